@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/stripe/stripe-cli/pkg/validators"
+)
+
+type devCmd struct {
+	cmd *cobra.Command
+}
+
+func newDevCmd() *devCmd {
+	dc := &devCmd{}
+
+	dc.cmd = &cobra.Command{
+		Use:   "dev",
+		Args:  validators.NoArgs,
+		Short: "Tools to develop your integration locally",
+		Long: `Tools that combine the other commands of the CLI to help you develop your
+integration locally.`,
+	}
+
+	dc.cmd.AddCommand(newDevWatchCmd().cmd)
+
+	return dc
+}
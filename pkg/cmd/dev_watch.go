@@ -0,0 +1,360 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/briandowns/spinner"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/stripe/stripe-cli/pkg/ansi"
+	"github.com/stripe/stripe-cli/pkg/config"
+	"github.com/stripe/stripe-cli/pkg/devwatch"
+	"github.com/stripe/stripe-cli/pkg/errorcategory"
+	"github.com/stripe/stripe-cli/pkg/logtailing"
+	"github.com/stripe/stripe-cli/pkg/proxy"
+	"github.com/stripe/stripe-cli/pkg/stripe"
+	"github.com/stripe/stripe-cli/pkg/validators"
+	"github.com/stripe/stripe-cli/pkg/version"
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+// devWatchFlushInterval is how often complete timelines are printed
+const devWatchFlushInterval = 500 * time.Millisecond
+
+type devWatchCmd struct {
+	cmd *cobra.Command
+
+	forwardURL     string
+	forwardHeaders []string
+	events         []string
+	skipVerify     bool
+	forwardTimeout time.Duration
+	settle         time.Duration
+	format         string
+	apiBaseURL     string
+	noWSS          bool
+	deviceToken    string
+}
+
+func newDevWatchCmd() *devWatchCmd {
+	wc := &devWatchCmd{}
+
+	wc.cmd = &cobra.Command{
+		Use:   "watch",
+		Args:  validators.NoArgs,
+		Short: "Watch API requests with the webhook events they trigger",
+		Long: `The watch command tails the API request logs of your account and listens for
+its webhook events at the same time, like 'stripe logs tail' and 'stripe listen'
+do. Events are linked to the request that triggered them, so that each request
+is printed with the events it triggered and how your endpoint responded to
+them.
+
+Requests are printed once no event was received for them for --settle, and,
+when events are forwarded, once your endpoint responded to all of them.`,
+		Example: `stripe dev watch
+  stripe dev watch --forward-to localhost:4242/webhook
+  stripe dev watch --events 'payment_intent.*,charge.*' --forward-to localhost:4242/webhook
+  stripe dev watch --format json | jq 'select(.events | length > 0)'`,
+		RunE: wc.runDevWatchCmd,
+	}
+
+	wc.cmd.Flags().StringVarP(&wc.forwardURL, "forward-to", "f", "", "The URL to forward webhook events to")
+	wc.cmd.Flags().StringSliceVarP(&wc.forwardHeaders, "headers", "H", []string{}, "A comma-separated list of custom headers to forward. Ex: \"Key1:Value1, Key2:Value2\"")
+	wc.cmd.Flags().StringSliceVarP(&wc.events, "events", "e", []string{"*"}, "A comma-separated list of specific events to listen for. Globs such as invoice.* and exclusions such as !invoice.upcoming are supported")
+	wc.cmd.Flags().BoolVar(&wc.skipVerify, "skip-verify", false, "Skip certificate verification when forwarding to HTTPS endpoints")
+	wc.cmd.Flags().DurationVar(&wc.forwardTimeout, "forward-timeout", 30*time.Second, "The time to wait for local endpoints to respond before giving up on a forward")
+	wc.cmd.Flags().DurationVar(&wc.settle, "settle", 5*time.Second, "The time to wait for more events after the last one a request triggered before printing it")
+	wc.cmd.Flags().StringVar(&wc.format, "format", "", `Specifies the output format of requests
+	Acceptable values:
+		'JSON' - Output a JSON object per line for each request, with its events and the responses of your endpoint`)
+
+	// Hidden configuration flags, useful for dev/debugging
+	wc.cmd.Flags().StringVar(&wc.apiBaseURL, "api-base", stripe.DefaultAPIBaseURL, "Sets the API base URL")
+	wc.cmd.Flags().MarkHidden("api-base") // #nosec G104
+
+	wc.cmd.Flags().BoolVar(&wc.noWSS, "no-wss", false, "Force unencrypted ws:// protocol instead of wss://")
+	wc.cmd.Flags().MarkHidden("no-wss") // #nosec G104
+
+	return wc
+}
+
+func (wc *devWatchCmd) runDevWatchCmd(cmd *cobra.Command, args []string) error {
+	if err := stripe.ValidateAPIBaseURL(wc.apiBaseURL); err != nil {
+		return err
+	}
+
+	if wc.settle <= 0 {
+		return errorcategory.New(errorcategory.UserInput, "--settle must be positive")
+	}
+
+	if ac, acErr := config.GetActiveContext(); acErr == nil && ac != nil && ac.Livemode {
+		return errorcategory.UserInputErrorf("'stripe dev watch' only works in sandboxes, but you're in live mode. Run 'stripe switch context' to select a sandbox.")
+	}
+
+	deviceName, err := Config.Profile.GetDeviceName()
+	if err != nil {
+		return err
+	}
+
+	creds, err := Config.Profile.ResolveCredentials(false)
+	if err != nil {
+		return err
+	}
+
+	apiBase, err := url.Parse(wc.apiBaseURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse API base url: %w", err)
+	}
+
+	if strings.ToUpper(wc.format) != outputFormatJSON {
+		version.CheckLatestVersion()
+	}
+
+	ctx, cancel := context.WithCancel(withSIGTERMCancel(cmd.Context(), func() {
+		log.WithFields(log.Fields{
+			"prefix": "cmd.devWatchCmd.runDevWatchCmd",
+		}).Debug("Ctrl+C received, cleaning up...")
+	}))
+	defer cancel()
+
+	client := &stripe.Client{
+		BaseURL:     apiBase,
+		Credentials: creds,
+	}
+
+	accountID, _ := Config.Profile.GetAccountID()
+	logger := log.StandardLogger()
+
+	proxyOutCh := make(chan websocket.IElement)
+	p, err := proxy.Init(ctx, &proxy.Config{
+		Client:            client,
+		DeviceName:        deviceName,
+		DeviceToken:       &wc.deviceToken,
+		ForwardURL:        wc.forwardURL,
+		ForwardHeaders:    wc.forwardHeaders,
+		WebSocketFeatures: []string{webhooksWebSocketFeature},
+		SkipVerify:        wc.skipVerify,
+		Log:               logger,
+		NoWSS:             wc.noWSS,
+		ForwardTimeout:    wc.forwardTimeout,
+		Events:            wc.events,
+		OutCh:             proxyOutCh,
+		LoggedInAccountID: accountID,
+	})
+	if err != nil {
+		return err
+	}
+
+	tailerOutCh := make(chan websocket.IElement)
+	tailer := logtailing.New(&logtailing.Config{
+		Client:     client,
+		DeviceName: deviceName,
+		Filters:    &logtailing.LogFilters{},
+		Log:        logger,
+		NoWSS:      wc.noWSS,
+		OutCh:      tailerOutCh,
+	})
+
+	go p.Run(ctx)
+	go tailer.Run(ctx)
+
+	// when forwarding, requests are held until the endpoint responded to
+	// their events, or gave up
+	var responseTimeout time.Duration
+	if wc.forwardURL != "" {
+		responseTimeout = wc.forwardTimeout
+	}
+
+	correlator := devwatch.NewCorrelator(wc.settle, responseTimeout)
+	visitor := wc.createVisitor(logger, correlator)
+
+	ticker := time.NewTicker(devWatchFlushInterval)
+	defer ticker.Stop()
+
+	// the other session is stopped when one of them is done, and timelines
+	// are printed until both are
+	for proxyOutCh != nil || tailerOutCh != nil {
+		select {
+		case el, ok := <-proxyOutCh:
+			if !ok {
+				proxyOutCh = nil
+				cancel()
+				continue
+			}
+
+			if err := el.Accept(visitor); err != nil {
+				return err
+			}
+		case el, ok := <-tailerOutCh:
+			if !ok {
+				tailerOutCh = nil
+				cancel()
+				continue
+			}
+
+			if err := el.Accept(visitor); err != nil {
+				return err
+			}
+		case now := <-ticker.C:
+			for _, timeline := range correlator.Flush(now) {
+				printTimeline(os.Stdout, timeline, wc.format)
+			}
+		}
+	}
+
+	for _, timeline := range correlator.FlushAll() {
+		printTimeline(os.Stdout, timeline, wc.format)
+	}
+
+	return nil
+}
+
+func (wc *devWatchCmd) createVisitor(logger *log.Logger, correlator *devwatch.Correlator) *websocket.Visitor {
+	var s *spinner.Spinner
+
+	// the proxy and the tailer are both ready once the proxy sent its
+	// signing secret and the tailer its ready state
+	var secret string
+	ready := 0
+
+	return &websocket.Visitor{
+		VisitError: func(ee websocket.ErrorElement) error {
+			switch ee.Error.(type) {
+			case proxy.FailedToPostError, proxy.FailedToReadResponseError:
+				color := ansi.Color(os.Stdout)
+				fmt.Printf("%s %v\n", color.Red("Failed to forward event:"), ee.Error)
+
+				// Don't exit program
+				return nil
+			}
+
+			ansi.StopSpinner(s, "", logger.Out)
+			return ee.Error
+		},
+		VisitWarning: func(we websocket.WarningElement) error {
+			color := ansi.Color(os.Stdout)
+			fmt.Printf("%s %s\n", color.Yellow("Warning"), we.Warning)
+			return nil
+		},
+		VisitStatus: func(se websocket.StateElement) error {
+			switch se.State {
+			case websocket.Loading:
+				if s == nil {
+					s = ansi.StartNewSpinner("Getting ready...", logger.Out)
+				}
+			case websocket.Reconnecting:
+				ansi.StartSpinner(s, "Session expired, reconnecting...", logger.Out)
+			case websocket.Ready:
+				if len(se.Data) > 1 {
+					secret = se.Data[1]
+				}
+
+				ready++
+				switch {
+				case ready == 2:
+					ansi.StopSpinner(s, fmt.Sprintf("Ready! You're now watching API requests and the events they trigger. Your webhook signing secret is %s (^C to quit)", ansi.Bold(secret)), logger.Out)
+				case ready > 2:
+					// a session reconnected
+					ansi.StopSpinner(s, "", logger.Out)
+				}
+			case websocket.Done:
+				ansi.StopSpinner(s, "", logger.Out)
+			}
+			return nil
+		},
+		VisitData: func(de websocket.DataElement) error {
+			now := time.Now()
+
+			switch data := de.Data.(type) {
+			case logtailing.EventPayload:
+				correlator.AddRequest(data, now)
+			case proxy.StripeEvent:
+				correlator.AddEvent(data, now)
+			case proxy.EndpointResponse:
+				correlator.AddResponse(data, now)
+			}
+
+			return nil
+		},
+	}
+}
+
+// printTimeline prints a request with the events it triggered and the
+// responses of endpoints to them.
+func printTimeline(out io.Writer, timeline *devwatch.Timeline, format string) {
+	if strings.ToUpper(format) == outputFormatJSON {
+		data, err := json.Marshal(timeline)
+		if err != nil {
+			return
+		}
+
+		fmt.Fprintln(out, string(data))
+
+		return
+	}
+
+	color := ansi.Color(out)
+
+	switch request := timeline.Request; {
+	case request != nil:
+		path := logtailing.Sanitize(request.URL)
+		if path == "" {
+			path = "[View path in dashboard]"
+		}
+
+		fmt.Fprintf(out, "%s [%d] %s %s [%s]\n",
+			color.Faint(time.Unix(int64(request.CreatedAt), 0).Format(timeLayout)),
+			ansi.ColorizeStatus(request.Status),
+			logtailing.Sanitize(request.Method),
+			path,
+			logtailing.Sanitize(request.RequestID),
+		)
+
+		if code := logtailing.Sanitize(request.Error.Code); code != "" {
+			fmt.Fprintf(out, "    %s %s\n", color.Red(code), logtailing.Sanitize(request.Error.Message))
+		}
+	case timeline.RequestID != "":
+		fmt.Fprintf(out, "%s [%s]\n", color.Faint("Request log not received"), logtailing.Sanitize(timeline.RequestID))
+	default:
+		fmt.Fprintln(out, color.Faint("Not triggered by an API request"))
+	}
+
+	if len(timeline.Events) == 0 {
+		fmt.Fprintf(out, "    %s\n", color.Faint("No events"))
+	}
+
+	for _, event := range timeline.Events {
+		maybeConnect := ""
+		if event.Account != "" {
+			maybeConnect = "connect "
+		}
+
+		fmt.Fprintf(out, "    --> %s%s [%s]\n",
+			color.BrightBlue(maybeConnect),
+			ansi.Bold(event.Type),
+			ansi.Linkify(event.ID, event.Event.URLForEventID(), out),
+		)
+
+		for _, response := range event.Responses {
+			line := fmt.Sprintf("        <-- [%d] %s %s %s",
+				ansi.ColorizeStatus(response.Status),
+				response.Method,
+				response.URL,
+				color.Faint(fmt.Sprintf("(%dms)", response.LatencyMS)),
+			)
+			if response.Attempt > 1 {
+				line += color.Faint(fmt.Sprintf(" (attempt %d)", response.Attempt)).String()
+			}
+
+			fmt.Fprintln(out, line)
+		}
+	}
+}
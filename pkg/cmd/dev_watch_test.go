@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/devwatch"
+	"github.com/stripe/stripe-cli/pkg/logtailing"
+)
+
+func testTimeline() *devwatch.Timeline {
+	return &devwatch.Timeline{
+		RequestID: "req_123",
+		Request: &logtailing.EventPayload{
+			CreatedAt: 1700000000,
+			Method:    "POST",
+			URL:       "/v1/payment_intents",
+			RequestID: "req_123",
+			Status:    200,
+		},
+		Events: []*devwatch.TimelineEvent{
+			{
+				ID:   "evt_1",
+				Type: "payment_intent.created",
+				Responses: []devwatch.TimelineResponse{
+					{URL: "http://localhost:4242/webhook", Method: "POST", Status: 500, Attempt: 1, LatencyMS: 12},
+					{URL: "http://localhost:4242/webhook", Method: "POST", Status: 200, Attempt: 2, LatencyMS: 35},
+				},
+			},
+			{ID: "evt_2", Type: "charge.succeeded"},
+		},
+	}
+}
+
+func TestPrintTimeline(t *testing.T) {
+	var out bytes.Buffer
+	printTimeline(&out, testTimeline(), "")
+
+	require.Contains(t, out.String(), "[200] POST /v1/payment_intents [req_123]\n")
+	require.Contains(t, out.String(), "    --> payment_intent.created [evt_1]\n"+
+		"        <-- [500] POST http://localhost:4242/webhook (12ms)\n"+
+		"        <-- [200] POST http://localhost:4242/webhook (35ms) (attempt 2)\n"+
+		"    --> charge.succeeded [evt_2]\n")
+}
+
+func TestPrintTimeline_WithoutRequest(t *testing.T) {
+	var out bytes.Buffer
+	printTimeline(&out, &devwatch.Timeline{RequestID: "req_123"}, "")
+	require.Equal(t, "Request log not received [req_123]\n    No events\n", out.String())
+
+	out.Reset()
+	printTimeline(&out, &devwatch.Timeline{Events: []*devwatch.TimelineEvent{{ID: "evt_1", Type: "invoice.upcoming"}}}, "")
+	require.Equal(t, "Not triggered by an API request\n    --> invoice.upcoming [evt_1]\n", out.String())
+}
+
+func TestPrintTimeline_SanitizesRequestLog(t *testing.T) {
+	timeline := testTimeline()
+	timeline.Request.Method = "\x1b[31mPOST"
+	timeline.Request.URL = "/v1/payment_intents\r\n\x1b[2J"
+	timeline.Request.RequestID = "req_123\n"
+	timeline.Request.Status = 400
+	timeline.Request.Error.Code = "\x1b[0mparameter_missing\n"
+	timeline.Request.Error.Message = "Missing\r\n required param\x1b[0m"
+
+	var out bytes.Buffer
+	printTimeline(&out, timeline, "")
+
+	require.Contains(t, out.String(), "[400] POST /v1/payment_intents [req_123]\n    parameter_missing Missing required param\n")
+}
+
+func TestPrintTimeline_JSON(t *testing.T) {
+	var out bytes.Buffer
+	printTimeline(&out, testTimeline(), "json")
+
+	var printed map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &printed))
+	require.Equal(t, "req_123", printed["request_id"])
+	require.Len(t, printed["events"], 2)
+}
+
+func TestDevWatch_InvalidSettle(t *testing.T) {
+	_, err := executeCommand(newDevCmd().cmd, "watch", "--settle", "0s")
+	require.EqualError(t, err, "--settle must be positive")
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
@@ -13,16 +15,21 @@ import (
 
 	"github.com/briandowns/spinner"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"golang.org/x/term"
 
 	"github.com/stripe/stripe-cli/pkg/ansi"
 	"github.com/stripe/stripe-cli/pkg/config"
 	"github.com/stripe/stripe-cli/pkg/errorcategory"
+	"github.com/stripe/stripe-cli/pkg/metrics"
 	"github.com/stripe/stripe-cli/pkg/proxy"
+	listentui "github.com/stripe/stripe-cli/pkg/proxy/tui"
 	"github.com/stripe/stripe-cli/pkg/stripe"
 	"github.com/stripe/stripe-cli/pkg/validators"
 	"github.com/stripe/stripe-cli/pkg/version"
+	"github.com/stripe/stripe-cli/pkg/webhooks"
 	"github.com/stripe/stripe-cli/pkg/websocket"
 )
 
@@ -31,6 +38,7 @@ const (
 	destinationsWebSocketFeature = "v2_events"
 	timeLayout                   = "2006-01-02 15:04:05"
 	outputFormatJSON             = "JSON"
+	outputFormatNDJSON           = "NDJSON"
 )
 
 type listenCmd struct {
@@ -56,6 +64,33 @@ type listenCmd struct {
 	noWSS                 bool
 	timeout               int64
 	deviceToken           string
+	journalPath           string
+	retryMaxAttempts      int
+	retryBackoff          time.Duration
+	retryMaxBackoff       time.Duration
+	retryStatusCodes      []int
+	forwardConcurrency    int
+	forwardQueueSize      int
+	routesFile            string
+	filters               []string
+	resignEvents          bool
+	signingSecret         string
+	tui                   bool
+	metricsAddr           string
+	healthAddr            string
+	secretFile            string
+	secretEnvVar          string
+	catchUp               bool
+	dedupe                bool
+	transforms            []string
+	transformCommand      string
+	respondWith           int
+	respondDelay          time.Duration
+	respondScript         string
+	forwardTimeout        time.Duration
+	slowThreshold         time.Duration
+	fanOutSocket          string
+	attachSocket          string
 }
 
 func newListenCmd() *listenCmd {
@@ -72,8 +107,26 @@ Stripe account.`,
 		Example: `stripe listen
   stripe listen --events charge.captured,charge.updated \
     --forward-to localhost:3000/events
+  stripe listen --events 'invoice.*,!invoice.upcoming'
+  stripe listen --filter 'data.object.metadata.tenant == "acme"'
   stripe listen --thin-events v1.billing.meter.no_meter_found \
-    --forward-thin-to localhost:3000/thin-events`,
+    --forward-thin-to localhost:3000/thin-events
+  stripe listen --forward-to unix:///tmp/app.sock:/webhooks
+  stripe listen --forward-to grpc://localhost:50051
+  stripe listen --forward-to 'exec:node handler.js'
+  stripe listen --routes routes.yaml
+  stripe listen --forward-to localhost:3000/events --tui
+  stripe listen --forward-to localhost:3000/events --metrics-addr localhost:9090
+  stripe listen --forward-to localhost:3000/events --health-addr localhost:8081
+  stripe listen --secret-file .env.local --secret-env-var STRIPE_WEBHOOK_SECRET
+  stripe listen --forward-to localhost:3000/events --journal events.jsonl --catch-up
+  stripe listen --forward-to localhost:3000/events \
+    --transform 'data.object.customer = "cus_local"' --transform 'del(data.object.metadata)'
+  stripe listen --respond-with 500 --respond-delay 35s
+  stripe listen --fanout-socket /tmp/stripe-listen.sock
+  stripe listen --attach /tmp/stripe-listen.sock --events 'invoice.*' \
+    --forward-to localhost:3000/events
+  stripe listen --forward-to localhost:3000/events --format ndjson | jq 'select(.type == "forward_result")'`,
 		Annotations: map[string]string{
 			AIAgentHelpAnnotationKey: "  Use `--forward-to` to specify where events are sent, e.g. localhost:4242/webhook.\n" +
 				"  Use `--events` to filter to specific event types, e.g. `--events checkout.session.completed`.\n" +
@@ -84,11 +137,12 @@ Stripe account.`,
 	}
 
 	lc.cmd.Flags().StringSliceVar(&lc.forwardConnectHeaders, "connect-headers", []string{}, "A comma-separated list of custom headers to forward for Connect. Ex: \"Key1:Value1, Key2:Value2\"")
-	lc.cmd.Flags().StringSliceVarP(&lc.events, "events", "e", []string{"*"}, "A comma-separated list of specific events to listen for. For a list of all possible events, see: https://stripe.com/docs/api/events/types")
-	lc.cmd.Flags().StringVarP(&lc.forwardURL, "forward-to", "f", "", "The URL to forward webhook events to")
+	lc.cmd.Flags().StringSliceVarP(&lc.events, "events", "e", []string{"*"}, "A comma-separated list of specific events to listen for. Globs such as invoice.* and exclusions such as !invoice.upcoming are supported. For a list of all possible events, see: https://stripe.com/docs/api/events/types")
+	lc.cmd.Flags().StringVarP(&lc.forwardURL, "forward-to", "f", "", "The URL to forward webhook events to. Use unix:///path/to/app.sock[:/path] to forward to a Unix socket, grpc://host:port[/package.Service/Method] to forward to a gRPC server, or exec:command to pipe events to a command")
+	lc.cmd.Flags().StringArrayVar(&lc.filters, "filter", []string{}, "Only print and forward events whose payload matches an expression like 'data.object.amount > 1000' or 'data.object.metadata.tenant == \"acme\" && !data.object.livemode'. Can be repeated, in which case events must match every filter")
 	lc.cmd.Flags().StringSliceVarP(&lc.forwardHeaders, "headers", "H", []string{}, "A comma-separated list of custom headers to forward. Ex: \"Key1:Value1, Key2:Value2\"")
 	lc.cmd.Flags().StringVarP(&lc.forwardConnectURL, "forward-connect-to", "c", "", "The URL to forward Connect webhook events to (default: same as normal events)")
-	lc.cmd.Flags().StringSliceVar(&lc.thinEvents, "thin-events", []string{}, "A comma-separated list of thin events to listen for.")
+	lc.cmd.Flags().StringSliceVar(&lc.thinEvents, "thin-events", []string{}, "A comma-separated list of thin events to listen for. Globs and exclusions are supported, like with --events.")
 	lc.cmd.Flags().StringVar(&lc.forwardThinURL, "forward-thin-to", "", "The URL to forward thin events to")
 	lc.cmd.Flags().StringVar(&lc.forwardThinConnectURL, "forward-thin-connect-to", "", "The URL to forward thin Connect events to")
 	lc.cmd.Flags().BoolVarP(&lc.latestAPIVersion, "latest", "l", false, "Receive events formatted with the latest API version (default: your account's default API version)")
@@ -97,11 +151,38 @@ Stripe account.`,
 	lc.cmd.Flags().MarkDeprecated("print-json", "Please use `--format json` instead and use `jq` if you need to process the JSON in the terminal.")
 	lc.cmd.Flags().StringVar(&lc.format, "format", "", `Specifies the output format of webhook events
 	Acceptable values:
-		'JSON' - Output webhook events in JSON format`)
+		'JSON' - Output webhook events in JSON format
+		'NDJSON' - Output a JSON record per line for received events, forward attempts and their results, errors and state changes`)
 	lc.cmd.Flags().BoolVarP(&lc.useConfiguredWebhooks, "use-configured-webhooks", "a", false, "Load webhook endpoint configuration from the webhooks API/dashboard")
 	lc.cmd.Flags().BoolVarP(&lc.skipVerify, "skip-verify", "", false, "Skip certificate verification when forwarding to HTTPS endpoints")
 	lc.cmd.Flags().BoolVar(&lc.onlyPrintSecret, "print-secret", false, "Only print the webhook signing secret and exit")
 	lc.cmd.Flags().BoolVarP(&lc.skipUpdate, "skip-update", "s", false, "Skip checking latest version of Stripe CLI")
+	lc.cmd.Flags().StringVar(&lc.routesFile, "routes", "", "A YAML or JSON file mapping event types (globs such as invoice.* are supported) to the URLs and headers to forward them to")
+	lc.cmd.Flags().IntVar(&lc.retryMaxAttempts, "retry-max-attempts", 1, "The maximum number of attempts to forward an event to a local endpoint, including the first one")
+	lc.cmd.Flags().DurationVar(&lc.retryBackoff, "retry-backoff", time.Second, "The delay before the first retry of a failed forward, doubled after every attempt")
+	lc.cmd.Flags().DurationVar(&lc.retryMaxBackoff, "retry-max-backoff", time.Minute, "The maximum delay between two attempts to forward an event")
+	lc.cmd.Flags().IntSliceVar(&lc.retryStatusCodes, "retry-status-codes", []int{}, "A comma-separated list of response status codes to retry (default: any non-2xx status)")
+	lc.cmd.Flags().IntVar(&lc.forwardConcurrency, "forward-concurrency", 0, "The maximum number of events forwarded to each endpoint at the same time. Use 1 to deliver events one at a time, in the order they were received (default: unlimited)")
+	lc.cmd.Flags().IntVar(&lc.forwardQueueSize, "forward-queue-size", 100, "The number of events that can wait for delivery to each endpoint when --forward-concurrency is set. No new events are received while the queue is full")
+	lc.cmd.Flags().BoolVar(&lc.tui, "tui", false, "Show an interactive dashboard of received events and endpoint responses, from which events can be resent or forwarded again")
+	lc.cmd.Flags().BoolVar(&lc.resignEvents, "resign", false, "Re-compute the Stripe-Signature header of forwarded events with the webhook signing secret of the session")
+	lc.cmd.Flags().StringVar(&lc.signingSecret, "signing-secret", "", "Re-compute the Stripe-Signature header of forwarded events with this webhook signing secret (whsec_...) instead of the session's")
+	lc.cmd.Flags().StringVar(&lc.metricsAddr, "metrics-addr", "", "Serve Prometheus metrics about received events, forwards and reconnections on /metrics at this address, like localhost:9090")
+	lc.cmd.Flags().StringVar(&lc.healthAddr, "health-addr", "", "Serve /healthz and /readyz at this address, like localhost:8081. /readyz succeeds once events can be received and returns the webhook signing secret as JSON")
+	lc.cmd.Flags().StringVar(&lc.secretFile, "secret-file", "", "Write the webhook signing secret to this dotenv file once ready, adding or updating the variable set by --secret-env-var")
+	lc.cmd.Flags().StringVar(&lc.secretEnvVar, "secret-env-var", webhooks.DefaultSecretEnvVar, "The variable the webhook signing secret is written to in --secret-file")
+	lc.cmd.Flags().BoolVar(&lc.catchUp, "catch-up", false, "After reconnecting, fetch the events missed while disconnected from the Events API and forward them. With --journal, the events missed since the journal's last event are also forwarded on startup")
+	lc.cmd.Flags().BoolVar(&lc.dedupe, "dedupe", false, "Suppress the deliveries of events already forwarded to an endpoint, answering Stripe with the endpoint's response to the first delivery")
+	lc.cmd.Flags().StringArrayVar(&lc.transforms, "transform", []string{}, "Rewrite the payload of forwarded events with an expression like 'data.object.customer = \"cus_local\"' or 'del(data.object.metadata)'. Can be repeated, in which case expressions are applied in order. Forwarded events are re-signed with the session's signing secret")
+	lc.cmd.Flags().StringVar(&lc.transformCommand, "transform-cmd", "", "Pipe the payload of forwarded events through this shell command, which must print the new JSON payload, like \"jq -c 'del(.data.object.metadata)'\". Forwarded events are re-signed with the session's signing secret")
+	lc.cmd.Flags().IntVar(&lc.respondWith, "respond-with", 0, "Instead of forwarding events, answer them with this response status, to see how Stripe handles failing endpoints (default: 200 when --respond-delay or --respond-script is set)")
+	lc.cmd.Flags().DurationVar(&lc.respondDelay, "respond-delay", 0, "Instead of forwarding events, answer them after this delay, like 35s, to see how Stripe handles slow endpoints")
+	lc.cmd.Flags().StringVar(&lc.respondScript, "respond-script", "", "Instead of forwarding events, answer them with the responses of a YAML or JSON file, matched in order by event type. Events matching none are answered according to --respond-with and --respond-delay")
+	lc.cmd.Flags().DurationVar(&lc.forwardTimeout, "forward-timeout", 30*time.Second, "The time to wait for local endpoints to respond before giving up on a forward")
+	lc.cmd.Flags().DurationVar(&lc.slowThreshold, "slow-threshold", proxy.DefaultSlowThreshold, "Flag forwards to endpoints taking longer than this to respond, since Stripe would likely time out waiting for them in production. Set to 0 to disable")
+	lc.cmd.Flags().StringVar(&lc.fanOutSocket, "fanout-socket", "", "Share this session with other `stripe listen --attach` processes over a Unix domain socket at this path. Attached processes only receive the events selected by this process's --events and --thin-events")
+	lc.cmd.Flags().StringVar(&lc.attachSocket, "attach", "", "Receive events from the `stripe listen --fanout-socket` process serving this socket instead of opening a new session with Stripe. Events are filtered and forwarded with this process's flags, but responses aren't sent back to Stripe")
+	lc.cmd.Flags().StringVar(&lc.journalPath, "journal", "", "Append received events and endpoint responses to a journal file, for use with \"stripe listen replay\"")
 
 	// Hidden configuration flags, useful for dev/debugging
 	lc.cmd.Flags().StringVar(&lc.apiBaseURL, "api-base", stripe.DefaultAPIBaseURL, "Sets the API base URL")
@@ -111,7 +192,7 @@ Stripe account.`,
 	lc.cmd.Flags().MarkHidden("no-wss") // #nosec G104
 
 	lc.cmd.Flags().Int64Var(&lc.timeout, "timeout", 30, "Sets timeout duration")
-	lc.cmd.Flags().MarkHidden("timeout")
+	lc.cmd.Flags().MarkDeprecated("timeout", "Please use `--forward-timeout` instead.")
 
 	// renamed --load-from-webhooks-api to --use-configured-webhooks,  but want to keep backward compatibility
 	lc.cmd.Flags().SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
@@ -121,6 +202,8 @@ Stripe account.`,
 		return pflag.NormalizedName(name)
 	})
 
+	lc.cmd.AddCommand(newListenReplayCmd().cmd)
+
 	return lc
 }
 
@@ -131,7 +214,30 @@ func (lc *listenCmd) runListenCmd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	if !lc.printJSON && !lc.onlyPrintSecret && !lc.skipUpdate {
+	if lc.tui && (lc.printJSON || lc.format != "" || lc.onlyPrintSecret) {
+		return errorcategory.New(errorcategory.UserInput, "--tui cannot be used with --format, --print-json or --print-secret")
+	}
+
+	if lc.tui && !term.IsTerminal(int(os.Stdout.Fd())) {
+		return errorcategory.New(errorcategory.UserInput, "--tui requires an interactive terminal")
+	}
+
+	if lc.fanOutSocket != "" && lc.attachSocket != "" {
+		return errorcategory.New(errorcategory.UserInput, "--fanout-socket cannot be used with --attach")
+	}
+
+	if lc.attachSocket != "" && (lc.onlyPrintSecret || lc.catchUp) {
+		return errorcategory.New(errorcategory.UserInput, "--attach cannot be used with --print-secret or --catch-up")
+	}
+
+	if err := webhooks.ValidateEnvVarName(lc.secretEnvVar); err != nil {
+		return err
+	}
+
+	ndjson := strings.ToUpper(lc.format) == outputFormatNDJSON
+
+	// the update notice would be mixed with the records on stdout
+	if !lc.printJSON && !ndjson && !lc.onlyPrintSecret && !lc.skipUpdate {
 		version.CheckLatestVersion()
 	}
 
@@ -179,6 +285,11 @@ func (lc *listenCmd) runListenCmd(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return err
 		}
+		if lc.secretFile != "" {
+			if err := webhooks.WriteSecretToDotEnv(afero.NewOsFs(), lc.secretFile, lc.secretEnvVar, secret); err != nil {
+				return err
+			}
+		}
 		fmt.Printf("%s\n", secret)
 		return nil
 	}
@@ -187,8 +298,16 @@ func (lc *listenCmd) runListenCmd(cmd *cobra.Command, args []string) error {
 
 	logger := log.StandardLogger()
 	proxyVisitor := lc.createVisitor(logger, lc.format, lc.printJSON)
+	if ndjson {
+		proxyVisitor = createNDJSONVisitor(os.Stdout, lc.slowThreshold)
+	}
 	proxyOutCh := make(chan websocket.IElement)
 
+	var metricsRegistry *metrics.Registry
+	if lc.metricsAddr != "" {
+		metricsRegistry = metrics.NewRegistry()
+	}
+
 	p, err := proxy.Init(ctx, &proxy.Config{
 		Client:                client,
 		DeviceName:            deviceName,
@@ -206,16 +325,64 @@ func (lc *listenCmd) runListenCmd(cmd *cobra.Command, args []string) error {
 		SkipVerify:            lc.skipVerify,
 		Log:                   logger,
 		NoWSS:                 lc.noWSS,
-		Timeout:               lc.timeout,
+		ForwardTimeout:        forwardTimeout(cmd, lc.forwardTimeout, lc.timeout),
 		Events:                lc.events,
 		ThinEvents:            lc.thinEvents,
 		OutCh:                 proxyOutCh,
 		LoggedInAccountID:     accountID,
+		JournalPath:           lc.journalPath,
+		RetryPolicy: proxy.RetryPolicy{
+			MaxAttempts:          lc.retryMaxAttempts,
+			Backoff:              lc.retryBackoff,
+			MaxBackoff:           lc.retryMaxBackoff,
+			RetryableStatusCodes: lc.retryStatusCodes,
+		},
+		DeliveryConcurrency: lc.forwardConcurrency,
+		DeliveryQueueSize:   lc.forwardQueueSize,
+		RoutesFile:          lc.routesFile,
+		Filters:             lc.filters,
+		ResignEvents:        lc.resignEvents,
+		SigningSecret:       lc.signingSecret,
+		Metrics:             metricsRegistry,
+		SecretFile:          lc.secretFile,
+		SecretEnvVar:        lc.secretEnvVar,
+		CatchUp:             lc.catchUp,
+		Dedupe:              lc.dedupe,
+		Transforms:          lc.transforms,
+		TransformCommand:    lc.transformCommand,
+		RespondWith:         lc.respondWith,
+		RespondDelay:        lc.respondDelay,
+		ResponseScript:      lc.respondScript,
+		FanOutSocket:        lc.fanOutSocket,
+		AttachSocket:        lc.attachSocket,
 	})
 	if err != nil {
 		return err
 	}
 
+	if err := lc.serveLocalEndpoints(ctx, p, metricsRegistry); err != nil {
+		return err
+	}
+
+	if lc.tui {
+		// the dashboard takes over the terminal, so logs would garble it
+		logger.SetOutput(io.Discard)
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		go p.Run(ctx)
+
+		return listentui.Run(proxyOutCh,
+			listentui.WithResend(func(ctx context.Context, evt listentui.Event) error {
+				// the resent event is expected, so it isn't a duplicate
+				p.AllowRedelivery(evt.ID)
+				return resendEvent(ctx, client, evt)
+			}),
+			listentui.WithReforward(p.Reforward),
+		)
+	}
+
 	go p.Run(ctx)
 
 	for el := range proxyOutCh {
@@ -228,6 +395,32 @@ func (lc *listenCmd) runListenCmd(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// resendEvent asks Stripe to send an event to the CLI again, like
+// `stripe events resend` does.
+func resendEvent(ctx context.Context, client stripe.RequestPerformer, evt listentui.Event) error {
+	if evt.Thin {
+		return errorcategory.New(errorcategory.UserInput, "thin events can't be resent")
+	}
+
+	params := url.Values{}
+	params.Set("for_stripecli", "true")
+	if evt.Account != "" {
+		params.Set("account", evt.Account)
+	}
+
+	resp, err := client.PerformRequest(ctx, http.MethodPost, "/v1/events/"+url.PathEscape(evt.ID)+"/retry", params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return errorcategory.Errorf(errorcategory.API, "request failed with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
 func withSIGTERMCancel(ctx context.Context, onCancel func()) context.Context {
 	// Create a context that will be canceled when Ctrl+C is pressed
 	ctx, cancel := context.WithCancel(ctx)
@@ -281,6 +474,19 @@ func (lc *listenCmd) createVisitor(logger *log.Logger, format string, printJSON
 				return ee.Error
 			}
 		},
+		VisitWarning: func(we websocket.WarningElement) error {
+			color := ansi.Color(os.Stdout)
+			localTime := time.Now().Format(timeLayout)
+
+			fmt.Printf("%s            [%s] %s\n",
+				color.Faint(localTime),
+				color.Yellow("WARNING"),
+				we.Warning,
+			)
+
+			// Don't exit program
+			return nil
+		},
 		VisitStatus: func(se websocket.StateElement) error {
 			switch se.State {
 			case websocket.Loading:
@@ -359,6 +565,62 @@ func (lc *listenCmd) createVisitor(logger *log.Logger, format string, printJSON
 					resp.Request.URL,
 					link,
 				)
+				if data.Attempt > 1 {
+					outputStr += ansi.Faint(fmt.Sprintf(" (attempt %d)", data.Attempt))
+				}
+				if data.Timing.Traced() {
+					outputStr += ansi.Faint(" " + formatForwardTiming(data.Timing))
+				}
+				if data.Timing.Slow(lc.slowThreshold) {
+					outputStr += color.Yellow(fmt.Sprintf(" slower than %s, Stripe would likely time out", lc.slowThreshold)).String()
+				}
+				fmt.Println(outputStr)
+				return nil
+			case proxy.EndpointAttempt:
+				// the attempt is printed with its response
+				return nil
+			case proxy.DuplicateSuppressed:
+				if strings.ToUpper(format) == outputFormatJSON || printJSON {
+					return nil
+				}
+
+				var eventType, link string
+				if data.Event != nil {
+					eventType = data.Event.Type
+					link = ansi.Linkify(data.Event.ID, data.Event.URLForEventID(), logger.Out)
+				} else if data.V2Event != nil {
+					eventType = data.V2Event.Type
+					link = ansi.Linkify(data.V2Event.ID, data.V2Event.URLForEventID(lc.deviceToken), logger.Out)
+				}
+				localTime := time.Now().Format(timeLayout)
+
+				color := ansi.Color(os.Stdout)
+				outputStr := fmt.Sprintf("%s   --> %s [%s] duplicate suppressed",
+					color.Faint(localTime),
+					color.Faint(eventType),
+					link,
+				)
+				fmt.Println(outputStr)
+				return nil
+			case proxy.EndpointRetry:
+				var link string
+				if data.Event != nil {
+					link = ansi.Linkify(data.Event.ID, data.Event.URLForEventID(), logger.Out)
+				} else if data.V2Event != nil {
+					link = ansi.Linkify(data.V2Event.ID, data.V2Event.URLForEventID(lc.deviceToken), logger.Out)
+				}
+				localTime := time.Now().Format(timeLayout)
+
+				color := ansi.Color(os.Stdout)
+				outputStr := fmt.Sprintf("%s            [%s] Retrying %s [%s] in %s (attempt %d of %d)",
+					color.Faint(localTime),
+					color.Yellow("RETRY"),
+					data.URL,
+					link,
+					data.Delay,
+					data.Attempt+1,
+					data.MaxAttempts,
+				)
 				fmt.Println(outputStr)
 				return nil
 			default:
@@ -368,6 +630,71 @@ func (lc *listenCmd) createVisitor(logger *log.Logger, format string, printJSON
 	}
 }
 
+// serveLocalEndpoints serves the endpoints requested with --metrics-addr and
+// --health-addr, sharing a server when they're on the same address.
+func (lc *listenCmd) serveLocalEndpoints(ctx context.Context, p *proxy.Proxy, registry *metrics.Registry) error {
+	muxes := make(map[string]*http.ServeMux)
+	mux := func(addr string) *http.ServeMux {
+		if _, ok := muxes[addr]; !ok {
+			muxes[addr] = http.NewServeMux()
+		}
+		return muxes[addr]
+	}
+
+	if lc.metricsAddr != "" {
+		mux(lc.metricsAddr).Handle("/metrics", registry)
+	}
+
+	if lc.healthAddr != "" {
+		health := p.HealthHandler()
+		mux(lc.healthAddr).Handle("/healthz", health)
+		mux(lc.healthAddr).Handle("/readyz", health)
+	}
+
+	for addr, m := range muxes {
+		if err := metrics.ListenAndServe(ctx, addr, m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// formatForwardTiming describes the time taken by a forward, like
+// "45ms (dns 2ms, connect 3ms, ttfb 44ms)".
+func formatForwardTiming(timing proxy.ForwardTiming) string {
+	phases := []string{}
+	if timing.DNS > 0 {
+		phases = append(phases, "dns "+roundDuration(timing.DNS).String())
+	}
+	if timing.Connect > 0 {
+		phases = append(phases, "connect "+roundDuration(timing.Connect).String())
+	}
+	phases = append(phases, "ttfb "+roundDuration(timing.TTFB).String())
+
+	return fmt.Sprintf("%s (%s)", roundDuration(timing.Total), strings.Join(phases, ", "))
+}
+
+// roundDuration rounds d to the millisecond, or to the microsecond when it's
+// shorter than a millisecond.
+func roundDuration(d time.Duration) time.Duration {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond)
+	}
+
+	return d.Round(time.Millisecond)
+}
+
+// forwardTimeout returns the timeout of --forward-timeout, or the one set in
+// seconds with the deprecated --timeout flag.
+func forwardTimeout(cmd *cobra.Command, timeout time.Duration, legacyTimeout int64) time.Duration {
+	if cmd.Flags().Changed("timeout") && !cmd.Flags().Changed("forward-timeout") {
+		return time.Duration(legacyTimeout) * time.Second
+	}
+
+	return timeout
+}
+
 func (lc *listenCmd) getFeatures() []string {
 	features := []string{}
 
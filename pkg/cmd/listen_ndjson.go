@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/acarl005/stripansi"
+
+	"github.com/stripe/stripe-cli/pkg/proxy"
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+// Types of the records printed with --format NDJSON
+const (
+	recordEventReceived  = "event_received"
+	recordForwardAttempt = "forward_attempt"
+	recordForwardResult  = "forward_result"
+	recordError          = "error"
+	recordStateChange    = "state_change"
+	recordWarning        = "warning"
+)
+
+// listenRecord is a line of the NDJSON output of stripe listen. Fields that
+// don't apply to a type of record are omitted.
+type listenRecord struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+
+	EventID   string `json:"event_id,omitempty"`
+	EventType string `json:"event_type,omitempty"`
+	Thin      bool   `json:"thin,omitempty"`
+	Account   string `json:"account,omitempty"`
+
+	// Payload is the event as delivered by Stripe
+	Payload json.RawMessage `json:"payload,omitempty"`
+
+	URL       string `json:"url,omitempty"`
+	Attempt   int    `json:"attempt,omitempty"`
+	Status    int    `json:"status,omitempty"`
+	LatencyMS int64  `json:"latency_ms,omitempty"`
+	DNSMS     int64  `json:"dns_ms,omitempty"`
+	ConnectMS int64  `json:"connect_ms,omitempty"`
+	TTFBMS    int64  `json:"ttfb_ms,omitempty"`
+
+	// Slow indicates whether the endpoint took longer than --slow-threshold
+	// to respond
+	Slow bool `json:"slow,omitempty"`
+
+	// Body is the endpoint's response body, truncated to 5000 characters
+	Body string `json:"body,omitempty"`
+
+	State  string `json:"state,omitempty"`
+	Secret string `json:"secret,omitempty"`
+
+	Message string `json:"message,omitempty"`
+}
+
+// createNDJSONVisitor returns a visitor printing every element of the proxy's
+// output as a typed JSON record, one per line. Forwards taking longer than
+// slowThreshold are flagged as slow.
+func createNDJSONVisitor(out io.Writer, slowThreshold time.Duration) *websocket.Visitor {
+	write := func(record listenRecord) error {
+		record.Time = time.Now().UTC()
+
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(out, string(line))
+		return err
+	}
+
+	return &websocket.Visitor{
+		VisitError: func(ee websocket.ErrorElement) error {
+			if err := write(listenRecord{Type: recordError, Message: ee.Error.Error()}); err != nil {
+				return err
+			}
+
+			switch ee.Error.(type) {
+			case proxy.FailedToPostError, proxy.FailedToReadResponseError:
+				// the event couldn't be delivered, but the proxy keeps running
+				return nil
+			default:
+				return ee.Error
+			}
+		},
+		VisitStatus: func(se websocket.StateElement) error {
+			record := listenRecord{Type: recordStateChange}
+
+			switch se.State {
+			case websocket.Loading:
+				record.State = "loading"
+			case websocket.Reconnecting:
+				record.State = "reconnecting"
+			case websocket.Ready:
+				record.State = "ready"
+				if len(se.Data) > 1 {
+					record.Secret = se.Data[1]
+				}
+			case websocket.Done:
+				record.State = "done"
+			}
+
+			return write(record)
+		},
+		VisitWarning: func(we websocket.WarningElement) error {
+			return write(listenRecord{Type: recordWarning, Message: we.Warning})
+		},
+		VisitData: func(de websocket.DataElement) error {
+			switch data := de.Data.(type) {
+			case proxy.StripeEvent:
+				return write(listenRecord{
+					Type:      recordEventReceived,
+					EventID:   data.ID,
+					EventType: data.Type,
+					Account:   data.Account,
+					Payload:   eventPayload(de),
+				})
+			case proxy.V2EventPayload:
+				return write(listenRecord{
+					Type:      recordEventReceived,
+					EventID:   data.ID,
+					EventType: data.Type,
+					Thin:      true,
+					Account:   data.Context,
+					Payload:   eventPayload(de),
+				})
+			case proxy.EndpointAttempt:
+				record := listenRecord{
+					Type:    recordForwardAttempt,
+					URL:     data.URL,
+					Attempt: data.Attempt,
+				}
+				setRecordEvent(&record, data.Event, data.V2Event)
+				return write(record)
+			case proxy.EndpointResponse:
+				record := listenRecord{
+					Type:      recordForwardResult,
+					Attempt:   data.Attempt,
+					Status:    data.Resp.StatusCode,
+					LatencyMS: data.Latency.Milliseconds(),
+					DNSMS:     data.Timing.DNS.Milliseconds(),
+					ConnectMS: data.Timing.Connect.Milliseconds(),
+					TTFBMS:    data.Timing.TTFB.Milliseconds(),
+					Slow:      data.Timing.Slow(slowThreshold),
+					Body:      data.ResponseBody,
+				}
+				if data.Resp.Request != nil {
+					record.URL = data.Resp.Request.URL.String()
+				}
+				setRecordEvent(&record, data.Event, data.V2Event)
+				return write(record)
+			default:
+				// retries and suppressed duplicates are described by the
+				// attempts and results around them
+				return nil
+			}
+		},
+	}
+}
+
+func setRecordEvent(record *listenRecord, evt *proxy.StripeEvent, v2Event *proxy.V2EventPayload) {
+	if evt != nil {
+		record.EventID = evt.ID
+		record.EventType = evt.Type
+	} else if v2Event != nil {
+		record.EventID = v2Event.ID
+		record.EventType = v2Event.Type
+		record.Thin = true
+	}
+}
+
+// eventPayload returns the payload of an event as delivered by Stripe, which
+// the proxy printed for --format JSON, or nil if it isn't valid JSON.
+func eventPayload(de websocket.DataElement) json.RawMessage {
+	payload := strings.TrimSpace(stripansi.Strip(de.Marshaled))
+	if !json.Valid([]byte(payload)) {
+		return nil
+	}
+
+	return json.RawMessage(payload)
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/proxy"
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+func readListenRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestNDJSONVisitor_ForwardedEvent(t *testing.T) {
+	var buf bytes.Buffer
+	visitor := createNDJSONVisitor(&buf, 30*time.Millisecond)
+
+	evt := &proxy.StripeEvent{ID: "evt_123", Type: "charge.succeeded", Account: "acct_123"}
+	forwardURL, _ := url.Parse("http://localhost:3000/events")
+
+	require.NoError(t, (&websocket.StateElement{State: websocket.Ready, Data: []string{"", "whsec_test"}}).Accept(visitor))
+	require.NoError(t, websocket.DataElement{
+		Data:      *evt,
+		Marshaled: `{"account":"acct_123","id":"evt_123","object":"event","type":"charge.succeeded"}` + "\n",
+	}.Accept(visitor))
+	require.NoError(t, websocket.DataElement{Data: proxy.EndpointAttempt{Event: evt, URL: forwardURL.String(), Attempt: 1}}.Accept(visitor))
+	require.NoError(t, websocket.DataElement{Data: proxy.EndpointResponse{
+		Event:        evt,
+		Resp:         &http.Response{StatusCode: 500, Request: &http.Request{URL: forwardURL}},
+		Attempt:      1,
+		ResponseBody: "oops",
+		Latency:      42 * time.Millisecond,
+		Timing:       proxy.ForwardTiming{TTFB: 40 * time.Millisecond, Total: 42 * time.Millisecond},
+	}}.Accept(visitor))
+	require.NoError(t, websocket.DataElement{Data: proxy.DuplicateSuppressed{Event: evt}}.Accept(visitor))
+
+	records := readListenRecords(t, &buf)
+	require.Len(t, records, 4)
+
+	require.Equal(t, "state_change", records[0]["type"])
+	require.Equal(t, "ready", records[0]["state"])
+	require.Equal(t, "whsec_test", records[0]["secret"])
+
+	require.Equal(t, "event_received", records[1]["type"])
+	require.Equal(t, "evt_123", records[1]["event_id"])
+	require.Equal(t, "charge.succeeded", records[1]["event_type"])
+	require.Equal(t, "acct_123", records[1]["account"])
+	require.Equal(t, map[string]interface{}{"account": "acct_123", "id": "evt_123", "object": "event", "type": "charge.succeeded"}, records[1]["payload"], "the payload is the one delivered by Stripe")
+
+	require.Equal(t, "forward_attempt", records[2]["type"])
+	require.Equal(t, "http://localhost:3000/events", records[2]["url"])
+	require.Equal(t, float64(1), records[2]["attempt"])
+
+	require.Equal(t, "forward_result", records[3]["type"])
+	require.Equal(t, "evt_123", records[3]["event_id"])
+	require.Equal(t, "http://localhost:3000/events", records[3]["url"])
+	require.Equal(t, float64(500), records[3]["status"])
+	require.Equal(t, float64(42), records[3]["latency_ms"])
+	require.Equal(t, float64(40), records[3]["ttfb_ms"])
+	require.Equal(t, true, records[3]["slow"])
+	require.Equal(t, "oops", records[3]["body"])
+	require.NotEmpty(t, records[3]["time"])
+}
+
+func TestNDJSONVisitor_Errors(t *testing.T) {
+	var buf bytes.Buffer
+	visitor := createNDJSONVisitor(&buf, proxy.DefaultSlowThreshold)
+
+	postErr := proxy.FailedToPostError{Err: errors.New("connection refused")}
+	require.NoError(t, websocket.ErrorElement{Error: postErr}.Accept(visitor))
+
+	fatalErr := errors.New("session expired")
+	require.Equal(t, fatalErr, websocket.ErrorElement{Error: fatalErr}.Accept(visitor))
+
+	records := readListenRecords(t, &buf)
+	require.Len(t, records, 2)
+	require.Equal(t, "error", records[0]["type"])
+	require.Equal(t, "connection refused", records[0]["message"])
+	require.Equal(t, "error", records[1]["type"])
+	require.Equal(t, "session expired", records[1]["message"])
+}
+
+func TestNDJSONVisitor_ThinEvent(t *testing.T) {
+	var buf bytes.Buffer
+	visitor := createNDJSONVisitor(&buf, 0)
+
+	payload := `{"context":"acct_123","id":"evt_456","object":"v2.core.event","type":"v1.billing.meter.no_meter_found"}`
+	require.NoError(t, websocket.DataElement{
+		Data:      proxy.V2EventPayload{ID: "evt_456", Type: "v1.billing.meter.no_meter_found", Context: "acct_123"},
+		Marshaled: payload + "\n",
+	}.Accept(visitor))
+
+	records := readListenRecords(t, &buf)
+	require.Len(t, records, 1)
+	require.Equal(t, true, records[0]["thin"])
+	require.Equal(t, "acct_123", records[0]["account"])
+
+	marshaled, err := json.Marshal(records[0]["payload"])
+	require.NoError(t, err)
+	require.JSONEq(t, payload, string(marshaled))
+}
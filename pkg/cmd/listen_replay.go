@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/stripe/stripe-cli/pkg/proxy"
+	"github.com/stripe/stripe-cli/pkg/validators"
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+type listenReplayCmd struct {
+	cmd *cobra.Command
+
+	journalPath           string
+	eventIDs              []string
+	forwardURL            string
+	forwardThinURL        string
+	forwardHeaders        []string
+	forwardConnectHeaders []string
+	forwardConnectURL     string
+	forwardThinConnectURL string
+	events                []string
+	thinEvents            []string
+	filters               []string
+	signingSecret         string
+	format                string
+	skipVerify            bool
+	forwardTimeout        time.Duration
+}
+
+func newListenReplayCmd() *listenReplayCmd {
+	rc := &listenReplayCmd{}
+
+	rc.cmd = &cobra.Command{
+		Use:   "replay",
+		Args:  validators.NoArgs,
+		Short: "Replay journaled webhook events to a local endpoint",
+		Long: `The replay command re-forwards events recorded with 'stripe listen --journal'
+to your local endpoints. Events are forwarded in the order they were received,
+without connecting to Stripe, so you can reproduce a webhook issue offline.`,
+		Example: `stripe listen replay --journal events.ndjson --forward-to localhost:3000/events
+  stripe listen replay --journal events.ndjson --event-id evt_123 \
+    --forward-to localhost:3000/events`,
+		RunE: rc.runListenReplayCmd,
+	}
+
+	rc.cmd.Flags().StringVar(&rc.journalPath, "journal", "", "The journal file to replay events from")
+	rc.cmd.Flags().StringSliceVar(&rc.eventIDs, "event-id", []string{}, "A comma-separated list of event IDs to replay (default: all journaled events)")
+	rc.cmd.Flags().StringSliceVar(&rc.forwardConnectHeaders, "connect-headers", []string{}, "A comma-separated list of custom headers to forward for Connect. Ex: \"Key1:Value1, Key2:Value2\"")
+	rc.cmd.Flags().StringSliceVarP(&rc.events, "events", "e", []string{"*"}, "A comma-separated list of specific events to replay")
+	rc.cmd.Flags().StringVar(&rc.signingSecret, "signing-secret", "", "Re-compute the Stripe-Signature header of replayed events with this webhook signing secret (whsec_...), since the recorded signatures are likely expired")
+	rc.cmd.Flags().StringVarP(&rc.forwardURL, "forward-to", "f", "", "The URL to forward webhook events to")
+	rc.cmd.Flags().StringSliceVarP(&rc.forwardHeaders, "headers", "H", []string{}, "A comma-separated list of custom headers to forward. Ex: \"Key1:Value1, Key2:Value2\"")
+	rc.cmd.Flags().StringVarP(&rc.forwardConnectURL, "forward-connect-to", "c", "", "The URL to forward Connect webhook events to (default: same as normal events)")
+	rc.cmd.Flags().StringSliceVar(&rc.thinEvents, "thin-events", []string{"*"}, "A comma-separated list of thin events to replay")
+	rc.cmd.Flags().StringArrayVar(&rc.filters, "filter", []string{}, "Only replay events whose payload matches an expression like 'data.object.amount > 1000'. Can be repeated, in which case events must match every filter")
+	rc.cmd.Flags().StringVar(&rc.forwardThinURL, "forward-thin-to", "", "The URL to forward thin events to")
+	rc.cmd.Flags().StringVar(&rc.forwardThinConnectURL, "forward-thin-connect-to", "", "The URL to forward thin Connect events to")
+	rc.cmd.Flags().StringVar(&rc.format, "format", "", `Specifies the output format of webhook events
+	Acceptable values:
+		'JSON' - Output webhook events in JSON format`)
+	rc.cmd.Flags().BoolVarP(&rc.skipVerify, "skip-verify", "", false, "Skip certificate verification when forwarding to HTTPS endpoints")
+	rc.cmd.Flags().DurationVar(&rc.forwardTimeout, "forward-timeout", 30*time.Second, "The time to wait for local endpoints to respond before giving up on a forward")
+
+	rc.cmd.MarkFlagRequired("journal")
+
+	return rc
+}
+
+func (rc *listenReplayCmd) runListenReplayCmd(cmd *cobra.Command, args []string) error {
+	accountID, _ := Config.Profile.GetAccountID()
+
+	logger := log.StandardLogger()
+	proxyVisitor := (&listenCmd{slowThreshold: proxy.DefaultSlowThreshold}).createVisitor(logger, rc.format, false)
+	proxyOutCh := make(chan websocket.IElement)
+
+	ctx := withSIGTERMCancel(cmd.Context(), func() {
+		log.WithFields(log.Fields{
+			"prefix": "proxy.Replay",
+		}).Debug("Ctrl+C received, cleaning up...")
+	})
+
+	go proxy.Replay(ctx, &proxy.ReplayConfig{
+		JournalPath:           rc.journalPath,
+		EventIDs:              rc.eventIDs,
+		ForwardURL:            rc.forwardURL,
+		ForwardThinURL:        rc.forwardThinURL,
+		ForwardHeaders:        rc.forwardHeaders,
+		ForwardConnectURL:     rc.forwardConnectURL,
+		ForwardThinConnectURL: rc.forwardThinConnectURL,
+		ForwardConnectHeaders: rc.forwardConnectHeaders,
+		Events:                rc.events,
+		ThinEvents:            rc.thinEvents,
+		Filters:               rc.filters,
+		SigningSecret:         rc.signingSecret,
+		SkipVerify:            rc.skipVerify,
+		Log:                   logger,
+		ForwardTimeout:        rc.forwardTimeout,
+		OutCh:                 proxyOutCh,
+		LoggedInAccountID:     accountID,
+	})
+
+	for el := range proxyOutCh {
+		err := el.Accept(proxyVisitor)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
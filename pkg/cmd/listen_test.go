@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/proxy"
+)
+
+func TestFormatForwardTiming(t *testing.T) {
+	require.Equal(t, "45ms (dns 2ms, connect 3ms, ttfb 44ms)", formatForwardTiming(proxy.ForwardTiming{
+		DNS:     2 * time.Millisecond,
+		Connect: 3 * time.Millisecond,
+		TTFB:    44 * time.Millisecond,
+		Total:   45*time.Millisecond + 300*time.Microsecond,
+	}))
+
+	// reused connections don't need a lookup or a new connection
+	require.Equal(t, "1.2s (ttfb 1.1s)", formatForwardTiming(proxy.ForwardTiming{
+		TTFB:  1100 * time.Millisecond,
+		Total: 1200 * time.Millisecond,
+	}))
+
+	require.Equal(t, "450µs (ttfb 400µs)", formatForwardTiming(proxy.ForwardTiming{
+		TTFB:  400 * time.Microsecond,
+		Total: 450 * time.Microsecond,
+	}))
+}
+
+func TestForwardTimeout(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		lc := newListenCmd()
+		require.NoError(t, lc.cmd.ParseFlags(args))
+		return lc.cmd
+	}
+
+	require.Equal(t, 30*time.Second, forwardTimeout(newCmd(), 30*time.Second, 30))
+	require.Equal(t, 5*time.Second, forwardTimeout(newCmd("--forward-timeout", "5s"), 5*time.Second, 30))
+
+	// the deprecated flag is in seconds
+	require.Equal(t, 10*time.Second, forwardTimeout(newCmd("--timeout", "10"), 30*time.Second, 10))
+}
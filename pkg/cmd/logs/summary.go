@@ -0,0 +1,125 @@
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/stripe/stripe-cli/pkg/ansi"
+	"github.com/stripe/stripe-cli/pkg/errorcategory"
+	"github.com/stripe/stripe-cli/pkg/logtailing"
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+const (
+	// summaryTopPaths is the number of paths printed in each summary
+	summaryTopPaths = 10
+
+	// summaryTopCodes is the number of error and decline codes printed in
+	// each summary
+	summaryTopCodes = 5
+)
+
+// printSummaries aggregates the request logs of outCh and prints a summary of
+// them every interval, until outCh is closed. Other elements are handled by
+// visitor.
+func printSummaries(out io.Writer, outCh <-chan websocket.IElement, visitor *websocket.Visitor, interval time.Duration, format string) error {
+	summary := logtailing.NewSummary(time.Now())
+
+	summaryVisitor := *visitor
+	summaryVisitor.VisitData = func(de websocket.DataElement) error {
+		log, ok := de.Data.(logtailing.EventPayload)
+		if !ok {
+			return errorcategory.Errorf(errorcategory.Internal, "VisitData received unexpected type for DataElement, got %T expected %T", de, logtailing.EventPayload{})
+		}
+
+		summary.Add(log)
+
+		return nil
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case el, ok := <-outCh:
+			if !ok {
+				// the requests received since the last summary aren't lost
+				// when quitting
+				if window := summary.Flush(time.Now()); window.Requests > 0 {
+					printSummary(out, window, format)
+				}
+
+				return nil
+			}
+
+			if err := el.Accept(&summaryVisitor); err != nil {
+				return err
+			}
+		case now := <-ticker.C:
+			printSummary(out, summary.Flush(now), format)
+		}
+	}
+}
+
+func printSummary(out io.Writer, window logtailing.SummaryWindow, format string) {
+	if strings.ToUpper(format) == outputFormatJSON {
+		data, err := json.Marshal(window)
+		if err != nil {
+			return
+		}
+
+		fmt.Fprintln(out, string(data))
+
+		return
+	}
+
+	color := ansi.Color(out)
+	timeLayout := "2006-01-02 15:04:05"
+
+	fmt.Fprintf(out, "%s %s, %d errors (%.1f%%)\n",
+		color.Faint(fmt.Sprintf("%s - %s", window.Start.Format(timeLayout), window.End.Format("15:04:05"))),
+		color.Bold(fmt.Sprintf("%d requests", window.Requests)),
+		window.Errors,
+		window.ErrorRate*100,
+	)
+
+	if window.Requests == 0 {
+		return
+	}
+
+	fmt.Fprintln(out, "  Paths:")
+	for _, path := range top(window.Paths, summaryTopPaths) {
+		fmt.Fprintf(out, "    %6d  %s\n", path.Count, logtailing.Sanitize(path.Key))
+	}
+
+	fmt.Fprintf(out, "  Status codes: %s\n", formatCounts(window.StatusCodes))
+
+	if len(window.ErrorCodes) > 0 {
+		fmt.Fprintf(out, "  Error codes: %s\n", formatCounts(top(window.ErrorCodes, summaryTopCodes)))
+	}
+
+	if len(window.DeclineCodes) > 0 {
+		fmt.Fprintf(out, "  Decline codes: %s\n", formatCounts(top(window.DeclineCodes, summaryTopCodes)))
+	}
+}
+
+func top(counts []logtailing.SummaryCount, n int) []logtailing.SummaryCount {
+	if len(counts) > n {
+		return counts[:n]
+	}
+
+	return counts
+}
+
+func formatCounts(counts []logtailing.SummaryCount) string {
+	formatted := make([]string, 0, len(counts))
+	for _, count := range counts {
+		formatted = append(formatted, fmt.Sprintf("%s (%d)", logtailing.Sanitize(count.Key), count.Count))
+	}
+
+	return strings.Join(formatted, ", ")
+}
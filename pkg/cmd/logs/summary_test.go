@@ -0,0 +1,67 @@
+package logs
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/logtailing"
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+func TestPrintSummaries(t *testing.T) {
+	outCh := make(chan websocket.IElement, 3)
+	outCh <- websocket.DataElement{Data: logtailing.EventPayload{Method: "POST", URL: "/v1/charges", Status: 200}}
+	outCh <- websocket.DataElement{Data: logtailing.EventPayload{Method: "POST", URL: "/v1/charges", Status: 402, Error: logtailing.RedactedError{Code: "card_declined", DeclineCode: "generic_decline"}}}
+	outCh <- websocket.WarningElement{Warning: "careful"}
+	close(outCh)
+
+	warnings := 0
+	visitor := &websocket.Visitor{
+		VisitWarning: func(websocket.WarningElement) error {
+			warnings++
+			return nil
+		},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, printSummaries(&out, outCh, visitor, time.Hour, ""))
+
+	require.Equal(t, 1, warnings)
+	require.Contains(t, out.String(), "2 requests, 1 errors (50.0%)")
+	require.Contains(t, out.String(), "     2  POST /v1/charges\n")
+	require.Contains(t, out.String(), "Status codes: 200 (1), 402 (1)\n")
+	require.Contains(t, out.String(), "Error codes: card_declined (1)\n")
+	require.Contains(t, out.String(), "Decline codes: generic_decline (1)\n")
+}
+
+func TestPrintSummary_JSON(t *testing.T) {
+	var out bytes.Buffer
+	printSummary(&out, logtailing.SummaryWindow{
+		Requests:  4,
+		Errors:    1,
+		ErrorRate: 0.25,
+		Paths:     []logtailing.SummaryCount{{Key: "GET /v1/customers/{id}", Count: 4}},
+	}, "json")
+
+	var window map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &window))
+	require.Equal(t, float64(4), window["requests"])
+	require.Equal(t, 0.25, window["error_rate"])
+}
+
+func TestValidateArgs_Summary(t *testing.T) {
+	tailCmd := NewTailCmd(nil)
+	tailCmd.summary = -time.Second
+	require.Error(t, tailCmd.validateArgs())
+
+	tailCmd.summary = 30 * time.Second
+	tailCmd.expand = true
+	require.Error(t, tailCmd.validateArgs())
+
+	tailCmd.expand = false
+	require.NoError(t, tailCmd.validateArgs())
+}
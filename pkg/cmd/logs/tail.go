@@ -2,18 +2,22 @@
 package logs
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"math"
+	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
 	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
-	"github.com/acarl005/stripansi"
 	"github.com/briandowns/spinner"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -22,6 +26,8 @@ import (
 	"github.com/stripe/stripe-cli/pkg/config"
 	"github.com/stripe/stripe-cli/pkg/errorcategory"
 	"github.com/stripe/stripe-cli/pkg/logtailing"
+	"github.com/stripe/stripe-cli/pkg/matcher"
+	"github.com/stripe/stripe-cli/pkg/metrics"
 	"github.com/stripe/stripe-cli/pkg/stripe"
 	"github.com/stripe/stripe-cli/pkg/validators"
 	"github.com/stripe/stripe-cli/pkg/version"
@@ -30,8 +36,6 @@ import (
 
 const outputFormatJSON = "JSON"
 
-var newlineRegex = regexp.MustCompile("[\r\n]")
-
 // TailCmd wraps the configuration for the tail command
 type TailCmd struct {
 	apiBaseURL string
@@ -40,6 +44,16 @@ type TailCmd struct {
 	format     string
 	LogFilters *logtailing.LogFilters
 	noWSS      bool
+
+	expand              bool
+	summary             time.Duration
+	where               string
+	whereExpression     *logtailing.WhereExpression
+	metricsAddr         string
+	output              string
+	rotateSize          string
+	rotateSizeBytes     int64
+	requestPathPatterns []string
 }
 
 // NewTailCmd creates and initializes the tail command for the logs package
@@ -58,7 +72,13 @@ Log tailing allows you to filter data similarly to the Stripe Dashboard; filter
 HTTP methods, IP addresses, paths, response status, and more.`,
 		Example: `stripe logs tail
   stripe logs tail --filter-http-method GET
-  stripe logs tail --filter-status-code-type 4XX`,
+  stripe logs tail --filter-status-code-type 4XX
+  stripe logs tail --filter-request-path '/v1/payment_intents*'
+  stripe logs tail --filter-status-code-type 4XX --expand
+  stripe logs tail --summary 30s
+  stripe logs tail --output logs.ndjson --rotate-size 50MB
+  stripe logs tail --expand --output requests.har
+  stripe logs tail --where 'status >= 400 && url ~ "/v1/payment_intents" && error.code != "card_declined"'`,
 		Annotations: map[string]string{
 			"ai_agent_help": "  Use `--format json` for machine-readable output.\n" +
 				"  Filter with `--filter-http-method`, `--filter-status-code-type`, or `--filter-request-path`.",
@@ -97,7 +117,7 @@ Acceptable values:
 	'POST'   - HTTP post requests
 	'DELETE' - HTTP delete requests`,
 	)
-	tailCmd.Cmd.Flags().StringSliceVar(&tailCmd.LogFilters.FilterRequestPath, "filter-request-path", []string{}, "Filter request logs by request path")
+	tailCmd.Cmd.Flags().StringSliceVar(&tailCmd.LogFilters.FilterRequestPath, "filter-request-path", []string{}, "Filter request logs by request path. Globs such as /v1/payment_intents* and exclusions such as !/v1/customers* are supported")
 	tailCmd.Cmd.Flags().StringSliceVar(
 		&tailCmd.LogFilters.FilterRequestStatus,
 		"filter-request-status",
@@ -128,6 +148,15 @@ Acceptable values:
 	'5XX' - All 5XX status codes`,
 	)
 
+	tailCmd.Cmd.Flags().BoolVar(&tailCmd.expand, "expand", false, "Fetch and print the detail of each request, like its parameters, idempotency key, API version and the ID of the object it returned")
+	tailCmd.Cmd.Flags().DurationVar(&tailCmd.summary, "summary", 0, "Instead of printing each request, print a summary of the requests received every interval, like 30s: request counts by path, status codes, top error and decline codes, and error rate")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.where, "where", "", `Filter request logs locally with an expression over their fields, like 'status >= 400 && url ~ "/v1/payment_intents"'.
+Fields are named like in the JSON output (status, method, url, request_id, livemode, error.code, error.decline_code, ...).
+Conditions can be combined with &&, || and !, and ~ matches a regular expression`)
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.output, "output", "", "Also write every request log to this file, as newline delimited JSON, or as a HAR archive when it ends with .har, an existing archive being moved aside. Includes the detail of requests with --expand")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.rotateSize, "rotate-size", "", "Start a new --output file when it grows over this size, like 50MB. Previous files are kept as logs.1.ndjson, logs.2.ndjson, ...")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.metricsAddr, "metrics-addr", "", "Serve Prometheus metrics on /metrics at this address, like localhost:9090")
+
 	// Hidden configuration flags, useful for dev/debugging
 	tailCmd.Cmd.Flags().StringVar(&tailCmd.apiBaseURL, "api-base", stripe.DefaultAPIBaseURL, "Sets the API base URL")
 	tailCmd.Cmd.Flags().MarkHidden("api-base") // #nosec G104
@@ -194,6 +223,37 @@ func (tailCmd *TailCmd) runTailCmd(cmd *cobra.Command, args []string) error {
 
 	logtailingOutCh := make(chan websocket.IElement)
 
+	ctx := withSIGTERMCancel(cmd.Context(), func() {
+		log.WithFields(log.Fields{
+			"prefix": "logtailing.Tailer.Run",
+		}).Debug("Ctrl+C received, cleaning up...")
+	})
+
+	var metricsRegistry *metrics.Registry
+	if tailCmd.metricsAddr != "" {
+		metricsRegistry = metrics.NewRegistry()
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metricsRegistry)
+
+		if err := metrics.ListenAndServe(ctx, tailCmd.metricsAddr, mux); err != nil {
+			return err
+		}
+	}
+
+	var recorder *logtailing.Recorder
+	if tailCmd.output != "" {
+		recorder, err = logtailing.NewRecorder(&logtailing.RecorderConfig{
+			Path:       tailCmd.output,
+			RotateSize: tailCmd.rotateSizeBytes,
+			BaseURL:    tailCmd.apiBaseURL,
+		})
+		if err != nil {
+			return err
+		}
+		defer recorder.Close()
+	}
+
 	tailer := logtailing.New(&logtailing.Config{
 		Client: &stripe.Client{
 			BaseURL:     apiBase,
@@ -204,16 +264,20 @@ func (tailCmd *TailCmd) runTailCmd(cmd *cobra.Command, args []string) error {
 		Log:        logger,
 		NoWSS:      tailCmd.noWSS,
 		OutCh:      logtailingOutCh,
-	})
 
-	ctx := withSIGTERMCancel(cmd.Context(), func() {
-		log.WithFields(log.Fields{
-			"prefix": "logtailing.Tailer.Run",
-		}).Debug("Ctrl+C received, cleaning up...")
+		Metrics:             metricsRegistry,
+		RequestPathPatterns: tailCmd.requestPathPatterns,
+		Where:               tailCmd.whereExpression,
+		Expand:              tailCmd.expand,
+		Recorder:            recorder,
 	})
 
 	go tailer.Run(ctx)
 
+	if tailCmd.summary > 0 {
+		return printSummaries(os.Stdout, logtailingOutCh, logtailingVisitor, tailCmd.summary, tailCmd.format)
+	}
+
 	for el := range logtailingOutCh {
 		err := el.Accept(logtailingVisitor)
 		if err != nil {
@@ -225,6 +289,18 @@ func (tailCmd *TailCmd) runTailCmd(cmd *cobra.Command, args []string) error {
 }
 
 func (tailCmd *TailCmd) validateArgs() error {
+	if tailCmd.summary < 0 {
+		return errorcategory.New(errorcategory.UserInput, "the summary interval cannot be negative")
+	}
+
+	if tailCmd.summary > 0 && tailCmd.expand {
+		return errorcategory.New(errorcategory.UserInput, "--expand cannot be used with --summary")
+	}
+
+	if tailCmd.rotateSize != "" && tailCmd.output == "" {
+		return errorcategory.New(errorcategory.UserInput, "--rotate-size can only be used with --output")
+	}
+
 	err := validators.CallNonEmptyArray(validators.Account, tailCmd.LogFilters.FilterAccount)
 	if err != nil {
 		return err
@@ -266,9 +342,74 @@ func (tailCmd *TailCmd) convertArgs() error {
 		}
 	}
 
+	if tailCmd.where != "" {
+		expression, err := logtailing.ParseWhereExpression(tailCmd.where)
+		if err != nil {
+			return err
+		}
+
+		tailCmd.whereExpression = expression
+	}
+
+	if tailCmd.rotateSize != "" {
+		size, err := parseByteSize(tailCmd.rotateSize)
+		if err != nil {
+			return err
+		}
+
+		tailCmd.rotateSizeBytes = size
+	}
+
+	// The backend only filters on exact request paths, so when patterns are
+	// used all of the request path filters are applied locally instead
+	for _, path := range tailCmd.LogFilters.FilterRequestPath {
+		if matcher.IsPattern(path) {
+			tailCmd.requestPathPatterns = tailCmd.LogFilters.FilterRequestPath
+			tailCmd.LogFilters.FilterRequestPath = []string{}
+			break
+		}
+	}
+
 	return nil
 }
 
+// byteSizeUnits are the units of --rotate-size, as powers of 1024
+var byteSizeUnits = map[string]int64{
+	"":    1,
+	"B":   1,
+	"K":   1 << 10,
+	"KB":  1 << 10,
+	"KIB": 1 << 10,
+	"M":   1 << 20,
+	"MB":  1 << 20,
+	"MIB": 1 << 20,
+	"G":   1 << 30,
+	"GB":  1 << 30,
+	"GIB": 1 << 30,
+}
+
+var byteSizeRegex = regexp.MustCompile(`^([0-9]+)\s*([A-Za-z]*)$`)
+
+// parseByteSize parses a size like 50MB or 512KiB into a number of bytes.
+func parseByteSize(size string) (int64, error) {
+	matches := byteSizeRegex.FindStringSubmatch(strings.TrimSpace(size))
+	if matches == nil {
+		return 0, errorcategory.Errorf(errorcategory.UserInput, "invalid size %q, expected a size like 50MB", size)
+	}
+
+	unit, ok := byteSizeUnits[strings.ToUpper(matches[2])]
+	if !ok {
+		return 0, errorcategory.Errorf(errorcategory.UserInput, "invalid size %q, units are B, KB, MB and GB", size)
+	}
+
+	value, err := strconv.ParseInt(matches[1], 10, 64)
+	if err != nil || value == 0 || value > math.MaxInt64/unit {
+		return 0, errorcategory.Errorf(errorcategory.UserInput, "invalid size %q, expected a size like 50MB", size)
+	}
+
+	return value * unit, nil
+}
+
 func createVisitor(logger *log.Logger, format string) *websocket.Visitor {
 	var s *spinner.Spinner
 
@@ -301,7 +442,7 @@ func createVisitor(logger *log.Logger, format string) *websocket.Visitor {
 				return errorcategory.Errorf(errorcategory.Internal, "VisitData received unexpected type for DataElement, got %T expected %T", de, logtailing.EventPayload{})
 			}
 
-			sanitizePayload(&log)
+			logtailing.SanitizePayload(&log)
 
 			if strings.ToUpper(format) == outputFormatJSON {
 				fmt.Println(ansi.ColorizeJSON(de.Marshaled, false, os.Stdout))
@@ -338,38 +479,64 @@ func createVisitor(logger *log.Logger, format string) *websocket.Visitor {
 					fmt.Printf("%s: %s\n", fieldName, fieldValue)
 				}
 			}
+
+			printRequestDetail(log.Detail)
+
 			return nil
 		},
 	}
 }
 
-func urlForRequestID(payload *logtailing.EventPayload) string {
-	maybeTest := ""
-	if !payload.Livemode {
-		maybeTest = "/test"
+// printRequestDetail prints the detail of a request fetched with --expand.
+func printRequestDetail(detail *logtailing.RequestDetail) {
+	if detail == nil {
+		return
 	}
 
-	return fmt.Sprintf("https://dashboard.stripe.com%s/logs/%s", maybeTest, payload.RequestID)
+	for _, field := range []struct {
+		name  string
+		value string
+	}{
+		{"APIVersion", detail.APIVersion},
+		{"IdempotencyKey", detail.IdempotencyKey},
+		{"ObjectID", detail.ObjectID},
+		{"RequestParams", formatRequestParams(detail.RequestParams)},
+	} {
+		if field.value != "" {
+			fmt.Printf("%s: %s\n", field.name, field.value)
+		}
+	}
 }
 
-func sanitize(str string) string {
-	withoutAnsi := stripansi.Strip(str)
-	withoutNewlines := newlineRegex.ReplaceAllLiteralString(withoutAnsi, "")
-	return strings.TrimSpace(withoutNewlines)
-}
+// formatRequestParams returns params as compact JSON, or as is when the
+// request log API returned them form-encoded.
+func formatRequestParams(params json.RawMessage) string {
+	if len(params) == 0 {
+		return ""
+	}
+
+	var encoded string
+	if err := json.Unmarshal(params, &encoded); err == nil {
+		return logtailing.Sanitize(encoded)
+	}
 
-func sanitizePayload(payload *logtailing.EventPayload) {
-	payload.Error.Charge = sanitize(payload.Error.Charge)
-	payload.Error.Code = sanitize(payload.Error.Code)
-	payload.Error.DeclineCode = sanitize(payload.Error.DeclineCode)
-	payload.Error.ErrorInsight = sanitize(payload.Error.ErrorInsight)
-	payload.Error.Message = sanitize(payload.Error.Message)
-	payload.Error.Param = sanitize(payload.Error.Param)
-	payload.Error.Type = sanitize(payload.Error.Type)
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, params); err != nil {
+		return ""
+	}
 
-	payload.Method = sanitize(payload.Method)
+	if buf.String() == "{}" {
+		return ""
+	}
 
-	payload.RequestID = sanitize(payload.RequestID)
+	return logtailing.Sanitize(buf.String())
+}
+
+func urlForRequestID(payload *logtailing.EventPayload) string {
+	maybeTest := ""
+	if !payload.Livemode {
+		maybeTest = "/test"
+	}
 
-	payload.URL = sanitize(payload.URL)
+	return fmt.Sprintf("https://dashboard.stripe.com%s/logs/%s", maybeTest, payload.RequestID)
 }
@@ -1,123 +1,72 @@
 package logs
 
 import (
-	"fmt"
-	"reflect"
+	"encoding/json"
 	"testing"
 
-	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-
-	"github.com/stripe/stripe-cli/pkg/logtailing"
 )
 
-func hasZeroValueString(v reflect.Value) bool {
-	switch v.Kind() {
-	case reflect.Struct:
-		for i := 0; i < v.NumField(); i++ {
-			if hasZeroValueString(v.Field(i)) {
-				return true
-			}
-		}
-		return false
-	case reflect.String:
-		return v.IsZero()
-	default:
-		return false
-	}
+func TestConvertArgs_RequestPathPatterns(t *testing.T) {
+	tailCmd := NewTailCmd(nil)
+	tailCmd.LogFilters.FilterRequestPath = []string{"/v1/customers"}
+	require.NoError(t, tailCmd.convertArgs())
+	require.Equal(t, []string{"/v1/customers"}, tailCmd.LogFilters.FilterRequestPath)
+	require.Empty(t, tailCmd.requestPathPatterns)
+
+	tailCmd = NewTailCmd(nil)
+	tailCmd.LogFilters.FilterRequestPath = []string{"/v1/customers", "/v1/payment_intents*", "!/v1/payment_intents/*/confirm"}
+	require.NoError(t, tailCmd.convertArgs())
+	require.Empty(t, tailCmd.LogFilters.FilterRequestPath)
+	require.Equal(t, []string{"/v1/customers", "/v1/payment_intents*", "!/v1/payment_intents/*/confirm"}, tailCmd.requestPathPatterns)
 }
 
-func containsZeroValueStrings(x interface{}) bool {
-	v := reflect.ValueOf(x)
-
-	// If it's a pointer, dereference it
-	if v.Kind() == reflect.Pointer {
-		v = v.Elem()
-	}
-
-	if !v.IsValid() {
-		return true
-	}
-
-	return hasZeroValueString(v)
+func TestFormatRequestParams(t *testing.T) {
+	require.Equal(t, "", formatRequestParams(nil))
+	require.Equal(t, "", formatRequestParams(json.RawMessage(`{}`)))
+	require.Equal(t, `{"amount":"2000","currency":"usd"}`, formatRequestParams(json.RawMessage(`{"amount": "2000", "currency": "usd"}`)))
+	require.Equal(t, "amount=2000&currency=usd", formatRequestParams(json.RawMessage(`"amount=2000&currency=usd"`)))
 }
 
-func TestSanitize(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected string
-	}{
-		{
-			name:     "does not change basic strings",
-			input:    "GET",
-			expected: "GET",
-		},
-		{
-			name:     "removes ansi escape codes",
-			input:    "\x0d\x0a\x1b[90mvery cool\x0d\x0a\x1b[32m and very legal",
-			expected: "very cool and very legal",
-		},
-		{
-			name:     "removes newlines",
-			input:    "\x0d\x0a\x1b[90mvery cool",
-			expected: "very cool",
-		},
-		{
-			name:     "removes both ansi escape codes and newlines",
-			input:    "\x0d\x0a\x1b[90ma horse\r\n a dog\n a cat\x0d\x0a\x1b[32m and a bird",
-			expected: "a horse a dog a cat and a bird",
-		},
-	}
+func TestConvertArgs_Where(t *testing.T) {
+	tailCmd := NewTailCmd(nil)
+	tailCmd.where = `status >= 400 && error.code != "card_declined"`
+	require.NoError(t, tailCmd.convertArgs())
+	require.NotNil(t, tailCmd.whereExpression)
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			actual := sanitize(tt.input)
-			assert.Equal(t, tt.expected, actual)
-		})
-	}
+	tailCmd.where = `status >=`
+	require.Error(t, tailCmd.convertArgs())
 }
 
-func TestSanitizePayload(t *testing.T) {
-	withAnsi := func(s string) string {
-		return fmt.Sprintf("\x1b[90m%s\x1b[0m", s)
-	}
-
-	payload := logtailing.EventPayload{
-		Error: logtailing.RedactedError{
-			Charge:       withAnsi("ch_123"),
-			Code:         withAnsi("invlaid_argument"),
-			DeclineCode:  withAnsi("card_declined"),
-			ErrorInsight: withAnsi("make fewer errors"),
-			Message:      withAnsi("an error occurred"),
-			Param:        withAnsi("card"),
-			Type:         withAnsi("invalid_request"),
-		},
-		Method:    withAnsi("POST"),
-		RequestID: withAnsi("req_123"),
-		URL:       withAnsi("https://example.com"),
+func TestParseByteSize(t *testing.T) {
+	for size, expected := range map[string]int64{
+		"1024":   1024,
+		"10B":    10,
+		"512KB":  512 << 10,
+		"512KiB": 512 << 10,
+		"50MB":   50 << 20,
+		"50mb":   50 << 20,
+		"50 MB":  50 << 20,
+		"2G":     2 << 30,
+	} {
+		parsed, err := parseByteSize(size)
+		require.NoError(t, err, size)
+		require.Equal(t, expected, parsed, size)
 	}
 
-	expected := logtailing.EventPayload{
-		Error: logtailing.RedactedError{
-			Charge:       "ch_123",
-			Code:         "invlaid_argument",
-			DeclineCode:  "card_declined",
-			ErrorInsight: "make fewer errors",
-			Message:      "an error occurred",
-			Param:        "card",
-			Type:         "invalid_request",
-		},
-		Method:    "POST",
-		RequestID: "req_123",
-		URL:       "https://example.com",
+	for _, size := range []string{"", "MB", "0MB", "-1MB", "1.5MB", "50TB", "99999999999999999GB"} {
+		_, err := parseByteSize(size)
+		require.Error(t, err, size)
 	}
+}
 
-	// Ensures that we're testing/covering the entire payload in case
-	// any new fields are added
-	require.Equal(t, containsZeroValueStrings(payload), false)
-
-	sanitizePayload(&payload)
+func TestValidateArgs_RotateSize(t *testing.T) {
+	tailCmd := NewTailCmd(nil)
+	tailCmd.rotateSize = "50MB"
+	require.Error(t, tailCmd.validateArgs())
 
-	assert.Equal(t, expected, payload)
+	tailCmd.output = "logs.ndjson"
+	require.NoError(t, tailCmd.validateArgs())
+	require.NoError(t, tailCmd.convertArgs())
+	require.Equal(t, int64(50<<20), tailCmd.rotateSizeBytes)
 }
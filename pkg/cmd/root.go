@@ -54,6 +54,8 @@ var rootCmd = &cobra.Command{
 		"delete":    "http",
 		"trigger":   "webhooks",
 		"listen":    "webhooks",
+		"webhooks":  "webhooks",
+		"dev":       "webhooks",
 		"logs":      "stripe",
 		"status":    "stripe",
 		"resources": "resources",
@@ -288,6 +290,7 @@ func init() {
 	rootCmd.AddCommand(newCompletionCmd().cmd)
 	rootCmd.AddCommand(newConfigCmd().cmd)
 	rootCmd.AddCommand(newDaemonCmd(&Config).cmd)
+	rootCmd.AddCommand(newDevCmd().cmd)
 	rootCmd.AddCommand(newFeedbackCmd().cmd)
 	rootCmd.AddCommand(newFixturesCmd(&Config).Cmd)
 	rootCmd.AddCommand(newListenCmd().cmd)
@@ -305,6 +308,7 @@ func init() {
 	// rootCmd.AddCommand(newStatusCmd().cmd)
 	rootCmd.AddCommand(newTriggerCmd().cmd)
 	rootCmd.AddCommand(newVersionCmd().cmd)
+	rootCmd.AddCommand(newWebhooksCmd().cmd)
 	rootCmd.AddCommand(newWhoamiCmd().cmd)
 	rootCmd.AddCommand(newPostinstallCmd(&Config).cmd)
 	rootCmd.AddCommand(newProvisionCmd().cmd)
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stripe/stripe-cli/pkg/errorcategory"
+	"github.com/stripe/stripe-cli/pkg/proxy"
+	"github.com/stripe/stripe-cli/pkg/stripe"
+	"github.com/stripe/stripe-cli/pkg/validators"
+)
+
+type webhooksCmd struct {
+	cmd *cobra.Command
+}
+
+func newWebhooksCmd() *webhooksCmd {
+	wc := &webhooksCmd{}
+
+	wc.cmd = &cobra.Command{
+		Use:   "webhooks",
+		Args:  validators.NoArgs,
+		Short: "Sign and verify webhook payloads locally",
+		Long: `Utilities to help test how your application handles webhook signatures.
+When no secret is provided, the signing secret of your 'stripe listen'
+sessions is used.`,
+	}
+
+	wc.cmd.AddCommand(newWebhooksSignCmd().cmd)
+	wc.cmd.AddCommand(newWebhooksVerifyCmd().cmd)
+
+	return wc
+}
+
+// getListenSigningSecret returns the webhook signing secret used by
+// `stripe listen` for the current profile.
+func getListenSigningSecret(ctx context.Context, apiBaseURL string) (string, error) {
+	if err := stripe.ValidateAPIBaseURL(apiBaseURL); err != nil {
+		return "", err
+	}
+
+	deviceName, err := Config.Profile.GetDeviceName()
+	if err != nil {
+		return "", err
+	}
+
+	creds, err := Config.Profile.ResolveCredentials(false)
+	if err != nil {
+		return "", err
+	}
+
+	apiBase, err := url.Parse(apiBaseURL)
+	if err != nil {
+		return "", errorcategory.Errorf(errorcategory.UserInput, "failed to parse API base url: %v", err)
+	}
+
+	return proxy.GetSessionSecret(ctx, &stripe.Client{
+		BaseURL:     apiBase,
+		Credentials: creds,
+	}, deviceName)
+}
+
+// readPayload reads a webhook payload from a file, or from standard input when
+// path is empty or "-".
+func readPayload(cmd *cobra.Command, path string) ([]byte, error) {
+	if path == "" || path == "-" {
+		return io.ReadAll(cmd.InOrStdin())
+	}
+
+	payload, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errorcategory.Errorf(errorcategory.UserInput, "failed to read payload file: %v", err)
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stripe/stripe-cli/pkg/stripe"
+	"github.com/stripe/stripe-cli/pkg/validators"
+	"github.com/stripe/stripe-cli/pkg/webhooks"
+)
+
+type webhooksSignCmd struct {
+	cmd *cobra.Command
+
+	secret     string
+	timestamp  int64
+	apiBaseURL string
+}
+
+func newWebhooksSignCmd() *webhooksSignCmd {
+	sc := &webhooksSignCmd{}
+
+	sc.cmd = &cobra.Command{
+		Use:   "sign [payload file]",
+		Args:  validators.MaximumNArgs(1),
+		Short: "Compute the Stripe-Signature header of a payload",
+		Long: `Compute the Stripe-Signature header Stripe would send with a webhook payload,
+so your signature verification code can be exercised end-to-end. The payload
+is read from standard input when no file is given.`,
+		Example: `stripe webhooks sign event.json
+  stripe webhooks sign event.json --secret whsec_...
+  curl -H "Stripe-Signature: $(stripe webhooks sign event.json)" \
+    -d @event.json localhost:3000/webhooks`,
+		RunE: sc.runWebhooksSignCmd,
+	}
+
+	sc.cmd.Flags().StringVar(&sc.secret, "secret", "", "The webhook signing secret (whsec_...) to sign with (default: the signing secret of stripe listen)")
+	sc.cmd.Flags().Int64Var(&sc.timestamp, "timestamp", 0, "The Unix timestamp to sign the payload at (default: now)")
+
+	// Hidden configuration flags, useful for dev/debugging
+	sc.cmd.Flags().StringVar(&sc.apiBaseURL, "api-base", stripe.DefaultAPIBaseURL, "Sets the API base URL")
+	sc.cmd.Flags().MarkHidden("api-base") // #nosec G104
+
+	return sc
+}
+
+func (sc *webhooksSignCmd) runWebhooksSignCmd(cmd *cobra.Command, args []string) error {
+	path := ""
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	payload, err := readPayload(cmd, path)
+	if err != nil {
+		return err
+	}
+
+	secret := sc.secret
+	if secret == "" {
+		secret, err = getListenSigningSecret(cmd.Context(), sc.apiBaseURL)
+		if err != nil {
+			return err
+		}
+	}
+
+	timestamp := time.Now()
+	if sc.timestamp != 0 {
+		timestamp = time.Unix(sc.timestamp, 0)
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), webhooks.GenerateSignatureHeader(timestamp, payload, secret))
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhooksSign(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "event.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"id":"evt_123"}`), 0600))
+
+	output, err := executeCommand(newWebhooksCmd().cmd, "sign", path, "--secret", "whsec_test", "--timestamp", "1700000000")
+	require.NoError(t, err)
+	require.Equal(t, "t=1700000000,v1=5a607119c8de704a1cff10c500951d1446f61db411d9ced87bfd2e4ae2d167ba\n", output)
+}
+
+func TestWebhooksSign_Stdin(t *testing.T) {
+	wc := newWebhooksCmd()
+	wc.cmd.SetIn(strings.NewReader(`{"id":"evt_123"}`))
+
+	output, err := executeCommand(wc.cmd, "sign", "--secret", "whsec_test", "--timestamp", "1700000000")
+	require.NoError(t, err)
+	require.Equal(t, "t=1700000000,v1=5a607119c8de704a1cff10c500951d1446f61db411d9ced87bfd2e4ae2d167ba\n", output)
+}
+
+func TestWebhooksVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "event.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"id":"evt_123"}`), 0600))
+
+	signature := "t=1700000000,v1=5a607119c8de704a1cff10c500951d1446f61db411d9ced87bfd2e4ae2d167ba"
+
+	output, err := executeCommand(newWebhooksCmd().cmd, "verify", path, "--signature", signature, "--secret", "whsec_test", "--tolerance", "0")
+	require.NoError(t, err)
+	require.Contains(t, output, "the v1 signature matches the payload")
+
+	output, err = executeCommand(newWebhooksCmd().cmd, "verify", path, "--signature", signature, "--secret", "whsec_other", "--tolerance", "0")
+	require.ErrorContains(t, err, "invalid signature")
+	require.Contains(t, output, "Hint:")
+
+	_, err = executeCommand(newWebhooksCmd().cmd, "verify", path, "--signature", signature, "--secret", "whsec_test")
+	require.ErrorContains(t, err, "tolerance")
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stripe/stripe-cli/pkg/ansi"
+	"github.com/stripe/stripe-cli/pkg/errorcategory"
+	"github.com/stripe/stripe-cli/pkg/stripe"
+	"github.com/stripe/stripe-cli/pkg/validators"
+	"github.com/stripe/stripe-cli/pkg/webhooks"
+)
+
+type webhooksVerifyCmd struct {
+	cmd *cobra.Command
+
+	signature  string
+	secret     string
+	tolerance  time.Duration
+	apiBaseURL string
+}
+
+func newWebhooksVerifyCmd() *webhooksVerifyCmd {
+	vc := &webhooksVerifyCmd{}
+
+	vc.cmd = &cobra.Command{
+		Use:   "verify [payload file]",
+		Args:  validators.MaximumNArgs(1),
+		Short: "Check a Stripe-Signature header against a payload",
+		Long: `Check whether a Stripe-Signature header is a valid signature of a webhook
+payload, and explain why when it isn't. The payload must be the raw request
+body received by your endpoint, and is read from standard input when no file
+is given.`,
+		Example: `stripe webhooks verify event.json --signature "t=1700000000,v1=5a60..."
+  stripe webhooks verify event.json --signature "$SIG" --secret whsec_...`,
+		RunE: vc.runWebhooksVerifyCmd,
+	}
+
+	vc.cmd.Flags().StringVar(&vc.signature, "signature", "", "The value of the Stripe-Signature header")
+	vc.cmd.Flags().StringVar(&vc.secret, "secret", "", "The webhook signing secret (whsec_...) to verify with (default: the signing secret of stripe listen)")
+	vc.cmd.Flags().DurationVar(&vc.tolerance, "tolerance", webhooks.DefaultTolerance, "The maximum age of the signature, 0 to accept any timestamp")
+
+	// Hidden configuration flags, useful for dev/debugging
+	vc.cmd.Flags().StringVar(&vc.apiBaseURL, "api-base", stripe.DefaultAPIBaseURL, "Sets the API base URL")
+	vc.cmd.Flags().MarkHidden("api-base") // #nosec G104
+
+	vc.cmd.MarkFlagRequired("signature")
+
+	return vc
+}
+
+func (vc *webhooksVerifyCmd) runWebhooksVerifyCmd(cmd *cobra.Command, args []string) error {
+	path := ""
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	payload, err := readPayload(cmd, path)
+	if err != nil {
+		return err
+	}
+
+	secret := vc.secret
+	if secret == "" {
+		secret, err = getListenSigningSecret(cmd.Context(), vc.apiBaseURL)
+		if err != nil {
+			return err
+		}
+	}
+
+	verification, err := webhooks.VerifySignatureHeader(payload, vc.signature, secret, vc.tolerance, time.Now())
+
+	out := cmd.OutOrStdout()
+
+	if len(verification.Schemes) > 0 {
+		fmt.Fprintf(out, "Schemes:   %s\n", strings.Join(verification.Schemes, ", "))
+	}
+
+	if !verification.Timestamp.IsZero() {
+		fmt.Fprintf(out, "Timestamp: %s (signed %s ago, tolerance %s)\n", verification.Timestamp.UTC().Format(time.RFC3339), verification.Skew.Round(time.Second), vc.tolerance)
+	}
+
+	if err != nil {
+		if verification.Hint != "" {
+			fmt.Fprintf(out, "Hint:      %s\n", verification.Hint)
+		}
+
+		return errorcategory.Errorf(errorcategory.UserInput, "invalid signature: %v", err)
+	}
+
+	color := ansi.Color(out)
+	fmt.Fprintf(out, "%s    the %s signature matches the payload\n", color.Green("Valid:"), verification.MatchedScheme)
+
+	return nil
+}
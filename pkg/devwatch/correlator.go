@@ -0,0 +1,248 @@
+// Package devwatch correlates the API requests made to an account with the
+// webhook events they trigger, for `stripe dev watch`.
+package devwatch
+
+import (
+	"sort"
+	"time"
+
+	"github.com/stripe/stripe-cli/pkg/logtailing"
+	"github.com/stripe/stripe-cli/pkg/proxy"
+)
+
+// Timeline is an API request, the events it triggered and the responses of
+// local endpoints to them. Events that weren't triggered by an API request,
+// like those of automatic processes, get a timeline of their own with no
+// request.
+type Timeline struct {
+	// RequestID is the ID of the request, empty for events that weren't
+	// triggered by an API request
+	RequestID string `json:"request_id,omitempty"`
+
+	// Request is the request log of the request. It's nil when the request
+	// log wasn't received, e.g. when the request was filtered out.
+	Request *logtailing.EventPayload `json:"request,omitempty"`
+
+	// Events are sorted by creation time, then by the order they were
+	// received in
+	Events []*TimelineEvent `json:"events"`
+
+	started time.Time
+	updated time.Time
+}
+
+// TimelineEvent is an event of a Timeline.
+type TimelineEvent struct {
+	ID       string    `json:"id"`
+	Type     string    `json:"type"`
+	Account  string    `json:"account,omitempty"`
+	Created  int       `json:"created"`
+	Received time.Time `json:"received"`
+
+	// Event is the event as received from Stripe
+	Event proxy.StripeEvent `json:"-"`
+
+	Responses []TimelineResponse `json:"responses"`
+}
+
+// TimelineResponse is the response of a local endpoint to an event.
+type TimelineResponse struct {
+	URL       string    `json:"url"`
+	Method    string    `json:"method"`
+	Status    int       `json:"status"`
+	Attempt   int       `json:"attempt,omitempty"`
+	LatencyMS int64     `json:"latency_ms"`
+	Received  time.Time `json:"received"`
+}
+
+// Correlator groups request logs, events and endpoint responses into
+// timelines by request ID. A timeline is complete once nothing was added to
+// it for a while, since events are usually received after the request log of
+// the request that triggered them, but not always. It isn't safe for
+// concurrent use.
+type Correlator struct {
+	settle          time.Duration
+	responseTimeout time.Duration
+
+	timelines map[string]*Timeline
+}
+
+// NewCorrelator returns a Correlator whose timelines are complete once
+// nothing was added to them for settle. When responseTimeout is set, the
+// timelines of events that no endpoint responded to yet are held for up to
+// responseTimeout longer.
+func NewCorrelator(settle, responseTimeout time.Duration) *Correlator {
+	return &Correlator{
+		settle:          settle,
+		responseTimeout: responseTimeout,
+		timelines:       make(map[string]*Timeline),
+	}
+}
+
+// Len returns the number of timelines that aren't complete yet.
+func (c *Correlator) Len() int {
+	return len(c.timelines)
+}
+
+// AddRequest adds a request log to the timeline of its request.
+func (c *Correlator) AddRequest(payload logtailing.EventPayload, now time.Time) {
+	if payload.RequestID == "" {
+		return
+	}
+
+	timeline := c.timeline(payload.RequestID, payload.RequestID, now)
+	timeline.Request = &payload
+}
+
+// AddEvent adds an event to the timeline of the request that triggered it.
+func (c *Correlator) AddEvent(evt proxy.StripeEvent, now time.Time) {
+	c.event(evt, now)
+}
+
+// AddResponse adds the response of an endpoint to the timeline of the event
+// it responded to.
+func (c *Correlator) AddResponse(resp proxy.EndpointResponse, now time.Time) {
+	if resp.Event == nil || resp.Resp == nil {
+		return
+	}
+
+	// the event is added if it isn't in a timeline anymore, in which case
+	// the response is printed in a new one
+	event := c.event(*resp.Event, now)
+
+	response := TimelineResponse{
+		Status:    resp.Resp.StatusCode,
+		Attempt:   resp.Attempt,
+		LatencyMS: resp.Latency.Milliseconds(),
+		Received:  now,
+	}
+
+	if resp.Resp.Request != nil {
+		response.Method = resp.Resp.Request.Method
+		if resp.Resp.Request.URL != nil {
+			response.URL = resp.Resp.Request.URL.String()
+		}
+	}
+
+	event.Responses = append(event.Responses, response)
+}
+
+// Flush removes and returns the timelines that are complete at now, sorted
+// by the time they were started.
+func (c *Correlator) Flush(now time.Time) []*Timeline {
+	var complete []*Timeline
+
+	for key, timeline := range c.timelines {
+		if c.complete(timeline, now) {
+			complete = append(complete, timeline)
+			delete(c.timelines, key)
+		}
+	}
+
+	sortTimelines(complete)
+
+	return complete
+}
+
+// FlushAll removes and returns all of the timelines, complete or not, sorted
+// by the time they were started.
+func (c *Correlator) FlushAll() []*Timeline {
+	all := make([]*Timeline, 0, len(c.timelines))
+	for _, timeline := range c.timelines {
+		all = append(all, timeline)
+	}
+
+	c.timelines = make(map[string]*Timeline)
+
+	sortTimelines(all)
+
+	return all
+}
+
+func (c *Correlator) complete(timeline *Timeline, now time.Time) bool {
+	idle := now.Sub(timeline.updated)
+	if idle < c.settle {
+		return false
+	}
+
+	if c.responseTimeout <= 0 || idle >= c.settle+c.responseTimeout {
+		return true
+	}
+
+	for _, event := range timeline.Events {
+		if len(event.Responses) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c *Correlator) timeline(key, requestID string, now time.Time) *Timeline {
+	timeline, ok := c.timelines[key]
+	if !ok {
+		timeline = &Timeline{
+			RequestID: requestID,
+			Events:    []*TimelineEvent{},
+			started:   now,
+		}
+		c.timelines[key] = timeline
+	}
+
+	timeline.updated = now
+
+	return timeline
+}
+
+func (c *Correlator) event(evt proxy.StripeEvent, now time.Time) *TimelineEvent {
+	requestID := evt.Request.ID
+
+	// events that weren't triggered by a request are on their own
+	key := requestID
+	if key == "" {
+		key = evt.ID
+	}
+
+	timeline := c.timeline(key, requestID, now)
+
+	for _, event := range timeline.Events {
+		if event.ID == evt.ID {
+			return event
+		}
+	}
+
+	event := &TimelineEvent{
+		ID:        evt.ID,
+		Type:      evt.Type,
+		Account:   evt.Account,
+		Created:   evt.Created,
+		Received:  now,
+		Event:     evt,
+		Responses: []TimelineResponse{},
+	}
+
+	timeline.Events = append(timeline.Events, event)
+
+	sort.SliceStable(timeline.Events, func(i, j int) bool {
+		return timeline.Events[i].Created < timeline.Events[j].Created
+	})
+
+	return event
+}
+
+func sortTimelines(timelines []*Timeline) {
+	sort.Slice(timelines, func(i, j int) bool {
+		if !timelines[i].started.Equal(timelines[j].started) {
+			return timelines[i].started.Before(timelines[j].started)
+		}
+		return timelines[i].key() < timelines[j].key()
+	})
+}
+
+func (t *Timeline) key() string {
+	if t.RequestID == "" && len(t.Events) > 0 {
+		return t.Events[0].ID
+	}
+
+	return t.RequestID
+}
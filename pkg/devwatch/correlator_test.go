@@ -0,0 +1,114 @@
+package devwatch
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/logtailing"
+	"github.com/stripe/stripe-cli/pkg/proxy"
+)
+
+func testEvent(id, eventType, requestID string, created int) proxy.StripeEvent {
+	return proxy.StripeEvent{
+		ID:      id,
+		Type:    eventType,
+		Created: created,
+		Request: proxy.StripeRequest{ID: requestID},
+	}
+}
+
+func testResponse(evt proxy.StripeEvent, status int) proxy.EndpointResponse {
+	endpoint, _ := url.Parse("http://localhost:4242/webhook")
+
+	return proxy.EndpointResponse{
+		Event:   &evt,
+		Resp:    &http.Response{StatusCode: status, Request: &http.Request{Method: http.MethodPost, URL: endpoint}},
+		Attempt: 1,
+		Latency: 35 * time.Millisecond,
+	}
+}
+
+func TestCorrelator(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := NewCorrelator(5*time.Second, 0)
+
+	charge := testEvent("evt_2", "charge.succeeded", "req_1", 101)
+	paymentIntent := testEvent("evt_1", "payment_intent.created", "req_1", 100)
+
+	// events can be received before the request log of their request
+	c.AddEvent(charge, start)
+	c.AddRequest(logtailing.EventPayload{RequestID: "req_1", Method: "POST", URL: "/v1/payment_intents", Status: 200}, start.Add(time.Second))
+	c.AddEvent(paymentIntent, start.Add(time.Second))
+	c.AddResponse(testResponse(paymentIntent, 200), start.Add(2*time.Second))
+	c.AddEvent(testEvent("evt_3", "invoice.upcoming", "", 102), start.Add(3*time.Second))
+
+	require.Equal(t, 2, c.Len())
+	require.Empty(t, c.Flush(start.Add(5*time.Second)))
+
+	timelines := c.Flush(start.Add(7 * time.Second))
+	require.Len(t, timelines, 1)
+	require.Equal(t, "req_1", timelines[0].RequestID)
+	require.Equal(t, "/v1/payment_intents", timelines[0].Request.URL)
+	require.Len(t, timelines[0].Events, 2)
+	require.Equal(t, "evt_1", timelines[0].Events[0].ID)
+	require.Equal(t, "evt_2", timelines[0].Events[1].ID)
+	require.Equal(t, []TimelineResponse{{
+		URL:       "http://localhost:4242/webhook",
+		Method:    http.MethodPost,
+		Status:    200,
+		Attempt:   1,
+		LatencyMS: 35,
+		Received:  start.Add(2 * time.Second),
+	}}, timelines[0].Events[0].Responses)
+	require.Empty(t, timelines[0].Events[1].Responses)
+
+	timelines = c.Flush(start.Add(8 * time.Second))
+	require.Len(t, timelines, 1)
+	require.Empty(t, timelines[0].RequestID)
+	require.Nil(t, timelines[0].Request)
+	require.Equal(t, "evt_3", timelines[0].Events[0].ID)
+
+	require.Zero(t, c.Len())
+}
+
+func TestCorrelator_ResponseTimeout(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := NewCorrelator(5*time.Second, 30*time.Second)
+
+	evt := testEvent("evt_1", "payment_intent.created", "req_1", 100)
+	c.AddEvent(evt, start)
+
+	// the timeline waits for the endpoint to respond
+	require.Empty(t, c.Flush(start.Add(10*time.Second)))
+
+	c.AddResponse(testResponse(evt, 500), start.Add(20*time.Second))
+	require.Empty(t, c.Flush(start.Add(24*time.Second)))
+
+	timelines := c.Flush(start.Add(25 * time.Second))
+	require.Len(t, timelines, 1)
+	require.Equal(t, 500, timelines[0].Events[0].Responses[0].Status)
+
+	// but not forever
+	c.AddEvent(testEvent("evt_2", "charge.failed", "req_2", 101), start)
+	require.Empty(t, c.Flush(start.Add(34*time.Second)))
+	require.Len(t, c.Flush(start.Add(35*time.Second)), 1)
+}
+
+func TestCorrelator_FlushAll(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := NewCorrelator(5*time.Second, 0)
+
+	c.AddRequest(logtailing.EventPayload{RequestID: "req_2"}, start.Add(time.Second))
+	c.AddRequest(logtailing.EventPayload{RequestID: "req_1"}, start)
+	c.AddRequest(logtailing.EventPayload{}, start)
+
+	timelines := c.FlushAll()
+	require.Len(t, timelines, 2)
+	require.Equal(t, "req_1", timelines[0].RequestID)
+	require.Equal(t, "req_2", timelines[1].RequestID)
+	require.Zero(t, c.Len())
+}
@@ -0,0 +1,445 @@
+// Package expr evaluates conditions on JSON documents, like the --filter
+// expressions of `stripe listen` and the --where expressions of
+// `stripe logs tail`.
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/stripe/stripe-cli/pkg/errorcategory"
+)
+
+// Expression is a condition on the fields of a JSON document. Fields are
+// gjson paths compared to values, and conditions can be combined with &&, ||
+// and !, e.g.:
+//
+//	data.object.amount > 10000 && data.object.metadata.tenant == "acme"
+//	status >= 400 && (url ~ "/v1/payment_intents" || error.code != card_declined)
+//	!livemode
+//
+// ~ and !~ match a regular expression. Unquoted values that aren't numbers,
+// booleans or null are read as strings. A bare field matches when it exists
+// and isn't null, false, an empty string or zero.
+type Expression struct {
+	expr  string
+	root  node
+	paths []string
+}
+
+// Parse parses an expression.
+func Parse(expr string) (*Expression, error) {
+	p := &parser{expr: expr}
+
+	if err := p.tokenize(); err != nil {
+		return nil, err
+	}
+
+	if len(p.tokens) == 0 {
+		return nil, errorcategory.New(errorcategory.UserInput, "the expression cannot be empty")
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.tokens) {
+		return nil, p.errorf("unexpected %q at position %d", p.tokens[p.pos].text, p.tokens[p.pos].pos+1)
+	}
+
+	return &Expression{expr: expr, root: root, paths: p.paths}, nil
+}
+
+// String returns the expression it was parsed from.
+func (e *Expression) String() string {
+	return e.expr
+}
+
+// Paths returns the paths of the fields the expression refers to, in order.
+func (e *Expression) Paths() []string {
+	return e.paths
+}
+
+// Match reports whether the JSON document satisfies the expression.
+func (e *Expression) Match(payload string) bool {
+	return e.root.eval(payload)
+}
+
+//
+// Evaluation
+//
+
+type node interface {
+	eval(payload string) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) eval(payload string) bool { return n.left.eval(payload) && n.right.eval(payload) }
+
+type orNode struct{ left, right node }
+
+func (n orNode) eval(payload string) bool { return n.left.eval(payload) || n.right.eval(payload) }
+
+type notNode struct{ node node }
+
+func (n notNode) eval(payload string) bool { return !n.node.eval(payload) }
+
+type comparison struct {
+	path     string
+	operator string
+	value    gjson.Result
+	pattern  *regexp.Regexp
+}
+
+func (n comparison) eval(payload string) bool {
+	result := gjson.Get(payload, n.path)
+
+	switch n.operator {
+	case "":
+		return truthy(result)
+	case "==":
+		return valuesEqual(result, n.value)
+	case "!=":
+		return !valuesEqual(result, n.value)
+	case "~":
+		return n.pattern.MatchString(result.String())
+	case "!~":
+		return !n.pattern.MatchString(result.String())
+	}
+
+	if result.Type != gjson.Number {
+		return false
+	}
+
+	switch n.operator {
+	case ">":
+		return result.Num > n.value.Num
+	case ">=":
+		return result.Num >= n.value.Num
+	case "<":
+		return result.Num < n.value.Num
+	case "<=":
+		return result.Num <= n.value.Num
+	}
+
+	return false
+}
+
+func truthy(result gjson.Result) bool {
+	switch result.Type {
+	case gjson.Null, gjson.False:
+		return false
+	case gjson.String:
+		return result.Str != ""
+	case gjson.Number:
+		return result.Num != 0
+	}
+
+	return result.Exists()
+}
+
+// valuesEqual compares a value read from the document with the value of a
+// comparison. Null is equal to missing values.
+func valuesEqual(result gjson.Result, value gjson.Result) bool {
+	switch value.Type {
+	case gjson.Null:
+		return !result.Exists() || result.Type == gjson.Null
+	case gjson.True, gjson.False:
+		return result.Type == value.Type
+	case gjson.Number:
+		return result.Type == gjson.Number && result.Num == value.Num
+	default:
+		return result.Type == gjson.String && result.Str == value.Str
+	}
+}
+
+//
+// Parsing
+//
+
+type tokenKind int
+
+const (
+	fieldToken tokenKind = iota
+	stringToken
+	numberToken
+	operatorToken
+	andToken
+	orToken
+	notToken
+	openParenToken
+	closeParenToken
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// operators are the comparison operators. Two-character operators come first
+// so that ">=" isn't read as ">".
+var operators = []string{"==", "!=", ">=", "<=", "!~", ">", "<", "~"}
+
+type parser struct {
+	expr   string
+	tokens []token
+	pos    int
+	paths  []string
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return errorcategory.Errorf(errorcategory.UserInput, "invalid expression %q: %s", p.expr, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) tokenize() error {
+	expr := p.expr
+
+	for i := 0; i < len(expr); {
+		c := expr[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(':
+			p.tokens = append(p.tokens, token{kind: openParenToken, text: "(", pos: i})
+			i++
+		case c == ')':
+			p.tokens = append(p.tokens, token{kind: closeParenToken, text: ")", pos: i})
+			i++
+		case strings.HasPrefix(expr[i:], "&&"):
+			p.tokens = append(p.tokens, token{kind: andToken, text: "&&", pos: i})
+			i += 2
+		case strings.HasPrefix(expr[i:], "||"):
+			p.tokens = append(p.tokens, token{kind: orToken, text: "||", pos: i})
+			i += 2
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(expr) && expr[end] != c {
+				if expr[end] == '\\' {
+					end++
+				}
+				end++
+			}
+
+			if end >= len(expr) {
+				return p.errorf("unterminated string")
+			}
+
+			str := expr[i+1 : end]
+			if c == '"' {
+				unquoted, err := strconv.Unquote(expr[i : end+1])
+				if err != nil {
+					return p.errorf("invalid string %s", expr[i:end+1])
+				}
+				str = unquoted
+			}
+
+			p.tokens = append(p.tokens, token{kind: stringToken, text: str, pos: i})
+			i = end + 1
+		default:
+			if operator := operatorAt(expr[i:]); operator != "" {
+				p.tokens = append(p.tokens, token{kind: operatorToken, text: operator, pos: i})
+				i += len(operator)
+				continue
+			}
+
+			if c == '!' {
+				p.tokens = append(p.tokens, token{kind: notToken, text: "!", pos: i})
+				i++
+				continue
+			}
+
+			end := i
+			for end < len(expr) && isWordChar(rune(expr[end])) {
+				// escaped characters, like the dot of metadata.order\.id,
+				// are part of gjson paths
+				if expr[end] == '\\' && end+1 < len(expr) {
+					end++
+				}
+				end++
+			}
+
+			if end == i {
+				return p.errorf("unexpected %q at position %d", string(c), i+1)
+			}
+
+			word := expr[i:end]
+			kind := fieldToken
+			if _, err := strconv.ParseFloat(word, 64); err == nil {
+				kind = numberToken
+			}
+
+			p.tokens = append(p.tokens, token{kind: kind, text: word, pos: i})
+			i = end
+		}
+	}
+
+	return nil
+}
+
+func operatorAt(s string) string {
+	for _, operator := range operators {
+		if strings.HasPrefix(s, operator) {
+			return operator
+		}
+	}
+
+	return ""
+}
+
+func isWordChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune(`_.-#*\`, r)
+}
+
+func (p *parser) peek() *token {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for t := p.peek(); t != nil && t.kind == orToken; t = p.peek() {
+		p.pos++
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = orNode{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for t := p.peek(); t != nil && t.kind == andToken; t = p.peek() {
+		p.pos++
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		left = andNode{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, p.errorf("unexpected end of expression")
+	}
+
+	switch t.kind {
+	case notToken:
+		p.pos++
+
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return notNode{n}, nil
+	case openParenToken:
+		p.pos++
+
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if closing := p.peek(); closing == nil || closing.kind != closeParenToken {
+			return nil, p.errorf("missing )")
+		}
+		p.pos++
+
+		return n, nil
+	case fieldToken:
+		return p.parseComparison()
+	}
+
+	return nil, p.errorf("unexpected %q at position %d, expected a field", t.text, t.pos+1)
+}
+
+func (p *parser) parseComparison() (node, error) {
+	field := p.tokens[p.pos]
+	p.pos++
+
+	p.paths = append(p.paths, field.text)
+
+	n := comparison{path: field.text}
+
+	operator := p.peek()
+	if operator == nil || operator.kind != operatorToken {
+		return n, nil
+	}
+	p.pos++
+
+	n.operator = operator.text
+
+	value := p.peek()
+	if value == nil || (value.kind != stringToken && value.kind != numberToken && value.kind != fieldToken) {
+		return nil, p.errorf("missing value after %s", operator.text)
+	}
+	p.pos++
+
+	switch {
+	case value.kind == stringToken:
+		n.value = gjson.Result{Type: gjson.String, Str: value.text}
+	case value.kind == numberToken:
+		num, _ := strconv.ParseFloat(value.text, 64)
+		n.value = gjson.Result{Type: gjson.Number, Num: num}
+	case value.text == "true":
+		n.value = gjson.Result{Type: gjson.True}
+	case value.text == "false":
+		n.value = gjson.Result{Type: gjson.False}
+	case value.text == "null":
+		n.value = gjson.Result{Type: gjson.Null}
+	default:
+		// unquoted words are read as strings, like card_declined
+		n.value = gjson.Result{Type: gjson.String, Str: value.text}
+	}
+
+	switch n.operator {
+	case "~", "!~":
+		if n.value.Type != gjson.String {
+			return nil, p.errorf("%s can only be used with a string", n.operator)
+		}
+
+		pattern, err := regexp.Compile(n.value.Str)
+		if err != nil {
+			return nil, p.errorf("invalid regular expression %q: %v", n.value.Str, err)
+		}
+
+		n.pattern = pattern
+	case ">", ">=", "<", "<=":
+		if n.value.Type != gjson.Number {
+			return nil, p.errorf("%s can only be used with numbers", n.operator)
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,84 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testPayload = `{
+  "id": "evt_123",
+  "data": {
+    "object": {
+      "amount": 20000,
+      "amount_refunded": 0,
+      "currency": "usd",
+      "description": "",
+      "livemode": false,
+      "customer": null,
+      "items": [{"price": "price_1"}],
+      "metadata": {"tenant": "acme", "note": "a == b", "order.id": "123"}
+    }
+  }
+}`
+
+func TestExpression_Match(t *testing.T) {
+	for expr, expected := range map[string]bool{
+		`data.object.metadata.tenant == "acme"`:                     true,
+		`data.object.metadata.tenant == 'acme'`:                     true,
+		`data.object.metadata.tenant == acme`:                       true,
+		`data.object.metadata.tenant != acme`:                       false,
+		`data.object.metadata.note == "a == b"`:                     true,
+		`data.object.metadata.order\.id == "123"`:                   true,
+		`data.object.metadata.order\.id == 123`:                     false,
+		`data.object.items.#.price ~ "price_1"`:                     true,
+		`data.object.amount > 10000 && data.object.currency == usd`: true,
+		`data.object.amount < 10000 || data.object.currency != usd`: false,
+		`!(data.object.amount <= 20000)`:                            false,
+		`data.object.amount >= -1`:                                  true,
+		`data.object.currency !~ "^eu"`:                             true,
+		`data.object.livemode == false`:                             true,
+		`data.object.customer == null`:                              true,
+		`data.object.missing == null`:                               true,
+		`data.object.missing > 1`:                                   false,
+
+		// bare fields are falsy when missing, null, false, empty or zero
+		`data.object.metadata`:        true,
+		`data.object.amount`:          true,
+		`data.object.amount_refunded`: false,
+		`data.object.description`:     false,
+		`data.object.livemode`:        false,
+		`data.object.customer`:        false,
+		`data.object.missing`:         false,
+	} {
+		expression, err := Parse(expr)
+		require.NoError(t, err, expr)
+		require.Equal(t, expected, expression.Match(testPayload), expr)
+	}
+}
+
+func TestExpression_Paths(t *testing.T) {
+	expression, err := Parse(`status >= 400 && (error.code == card_declined || !livemode)`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"status", "error.code", "livemode"}, expression.Paths())
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		``,
+		`   `,
+		`== 1`,
+		`amount >`,
+		`amount > "ten"`,
+		`url ~ "["`,
+		`url ~ 10`,
+		`(amount == 1`,
+		`amount == 1)`,
+		`amount == 1 &&`,
+		`tenant == "acme`,
+		`amount $ 1`,
+	} {
+		_, err := Parse(expr)
+		require.Error(t, err, expr)
+	}
+}
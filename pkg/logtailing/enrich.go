@@ -0,0 +1,204 @@
+package logtailing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/stripe/stripe-cli/pkg/errorcategory"
+	"github.com/stripe/stripe-cli/pkg/stripe"
+)
+
+const (
+	requestLogsPath = "/v1/request_logs/"
+
+	// defaultExpandConcurrency is the number of request logs fetched at the
+	// same time, to stay well under the API's rate limits
+	defaultExpandConcurrency = 4
+
+	// defaultExpandCacheSize is the number of request logs kept in memory
+	defaultExpandCacheSize = 1000
+
+	// request logs can be tailed before the request log API returns them, so
+	// fetches are retried a few times when they aren't found
+	expandAttempts   = 3
+	expandRetryDelay = time.Second
+)
+
+// RequestDetail is the detail of a request fetched from the request log API
+// by `stripe logs tail --expand`.
+type RequestDetail struct {
+	RequestID      string `json:"request_id"`
+	APIVersion     string `json:"api_version,omitempty"`
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+
+	// RequestParams are the parameters the request was sent with
+	RequestParams json.RawMessage `json:"request_params,omitempty"`
+
+	// ObjectID is the ID of the object returned by the request, like the
+	// payment intent a POST /v1/payment_intents created
+	ObjectID string `json:"object_id,omitempty"`
+}
+
+// Enricher fetches the detail of tailed requests from the request log API. It
+// fetches a few requests at a time and caches the requests it fetched.
+type Enricher struct {
+	client stripe.RequestPerformer
+
+	sem      chan struct{}
+	inflight singleflight.Group
+
+	mu        sync.Mutex
+	cache     map[string]*RequestDetail
+	order     []string
+	cacheSize int
+
+	retryDelay time.Duration
+}
+
+// NewEnricher returns an Enricher fetching up to concurrency requests at the
+// same time, and caching up to cacheSize requests.
+func NewEnricher(client stripe.RequestPerformer, concurrency, cacheSize int) *Enricher {
+	if concurrency <= 0 {
+		concurrency = defaultExpandConcurrency
+	}
+
+	if cacheSize <= 0 {
+		cacheSize = defaultExpandCacheSize
+	}
+
+	return &Enricher{
+		client:     client,
+		sem:        make(chan struct{}, concurrency),
+		cache:      make(map[string]*RequestDetail),
+		cacheSize:  cacheSize,
+		retryDelay: expandRetryDelay,
+	}
+}
+
+// Fetch returns the detail of the request with ID requestID.
+func (e *Enricher) Fetch(ctx context.Context, requestID string) (*RequestDetail, error) {
+	if detail := e.cached(requestID); detail != nil {
+		return detail, nil
+	}
+
+	// requests are usually tailed once, but a detail being fetched doesn't
+	// need to be fetched twice
+	detail, err, _ := e.inflight.Do(requestID, func() (interface{}, error) {
+		detail, err := e.fetchWithRetries(ctx, requestID)
+		if err != nil {
+			return nil, err
+		}
+
+		e.store(detail)
+
+		return detail, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return detail.(*RequestDetail), nil
+}
+
+func (e *Enricher) fetchWithRetries(ctx context.Context, requestID string) (*RequestDetail, error) {
+	select {
+	case e.sem <- struct{}{}:
+		defer func() { <-e.sem }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	for attempt := 1; ; attempt++ {
+		detail, found, err := e.fetch(ctx, requestID)
+		if err != nil || found {
+			return detail, err
+		}
+
+		if attempt == expandAttempts {
+			return nil, errorcategory.Errorf(errorcategory.API, "request log %s not found", requestID)
+		}
+
+		select {
+		case <-time.After(e.retryDelay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (e *Enricher) fetch(ctx context.Context, requestID string) (*RequestDetail, bool, error) {
+	resp, err := e.client.PerformRequest(ctx, http.MethodGet, requestLogsPath+url.PathEscape(requestID), "", nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+
+	if resp.StatusCode >= 300 {
+		return nil, false, errorcategory.Errorf(errorcategory.API, "fetching request log %s failed with status %d", requestID, resp.StatusCode)
+	}
+
+	if !gjson.ValidBytes(body) {
+		return nil, false, errorcategory.Errorf(errorcategory.API, "received malformed request log %s", requestID)
+	}
+
+	return parseRequestDetail(requestID, body), true, nil
+}
+
+func parseRequestDetail(requestID string, body []byte) *RequestDetail {
+	requestLog := gjson.ParseBytes(body)
+
+	detail := &RequestDetail{
+		RequestID:      requestID,
+		APIVersion:     requestLog.Get("api_version").String(),
+		IdempotencyKey: requestLog.Get("idempotency_key").String(),
+		ObjectID:       requestLog.Get("response_body.id").String(),
+	}
+
+	if params := requestLog.Get("request_body"); params.Exists() && params.Type != gjson.Null {
+		detail.RequestParams = json.RawMessage(params.Raw)
+	}
+
+	return detail
+}
+
+func (e *Enricher) cached(requestID string) *RequestDetail {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.cache[requestID]
+}
+
+// store caches detail, evicting the oldest request once the cache is full.
+func (e *Enricher) store(detail *RequestDetail) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.cache[detail.RequestID]; ok {
+		return
+	}
+
+	if len(e.order) >= e.cacheSize {
+		delete(e.cache, e.order[0])
+		e.order = e.order[1:]
+	}
+
+	e.cache[detail.RequestID] = detail
+	e.order = append(e.order, detail.RequestID)
+}
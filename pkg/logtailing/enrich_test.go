@@ -0,0 +1,183 @@
+package logtailing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/stripe"
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+const requestLogResponse = `{
+	"id": "req_123",
+	"api_version": "2024-06-20",
+	"idempotency_key": "key_123",
+	"request_body": {"amount": "2000", "currency": "usd"},
+	"response_body": {"id": "pi_123", "object": "payment_intent"}
+}`
+
+func newEnricherTestClient(t *testing.T, handler http.HandlerFunc) stripe.RequestPerformer {
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+
+	baseURL, _ := url.Parse(ts.URL)
+
+	return &stripe.Client{Credentials: stripe.NewAPIKeyCredentials("sk_test_123"), BaseURL: baseURL}
+}
+
+func TestEnricher_Fetch(t *testing.T) {
+	var nRequests atomic.Int32
+	client := newEnricherTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		nRequests.Add(1)
+		require.Equal(t, http.MethodGet, r.Method)
+		require.Equal(t, "/v1/request_logs/req_123", r.URL.Path)
+		w.Write([]byte(requestLogResponse))
+	})
+
+	enricher := NewEnricher(client, 0, 0)
+
+	detail, err := enricher.Fetch(context.Background(), "req_123")
+	require.NoError(t, err)
+	require.Equal(t, "req_123", detail.RequestID)
+	require.Equal(t, "2024-06-20", detail.APIVersion)
+	require.Equal(t, "key_123", detail.IdempotencyKey)
+	require.Equal(t, "pi_123", detail.ObjectID)
+	require.JSONEq(t, `{"amount": "2000", "currency": "usd"}`, string(detail.RequestParams))
+
+	// the detail is cached
+	_, err = enricher.Fetch(context.Background(), "req_123")
+	require.NoError(t, err)
+	require.Equal(t, int32(1), nRequests.Load())
+}
+
+func TestEnricher_RetriesRequestsNotFoundYet(t *testing.T) {
+	var nRequests atomic.Int32
+	client := newEnricherTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if nRequests.Add(1) == 1 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(requestLogResponse))
+	})
+
+	enricher := NewEnricher(client, 0, 0)
+	enricher.retryDelay = time.Millisecond
+
+	detail, err := enricher.Fetch(context.Background(), "req_123")
+	require.NoError(t, err)
+	require.Equal(t, "pi_123", detail.ObjectID)
+	require.Equal(t, int32(2), nRequests.Load())
+
+	notFound := NewEnricher(newEnricherTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}), 0, 0)
+	notFound.retryDelay = time.Millisecond
+
+	_, err = notFound.Fetch(context.Background(), "req_123")
+	require.ErrorContains(t, err, "not found")
+}
+
+func TestEnricher_Errors(t *testing.T) {
+	enricher := NewEnricher(newEnricherTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}), 0, 0)
+
+	_, err := enricher.Fetch(context.Background(), "req_123")
+	require.ErrorContains(t, err, "status 403")
+
+	enricher = NewEnricher(newEnricherTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not json"))
+	}), 0, 0)
+
+	_, err = enricher.Fetch(context.Background(), "req_123")
+	require.ErrorContains(t, err, "malformed")
+}
+
+func TestEnricher_ConcurrencyLimit(t *testing.T) {
+	var mu sync.Mutex
+	inflight, maxInflight := 0, 0
+
+	client := newEnricherTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inflight++
+		if inflight > maxInflight {
+			maxInflight = inflight
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		inflight--
+		mu.Unlock()
+
+		w.Write([]byte(`{}`))
+	})
+
+	enricher := NewEnricher(client, 2, 0)
+
+	var wg sync.WaitGroup
+	for _, id := range []string{"req_1", "req_2", "req_3", "req_4", "req_5", "req_6"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			_, err := enricher.Fetch(context.Background(), id)
+			require.NoError(t, err)
+		}(id)
+	}
+	wg.Wait()
+
+	require.Equal(t, 2, maxInflight)
+}
+
+func TestEnricher_CacheEviction(t *testing.T) {
+	var nRequests atomic.Int32
+	enricher := NewEnricher(newEnricherTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		nRequests.Add(1)
+		w.Write([]byte(`{}`))
+	}), 0, 2)
+
+	for _, id := range []string{"req_1", "req_2", "req_3", "req_1"} {
+		_, err := enricher.Fetch(context.Background(), id)
+		require.NoError(t, err)
+	}
+
+	// req_1 was evicted by req_3
+	require.Equal(t, int32(4), nRequests.Load())
+	require.Len(t, enricher.cache, 2)
+}
+
+func TestTailer_Expand(t *testing.T) {
+	client := newEnricherTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(requestLogResponse))
+	})
+
+	outCh := make(chan websocket.IElement, 1)
+	tailer := New(&Config{Client: client, OutCh: outCh, Expand: true})
+
+	payload := EventPayload{RequestID: "req_123"}
+	marshaled := tailer.expand(context.Background(), &payload, `{"request_id":"req_123","status":200}`)
+
+	require.NotNil(t, payload.Detail)
+	require.Equal(t, "pi_123", payload.Detail.ObjectID)
+
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(marshaled), &fields))
+	require.Equal(t, float64(200), fields["status"])
+	require.Equal(t, "pi_123", fields["detail"].(map[string]interface{})["object_id"])
+
+	// the payload's detail is a copy of the cached one
+	payload.Detail.ObjectID = "changed"
+	detail, err := tailer.enricher.Fetch(context.Background(), "req_123")
+	require.NoError(t, err)
+	require.Equal(t, "pi_123", detail.ObjectID)
+}
@@ -0,0 +1,198 @@
+package logtailing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stripe/stripe-cli/pkg/version"
+)
+
+// harArchive is an HTTP Archive (HAR 1.2), the format browsers export the
+// requests of a page to. Request logs only describe requests partially, so
+// entries use -1 for the sizes they don't know and 0 for timings, and carry
+// the request ID, error and detail of requests in custom _-prefixed fields.
+type harArchive struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+
+	RequestID string         `json:"_requestId,omitempty"`
+	Livemode  bool           `json:"_livemode"`
+	Error     *RedactedError `json:"_error,omitempty"`
+	Detail    *RequestDetail `json:"_detail,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+func newHARArchive() *harArchive {
+	return &harArchive{
+		Log: harLog{
+			Version: "1.2",
+			Creator: harCreator{Name: "stripe-cli", Version: version.Version},
+			Entries: []harEntry{},
+		},
+	}
+}
+
+func newHAREntry(baseURL string, payload EventPayload) harEntry {
+	requestURL := strings.TrimSuffix(baseURL, "/") + payload.URL
+
+	entry := harEntry{
+		StartedDateTime: time.Unix(int64(payload.CreatedAt), 0).UTC().Format(time.RFC3339),
+		Time:            0,
+		Request: harRequest{
+			Method:      payload.Method,
+			URL:         requestURL,
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []harNameValue{},
+			Headers:     []harNameValue{},
+			QueryString: harQueryString(requestURL),
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Response: harResponse{
+			Status:      payload.Status,
+			StatusText:  http.StatusText(payload.Status),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []harNameValue{},
+			Headers:     []harNameValue{},
+			Content:     harContent{Size: -1, MimeType: "application/json"},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		RequestID: payload.RequestID,
+		Livemode:  payload.Livemode,
+		Detail:    payload.Detail,
+	}
+
+	if payload.RequestID != "" {
+		entry.Response.Headers = append(entry.Response.Headers, harNameValue{Name: "Request-Id", Value: payload.RequestID})
+	}
+
+	if payload.Error != (RedactedError{}) {
+		redacted := payload.Error
+		entry.Error = &redacted
+
+		if body, err := json.Marshal(map[string]RedactedError{"error": redacted}); err == nil {
+			entry.Response.Content.Text = string(body)
+		}
+	}
+
+	if detail := payload.Detail; detail != nil {
+		if detail.APIVersion != "" {
+			entry.Request.Headers = append(entry.Request.Headers, harNameValue{Name: "Stripe-Version", Value: detail.APIVersion})
+		}
+
+		if detail.IdempotencyKey != "" {
+			entry.Request.Headers = append(entry.Request.Headers, harNameValue{Name: "Idempotency-Key", Value: detail.IdempotencyKey})
+		}
+
+		if len(detail.RequestParams) > 0 && payload.Method != http.MethodGet {
+			entry.Request.PostData = harRequestParams(detail.RequestParams)
+		}
+	}
+
+	return entry
+}
+
+func harQueryString(requestURL string) []harNameValue {
+	query := []harNameValue{}
+
+	parsed, err := url.Parse(requestURL)
+	if err != nil {
+		return query
+	}
+
+	values := parsed.Query()
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range values[name] {
+			query = append(query, harNameValue{Name: name, Value: value})
+		}
+	}
+
+	return query
+}
+
+// harRequestParams returns the body of a request from its parameters, which
+// the request log API returns as JSON, or form-encoded like they were sent.
+func harRequestParams(params json.RawMessage) *harPostData {
+	var encoded string
+	if err := json.Unmarshal(params, &encoded); err == nil {
+		return &harPostData{MimeType: "application/x-www-form-urlencoded", Text: encoded}
+	}
+
+	return &harPostData{MimeType: "application/json", Text: string(params)}
+}
@@ -0,0 +1,50 @@
+package logtailing
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func TestNewHAREntry(t *testing.T) {
+	entry := newHAREntry("https://api.stripe.com/", EventPayload{
+		CreatedAt: 1700000000,
+		Method:    "POST",
+		URL:       "/v1/payment_intents/pi_123/confirm?expand[]=customer&a=1",
+		RequestID: "req_123",
+		Status:    402,
+		Error:     RedactedError{Code: "card_declined", DeclineCode: "insufficient_funds"},
+		Detail: &RequestDetail{
+			RequestID:      "req_123",
+			APIVersion:     "2024-06-20",
+			IdempotencyKey: "key_123",
+			RequestParams:  json.RawMessage(`"payment_method=pm_card_visa"`),
+		},
+	})
+
+	require.Equal(t, "2023-11-14T22:13:20Z", entry.StartedDateTime)
+	require.Equal(t, "https://api.stripe.com/v1/payment_intents/pi_123/confirm?expand[]=customer&a=1", entry.Request.URL)
+	require.Equal(t, []harNameValue{{Name: "a", Value: "1"}, {Name: "expand[]", Value: "customer"}}, entry.Request.QueryString)
+	require.Equal(t, []harNameValue{{Name: "Stripe-Version", Value: "2024-06-20"}, {Name: "Idempotency-Key", Value: "key_123"}}, entry.Request.Headers)
+	require.Equal(t, &harPostData{MimeType: "application/x-www-form-urlencoded", Text: "payment_method=pm_card_visa"}, entry.Request.PostData)
+
+	require.Equal(t, 402, entry.Response.Status)
+	require.Equal(t, "Payment Required", entry.Response.StatusText)
+	require.Equal(t, []harNameValue{{Name: "Request-Id", Value: "req_123"}}, entry.Response.Headers)
+	require.Equal(t, "card_declined", gjson.Get(entry.Response.Content.Text, "error.code").String())
+	require.Equal(t, "insufficient_funds", gjson.Get(entry.Response.Content.Text, "error.decline_code").String())
+	require.Equal(t, "card_declined", entry.Error.Code)
+}
+
+func TestNewHAREntry_WithoutDetail(t *testing.T) {
+	entry := newHAREntry("https://api.stripe.com", EventPayload{Method: "GET", URL: "/v1/customers", Status: 200})
+
+	require.Empty(t, entry.Request.Headers)
+	require.Empty(t, entry.Request.QueryString)
+	require.Nil(t, entry.Request.PostData)
+	require.Empty(t, entry.Response.Content.Text)
+	require.Nil(t, entry.Error)
+	require.Nil(t, entry.Detail)
+}
@@ -0,0 +1,52 @@
+package logtailing
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/stripe/stripe-cli/pkg/metrics"
+)
+
+// tailerMetrics are the metrics reported by the tailer. A nil *tailerMetrics
+// reports nothing.
+type tailerMetrics struct {
+	requestLogsReceived *metrics.Counter
+	reconnects          *metrics.Counter
+	lastEvent           *metrics.Gauge
+}
+
+func newTailerMetrics(registry *metrics.Registry) *tailerMetrics {
+	return &tailerMetrics{
+		requestLogsReceived: registry.NewCounter(
+			"stripe_logs_tail_request_logs_received",
+			"Number of API request logs received from Stripe.",
+			"status",
+		),
+		reconnects: registry.NewCounter(
+			"stripe_logs_tail_websocket_reconnects",
+			"Number of times the websocket connection to Stripe was re-established.",
+			"reason",
+		),
+		lastEvent: registry.NewGauge(
+			"stripe_logs_tail_last_event_timestamp_seconds",
+			"Unix time at which the last request log was received from Stripe.",
+		),
+	}
+}
+
+func (m *tailerMetrics) requestLogReceived(status int) {
+	if m == nil {
+		return
+	}
+
+	m.requestLogsReceived.Inc(strconv.Itoa(status))
+	m.lastEvent.Set(float64(time.Now().UnixNano()) / float64(time.Second))
+}
+
+func (m *tailerMetrics) reconnected(reason string) {
+	if m == nil {
+		return
+	}
+
+	m.reconnects.Inc(reason)
+}
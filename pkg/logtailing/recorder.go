@@ -0,0 +1,276 @@
+package logtailing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/stripe/stripe-cli/pkg/errorcategory"
+)
+
+// RecorderConfig is the configuration of a Recorder
+type RecorderConfig struct {
+	// Path is the file request logs are written to. Request logs are written
+	// as a HAR archive when it ends with .har, and as newline delimited JSON
+	// otherwise.
+	Path string
+
+	// RotateSize, when set, is the size in bytes above which the file at
+	// Path is moved aside, to logs.1.ndjson, logs.2.ndjson and so on, and a
+	// new file is started. Rotated files are kept.
+	RotateSize int64
+
+	// BaseURL is the URL of the API request paths are relative to in HAR
+	// archives (default: https://api.stripe.com)
+	BaseURL string
+}
+
+// Recorder persists request logs to disk, for `stripe logs tail --output`.
+// It's safe for concurrent use.
+type Recorder struct {
+	cfg *RecorderConfig
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	closed bool
+
+	// har is set when recording a HAR archive, whose file holds harEntries
+	// entries. size is then the offset at which the next entry is written,
+	// over the end of the archive.
+	har        bool
+	harEntries int
+}
+
+// harFooter ends the entries of a HAR archive, and the archive itself
+const harFooter = "\n    ]\n  }\n}\n"
+
+// NewRecorder opens the file at cfg.Path. Newline delimited JSON files are
+// appended to, while a new HAR archive is started, an existing one being
+// moved aside like rotated files.
+func NewRecorder(cfg *RecorderConfig) (*Recorder, error) {
+	if cfg.RotateSize < 0 {
+		return nil, errorcategory.New(errorcategory.UserInput, "the rotation size cannot be negative")
+	}
+
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.stripe.com"
+	}
+
+	r := &Recorder{cfg: cfg}
+
+	if isHARPath(cfg.Path) {
+		r.har = true
+
+		// entries can't be appended to an archive written by another
+		// command, so it's kept next to the new one
+		if info, err := os.Stat(cfg.Path); err == nil && info.Size() > 0 {
+			if err := r.moveAside(); err != nil {
+				return nil, errorcategory.Errorf(errorcategory.UserInput, "failed to move %s aside: %v", cfg.Path, err)
+			}
+		}
+
+		// the archive is written right away, so that it's valid even when
+		// no request is recorded
+		if err := r.openHAR(); err != nil {
+			return nil, err
+		}
+
+		return r, nil
+	}
+
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func isHARPath(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".har")
+}
+
+func (r *Recorder) open() error {
+	file, err := os.OpenFile(r.cfg.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return errorcategory.Errorf(errorcategory.UserInput, "failed to open %s: %v", r.cfg.Path, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r.file = file
+	r.size = info.Size()
+
+	return nil
+}
+
+// Record persists a request log. marshaled is the request log as received
+// from Stripe, with the detail of the request when it was expanded.
+func (r *Recorder) Record(payload EventPayload, marshaled string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+
+	if r.har {
+		return r.recordHAR(payload)
+	}
+
+	var line bytes.Buffer
+	if err := json.Compact(&line, []byte(marshaled)); err != nil {
+		return fmt.Errorf("invalid request log: %w", err)
+	}
+	line.WriteByte('\n')
+
+	if r.shouldRotate(int64(line.Len())) {
+		if err := r.rotateNDJSON(); err != nil {
+			return err
+		}
+	}
+
+	n, err := r.file.Write(line.Bytes())
+	r.size += int64(n)
+
+	return err
+}
+
+// shouldRotate reports whether writing n more bytes to the current file
+// would take it over the rotation size. Files always get at least one
+// request log, even when it's bigger than the rotation size.
+func (r *Recorder) shouldRotate(n int64) bool {
+	return r.cfg.RotateSize > 0 && r.size > 0 && r.size+n > r.cfg.RotateSize
+}
+
+func (r *Recorder) rotateNDJSON() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+
+	if err := r.moveAside(); err != nil {
+		return err
+	}
+
+	return r.open()
+}
+
+func (r *Recorder) recordHAR(payload EventPayload) error {
+	data, err := json.MarshalIndent(newHAREntry(r.cfg.BaseURL, payload), "      ", "  ")
+	if err != nil {
+		return err
+	}
+
+	// archives always get at least one entry, even when it's bigger than
+	// the rotation size
+	n := int64(len(",\n      ") + len(data) + len(harFooter))
+	if r.cfg.RotateSize > 0 && r.harEntries > 0 && r.size+n > r.cfg.RotateSize {
+		if err := r.file.Close(); err != nil {
+			return err
+		}
+
+		// the archive on disk holds the previous entries and is kept as is
+		if err := r.moveAside(); err != nil {
+			return err
+		}
+
+		if err := r.openHAR(); err != nil {
+			return err
+		}
+	}
+
+	var entry bytes.Buffer
+	if r.harEntries > 0 {
+		entry.WriteByte(',')
+	}
+	entry.WriteString("\n      ")
+	entry.Write(data)
+
+	// the entry overwrites the end of the archive, which is written again
+	// after it, so that the file is always a valid HAR archive even if the
+	// command is killed
+	if _, err := r.file.WriteAt(append(entry.Bytes(), harFooter...), r.size); err != nil {
+		return errorcategory.Errorf(errorcategory.UserInput, "failed to write %s: %v", r.cfg.Path, err)
+	}
+
+	r.size += int64(entry.Len())
+	r.harEntries++
+
+	return nil
+}
+
+// openHAR creates an empty archive at Path, replacing any existing file.
+func (r *Recorder) openHAR() error {
+	data, err := json.MarshalIndent(newHARArchive(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// entries are written between the opening bracket of the empty entries
+	// array and harFooter
+	header := data[:bytes.LastIndex(data, []byte("[]"))+1]
+
+	file, err := os.OpenFile(r.cfg.Path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return errorcategory.Errorf(errorcategory.UserInput, "failed to open %s: %v", r.cfg.Path, err)
+	}
+
+	if _, err := file.Write(append(header, harFooter...)); err != nil {
+		file.Close()
+		return errorcategory.Errorf(errorcategory.UserInput, "failed to write %s: %v", r.cfg.Path, err)
+	}
+
+	r.file = file
+	r.size = int64(len(header))
+	r.harEntries = 0
+
+	return nil
+}
+
+// moveAside renames the file at Path to the first unused rotated path, like
+// logs.1.ndjson.
+func (r *Recorder) moveAside() error {
+	ext := filepath.Ext(r.cfg.Path)
+	base := strings.TrimSuffix(r.cfg.Path, ext)
+
+	for i := 1; ; i++ {
+		rotated := fmt.Sprintf("%s.%d%s", base, i, ext)
+
+		if _, err := os.Stat(rotated); os.IsNotExist(err) {
+			return os.Rename(r.cfg.Path, rotated)
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// Close closes the file request logs are written to. Request logs recorded
+// afterwards are ignored.
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+
+	r.closed = true
+
+	if r.file != nil {
+		return r.file.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package logtailing
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func recordTestLog(t *testing.T, r *Recorder, requestID string) {
+	payload := EventPayload{
+		CreatedAt: 1700000000,
+		Method:    "POST",
+		URL:       "/v1/payment_intents",
+		RequestID: requestID,
+		Status:    200,
+	}
+
+	marshaled, err := json.MarshalIndent(payload, "", "  ")
+	require.NoError(t, err)
+
+	require.NoError(t, r.Record(payload, string(marshaled)))
+}
+
+func readNDJSON(t *testing.T, path string) []EventPayload {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var payloads []EventPayload
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var payload EventPayload
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &payload))
+		payloads = append(payloads, payload)
+	}
+	require.NoError(t, scanner.Err())
+
+	return payloads
+}
+
+func readHAR(t *testing.T, path string) harArchive {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var archive harArchive
+	require.NoError(t, json.Unmarshal(data, &archive))
+
+	return archive
+}
+
+// recordedFiles returns the rotated files of path in order, followed by path.
+func recordedFiles(t *testing.T, path string) []string {
+	ext := filepath.Ext(path)
+	base := path[:len(path)-len(ext)]
+
+	var files []string
+	for i := 1; ; i++ {
+		rotated := fmt.Sprintf("%s.%d%s", base, i, ext)
+		if _, err := os.Stat(rotated); os.IsNotExist(err) {
+			break
+		}
+		files = append(files, rotated)
+	}
+
+	return append(files, path)
+}
+
+func TestRecorder_NDJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.ndjson")
+
+	r, err := NewRecorder(&RecorderConfig{Path: path})
+	require.NoError(t, err)
+	recordTestLog(t, r, "req_1")
+	recordTestLog(t, r, "req_2")
+	require.NoError(t, r.Close())
+
+	// files are appended to
+	r, err = NewRecorder(&RecorderConfig{Path: path})
+	require.NoError(t, err)
+	recordTestLog(t, r, "req_3")
+	require.NoError(t, r.Close())
+
+	// request logs recorded after Close are ignored
+	recordTestLog(t, r, "req_4")
+
+	payloads := readNDJSON(t, path)
+	require.Len(t, payloads, 3)
+	require.Equal(t, "req_1", payloads[0].RequestID)
+	require.Equal(t, "req_3", payloads[2].RequestID)
+}
+
+func TestRecorder_NDJSONRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.ndjson")
+
+	r, err := NewRecorder(&RecorderConfig{Path: path, RotateSize: 600})
+	require.NoError(t, err)
+	defer r.Close()
+
+	for _, requestID := range []string{"req_1", "req_2", "req_3", "req_4", "req_5"} {
+		recordTestLog(t, r, requestID)
+	}
+
+	files := recordedFiles(t, path)
+	require.Greater(t, len(files), 1)
+
+	var requestIDs []string
+	for _, file := range files {
+		info, err := os.Stat(file)
+		require.NoError(t, err)
+		require.LessOrEqual(t, info.Size(), int64(600), file)
+
+		for _, payload := range readNDJSON(t, file) {
+			requestIDs = append(requestIDs, payload.RequestID)
+		}
+	}
+
+	require.Equal(t, []string{"req_1", "req_2", "req_3", "req_4", "req_5"}, requestIDs)
+}
+
+func TestRecorder_HAR(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "requests.har")
+
+	r, err := NewRecorder(&RecorderConfig{Path: path})
+	require.NoError(t, err)
+	defer r.Close()
+
+	// the archive is valid before any request is recorded
+	require.Empty(t, readHAR(t, path).Log.Entries)
+
+	recordTestLog(t, r, "req_1")
+	recordTestLog(t, r, "req_2")
+
+	archive := readHAR(t, path)
+	require.Equal(t, "1.2", archive.Log.Version)
+	require.Equal(t, "stripe-cli", archive.Log.Creator.Name)
+	require.Len(t, archive.Log.Entries, 2)
+	require.Equal(t, "https://api.stripe.com/v1/payment_intents", archive.Log.Entries[0].Request.URL)
+	require.Equal(t, "req_2", archive.Log.Entries[1].RequestID)
+}
+
+func TestRecorder_HARKeepsExistingArchive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "requests.har")
+
+	r, err := NewRecorder(&RecorderConfig{Path: path})
+	require.NoError(t, err)
+	recordTestLog(t, r, "req_1")
+	require.NoError(t, r.Close())
+
+	r, err = NewRecorder(&RecorderConfig{Path: path})
+	require.NoError(t, err)
+	defer r.Close()
+	recordTestLog(t, r, "req_2")
+
+	files := recordedFiles(t, path)
+	require.Len(t, files, 2)
+	require.Equal(t, "req_1", readHAR(t, files[0]).Log.Entries[0].RequestID)
+	require.Equal(t, "req_2", readHAR(t, files[1]).Log.Entries[0].RequestID)
+}
+
+func TestRecorder_HARRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "requests.har")
+
+	r, err := NewRecorder(&RecorderConfig{Path: path, RotateSize: 3000})
+	require.NoError(t, err)
+	defer r.Close()
+
+	for _, requestID := range []string{"req_1", "req_2", "req_3", "req_4", "req_5"} {
+		recordTestLog(t, r, requestID)
+	}
+
+	files := recordedFiles(t, path)
+	require.Greater(t, len(files), 1)
+
+	var requestIDs []string
+	for _, file := range files {
+		entries := readHAR(t, file).Log.Entries
+		for _, entry := range entries {
+			requestIDs = append(requestIDs, entry.RequestID)
+		}
+
+		if len(entries) > 1 {
+			info, err := os.Stat(file)
+			require.NoError(t, err)
+			require.LessOrEqual(t, info.Size(), int64(3000), file)
+		}
+	}
+
+	require.Equal(t, []string{"req_1", "req_2", "req_3", "req_4", "req_5"}, requestIDs)
+}
+
+func TestNewRecorder_NegativeRotateSize(t *testing.T) {
+	_, err := NewRecorder(&RecorderConfig{Path: filepath.Join(t.TempDir(), "logs.ndjson"), RotateSize: -1})
+	require.Error(t, err)
+}
@@ -0,0 +1,42 @@
+package logtailing
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/acarl005/stripansi"
+)
+
+var newlineRegex = regexp.MustCompile("[\r\n]")
+
+// Sanitize removes the ANSI escape codes and newlines of a request log field,
+// so that it can be printed to a terminal on a single line.
+func Sanitize(str string) string {
+	withoutAnsi := stripansi.Strip(str)
+	withoutNewlines := newlineRegex.ReplaceAllLiteralString(withoutAnsi, "")
+	return strings.TrimSpace(withoutNewlines)
+}
+
+// SanitizePayload sanitizes the fields of a request log that are printed.
+func SanitizePayload(payload *EventPayload) {
+	payload.Error.Charge = Sanitize(payload.Error.Charge)
+	payload.Error.Code = Sanitize(payload.Error.Code)
+	payload.Error.DeclineCode = Sanitize(payload.Error.DeclineCode)
+	payload.Error.ErrorInsight = Sanitize(payload.Error.ErrorInsight)
+	payload.Error.Message = Sanitize(payload.Error.Message)
+	payload.Error.Param = Sanitize(payload.Error.Param)
+	payload.Error.Type = Sanitize(payload.Error.Type)
+
+	payload.Method = Sanitize(payload.Method)
+
+	payload.RequestID = Sanitize(payload.RequestID)
+
+	payload.URL = Sanitize(payload.URL)
+
+	if payload.Detail != nil {
+		payload.Detail.APIVersion = Sanitize(payload.Detail.APIVersion)
+		payload.Detail.IdempotencyKey = Sanitize(payload.Detail.IdempotencyKey)
+		payload.Detail.ObjectID = Sanitize(payload.Detail.ObjectID)
+		payload.Detail.RequestID = Sanitize(payload.Detail.RequestID)
+	}
+}
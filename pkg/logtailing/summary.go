@@ -0,0 +1,161 @@
+package logtailing
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// idSegment matches the path segments of object IDs, like cus_NffrFeUfNV2Hib
+// or sub_sched_1Mr3, which contain a digit unlike resource names such as
+// payment_intents
+var idSegment = regexp.MustCompile(`^[a-z]+(_[a-z]+)*_[0-9A-Za-z]*[0-9][0-9A-Za-z]*$`)
+
+var numericSegment = regexp.MustCompile(`^[0-9]+$`)
+
+// PathTemplate returns the path of url with object IDs collapsed, so that
+// /v1/customers/cus_123/sources/card_456 becomes
+// /v1/customers/{id}/sources/{id}.
+func PathTemplate(url string) string {
+	path, _, _ := strings.Cut(url, "?")
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if idSegment.MatchString(segment) || numericSegment.MatchString(segment) {
+			segments[i] = "{id}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// SummaryCount is the number of requests of a path, status or error code in
+// a SummaryWindow.
+type SummaryCount struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// SummaryWindow aggregates the request logs received over a period of time.
+type SummaryWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	Requests int `json:"requests"`
+	Errors   int `json:"errors"`
+
+	// ErrorRate is the share of requests that failed, between 0 and 1
+	ErrorRate float64 `json:"error_rate"`
+
+	// Paths, StatusCodes, ErrorCodes and DeclineCodes are sorted by
+	// decreasing count
+	Paths        []SummaryCount `json:"paths"`
+	StatusCodes  []SummaryCount `json:"status_codes"`
+	ErrorCodes   []SummaryCount `json:"error_codes"`
+	DeclineCodes []SummaryCount `json:"decline_codes"`
+}
+
+// Summary aggregates request logs into windows, for
+// `stripe logs tail --summary`. It isn't safe for concurrent use.
+type Summary struct {
+	start    time.Time
+	requests int
+	errors   int
+
+	paths        map[string]int
+	statusCodes  map[string]int
+	errorCodes   map[string]int
+	declineCodes map[string]int
+}
+
+// NewSummary returns a Summary whose first window starts at start.
+func NewSummary(start time.Time) *Summary {
+	s := &Summary{}
+	s.reset(start)
+
+	return s
+}
+
+func (s *Summary) reset(start time.Time) {
+	s.start = start
+	s.requests = 0
+	s.errors = 0
+	s.paths = make(map[string]int)
+	s.statusCodes = make(map[string]int)
+	s.errorCodes = make(map[string]int)
+	s.declineCodes = make(map[string]int)
+}
+
+// Add adds a request log to the current window.
+func (s *Summary) Add(payload EventPayload) {
+	s.requests++
+
+	if payload.Status >= 400 {
+		s.errors++
+	}
+
+	path := "[unknown path]"
+	if payload.URL != "" {
+		path = PathTemplate(payload.URL)
+	}
+
+	s.paths[strings.TrimSpace(payload.Method+" "+path)]++
+	s.statusCodes[statusCodeKey(payload.Status)]++
+
+	if payload.Error.Code != "" {
+		s.errorCodes[payload.Error.Code]++
+	}
+
+	if payload.Error.DeclineCode != "" {
+		s.declineCodes[payload.Error.DeclineCode]++
+	}
+}
+
+// Flush returns the current window, ending at end, and starts a new one.
+func (s *Summary) Flush(end time.Time) SummaryWindow {
+	window := SummaryWindow{
+		Start:        s.start,
+		End:          end,
+		Requests:     s.requests,
+		Errors:       s.errors,
+		Paths:        sortedCounts(s.paths),
+		StatusCodes:  sortedCounts(s.statusCodes),
+		ErrorCodes:   sortedCounts(s.errorCodes),
+		DeclineCodes: sortedCounts(s.declineCodes),
+	}
+
+	if s.requests > 0 {
+		window.ErrorRate = float64(s.errors) / float64(s.requests)
+	}
+
+	s.reset(end)
+
+	return window
+}
+
+func statusCodeKey(status int) string {
+	if status == 0 {
+		return "unknown"
+	}
+
+	return strconv.Itoa(status)
+}
+
+// sortedCounts returns counts sorted by decreasing count, then by key.
+func sortedCounts(counts map[string]int) []SummaryCount {
+	sorted := make([]SummaryCount, 0, len(counts))
+	for key, count := range counts {
+		sorted = append(sorted, SummaryCount{Key: key, Count: count})
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Count != sorted[j].Count {
+			return sorted[i].Count > sorted[j].Count
+		}
+		return sorted[i].Key < sorted[j].Key
+	})
+
+	return sorted
+}
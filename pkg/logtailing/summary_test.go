@@ -0,0 +1,56 @@
+package logtailing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathTemplate(t *testing.T) {
+	for url, expected := range map[string]string{
+		"/v1/payment_intents":                           "/v1/payment_intents",
+		"/v1/payment_intents/pi_3NxYzAbC12/confirm":     "/v1/payment_intents/{id}/confirm",
+		"/v1/customers/cus_123/sources/card_1AbC":       "/v1/customers/{id}/sources/{id}",
+		"/v1/subscription_schedules/sub_sched_1Mr3":     "/v1/subscription_schedules/{id}",
+		"/v1/test_helpers/test_clocks/clock_1a/advance": "/v1/test_helpers/test_clocks/{id}/advance",
+		"/v1/files/12345":                               "/v1/files/{id}",
+		"/v1/customers?limit=3":                         "/v1/customers",
+	} {
+		require.Equal(t, expected, PathTemplate(url), url)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	summary := NewSummary(start)
+
+	summary.Add(EventPayload{Method: "POST", URL: "/v1/payment_intents", Status: 200})
+	summary.Add(EventPayload{Method: "POST", URL: "/v1/payment_intents/pi_1/confirm", Status: 402, Error: RedactedError{Code: "card_declined", DeclineCode: "insufficient_funds"}})
+	summary.Add(EventPayload{Method: "POST", URL: "/v1/payment_intents/pi_2/confirm", Status: 402, Error: RedactedError{Code: "card_declined", DeclineCode: "lost_card"}})
+	summary.Add(EventPayload{Method: "GET", URL: "/v1/customers/cus_1", Status: 404, Error: RedactedError{Code: "resource_missing"}})
+
+	end := start.Add(30 * time.Second)
+	window := summary.Flush(end)
+
+	require.Equal(t, start, window.Start)
+	require.Equal(t, end, window.End)
+	require.Equal(t, 4, window.Requests)
+	require.Equal(t, 3, window.Errors)
+	require.Equal(t, 0.75, window.ErrorRate)
+	require.Equal(t, []SummaryCount{
+		{Key: "POST /v1/payment_intents/{id}/confirm", Count: 2},
+		{Key: "GET /v1/customers/{id}", Count: 1},
+		{Key: "POST /v1/payment_intents", Count: 1},
+	}, window.Paths)
+	require.Equal(t, []SummaryCount{{Key: "402", Count: 2}, {Key: "200", Count: 1}, {Key: "404", Count: 1}}, window.StatusCodes)
+	require.Equal(t, []SummaryCount{{Key: "card_declined", Count: 2}, {Key: "resource_missing", Count: 1}}, window.ErrorCodes)
+	require.Equal(t, []SummaryCount{{Key: "insufficient_funds", Count: 1}, {Key: "lost_card", Count: 1}}, window.DeclineCodes)
+
+	// the next window starts empty
+	window = summary.Flush(end.Add(30 * time.Second))
+	require.Equal(t, end, window.Start)
+	require.Zero(t, window.Requests)
+	require.Zero(t, window.ErrorRate)
+	require.Empty(t, window.Paths)
+}
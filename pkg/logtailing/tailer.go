@@ -4,6 +4,7 @@ package logtailing
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
@@ -12,6 +13,8 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/stripe/stripe-cli/pkg/errorcategory"
+	"github.com/stripe/stripe-cli/pkg/matcher"
+	"github.com/stripe/stripe-cli/pkg/metrics"
 	"github.com/stripe/stripe-cli/pkg/stripe"
 	"github.com/stripe/stripe-cli/pkg/stripeauth"
 	"github.com/stripe/stripe-cli/pkg/websocket"
@@ -49,6 +52,25 @@ type Config struct {
 
 	// OutCh is the channel to send logs and statuses to for processing in other packages
 	OutCh chan websocket.IElement
+
+	// RequestPathPatterns filters request logs by path locally, for glob and
+	// negation patterns that the server-side filters don't support
+	RequestPathPatterns []string
+
+	// Where, when set, filters request logs locally with conditions the
+	// server-side filters can't express
+	Where *WhereExpression
+
+	// Metrics, when set, is the registry the tailer reports its metrics to
+	Metrics *metrics.Registry
+
+	// Expand indicates whether to fetch the detail of each request log from
+	// the request log API, like the request's parameters and API version
+	Expand bool
+
+	// Recorder, when set, persists every request log sent to OutCh, with its
+	// detail when it was expanded
+	Recorder *Recorder
 }
 
 // Tailer is the main interface for running the log tailing session
@@ -57,6 +79,9 @@ type Tailer struct {
 
 	stripeAuthClient *stripeauth.Client
 	webSocketClient  *websocket.Client
+	requestPaths     *matcher.Matcher
+	metrics          *tailerMetrics
+	enricher         *Enricher
 
 	interruptCh chan os.Signal
 }
@@ -70,6 +95,9 @@ type EventPayload struct {
 	Status    int           `json:"status"`
 	URL       string        `json:"url"`
 	Error     RedactedError `json:"error"`
+
+	// Detail is set with `stripe logs tail --expand`
+	Detail *RequestDetail `json:"detail,omitempty"`
 }
 
 // RedactedError is the mapping for fields in error from an EventPayload
@@ -89,12 +117,25 @@ func New(cfg *Config) *Tailer {
 		cfg.Log = &log.Logger{Out: io.Discard}
 	}
 
+	var tm *tailerMetrics
+	if cfg.Metrics != nil {
+		tm = newTailerMetrics(cfg.Metrics)
+	}
+
+	var enricher *Enricher
+	if cfg.Expand {
+		enricher = NewEnricher(cfg.Client, defaultExpandConcurrency, defaultExpandCacheSize)
+	}
+
 	return &Tailer{
-		cfg: cfg,
+		cfg:      cfg,
+		metrics:  tm,
+		enricher: enricher,
 		stripeAuthClient: stripeauth.NewClient(cfg.Client, &stripeauth.Config{
 			Log: cfg.Log,
 		}),
-		interruptCh: make(chan os.Signal, 1),
+		requestPaths: matcher.New(cfg.RequestPathPatterns),
+		interruptCh:  make(chan os.Signal, 1),
 	}
 }
 
@@ -134,10 +175,13 @@ func (t *Tailer) Run(ctx context.Context) error {
 			session.WebSocketID,
 			session.WebSocketAuthorizedFeature,
 			&websocket.Config{
-				EventHandler:      websocket.EventHandlerFunc(t.processRequestLogEvent),
+				EventHandler: websocket.EventHandlerFunc(func(msg websocket.IncomingMessage) {
+					t.processRequestLogEvent(ctx, msg)
+				}),
 				Log:               t.cfg.Log,
 				NoWSS:             t.cfg.NoWSS,
 				ReconnectInterval: time.Duration(session.ReconnectDelay) * time.Second,
+				OnReconnect:       t.metrics.reconnected,
 			},
 		)
 
@@ -160,6 +204,7 @@ func (t *Tailer) Run(ctx context.Context) error {
 			return nil
 		case <-t.webSocketClient.NotifyExpired:
 			if nAttempts < maxConnectAttempts {
+				t.metrics.reconnected("expired")
 				t.cfg.OutCh <- &websocket.StateElement{
 					State: websocket.Reconnecting,
 				}
@@ -234,7 +279,7 @@ func (t *Tailer) createSession(ctx context.Context) (*stripeauth.StripeCLISessio
 	return session, err
 }
 
-func (t *Tailer) processRequestLogEvent(msg websocket.IncomingMessage) {
+func (t *Tailer) processRequestLogEvent(ctx context.Context, msg websocket.IncomingMessage) {
 	if msg.RequestLogEvent == nil {
 		t.cfg.Log.Debug("WebSocket specified for request logs received non-request-logs event")
 		return
@@ -262,10 +307,74 @@ func (t *Tailer) processRequestLogEvent(msg websocket.IncomingMessage) {
 		return
 	}
 
+	if !t.requestPaths.Empty() && !t.requestPaths.Match(payload.URL) {
+		return
+	}
+
+	if t.cfg.Where != nil && !t.cfg.Where.Match(payload) {
+		return
+	}
+
+	t.metrics.requestLogReceived(payload.Status)
+
+	marshaled := requestLogEvent.EventPayload
+	if t.enricher != nil && payload.RequestID != "" {
+		marshaled = t.expand(ctx, &payload, marshaled)
+
+		// the tailer stopped while the detail was fetched
+		if ctx.Err() != nil {
+			return
+		}
+	}
+
+	if t.cfg.Recorder != nil {
+		if err := t.cfg.Recorder.Record(payload, marshaled); err != nil {
+			t.cfg.OutCh <- websocket.WarningElement{
+				Warning: fmt.Sprintf("failed to record request %s: %v", payload.RequestID, err),
+			}
+		}
+	}
+
 	t.cfg.OutCh <- websocket.DataElement{
 		Data:      payload,
-		Marshaled: requestLogEvent.EventPayload,
+		Marshaled: marshaled,
+	}
+}
+
+// expand sets the detail of the request of payload and returns marshaled
+// with the detail added. The request log is still printed when its detail
+// can't be fetched.
+func (t *Tailer) expand(ctx context.Context, payload *EventPayload, marshaled string) string {
+	detail, err := t.enricher.Fetch(ctx, payload.RequestID)
+	if err != nil {
+		if ctx.Err() == nil {
+			t.cfg.OutCh <- websocket.WarningElement{
+				Warning: fmt.Sprintf("failed to expand request %s: %v", payload.RequestID, err),
+			}
+		}
+		return marshaled
 	}
+
+	// the detail is cached, so the payload gets its own copy
+	expanded := *detail
+	payload.Detail = &expanded
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(marshaled), &fields); err != nil {
+		return marshaled
+	}
+
+	fields["detail"], err = json.Marshal(detail)
+	if err != nil {
+		return marshaled
+	}
+
+	withDetail, err := json.Marshal(fields)
+	if err != nil {
+		return marshaled
+	}
+
+	return string(withDetail)
 }
 
 func jsonifyFilters(logFilters *LogFilters) (string, error) {
@@ -0,0 +1,87 @@
+package logtailing
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/stripe/stripe-cli/pkg/errorcategory"
+	"github.com/stripe/stripe-cli/pkg/expr"
+)
+
+// WhereExpression is a condition on the fields of request logs, evaluated
+// locally by `stripe logs tail --where`. Fields are named like in the JSON
+// output of the command, e.g.:
+//
+//	status >= 400 && url ~ "/v1/payment_intents" && error.code != "card_declined"
+//	method == "POST" && (status == 402 || error.decline_code == "insufficient_funds")
+//	!livemode
+//
+// See expr.Expression for the syntax of expressions.
+type WhereExpression struct {
+	expression *expr.Expression
+}
+
+// whereFields are the top-level fields expressions can refer to
+var whereFields = eventPayloadFields()
+
+// ParseWhereExpression parses a --where expression.
+func ParseWhereExpression(where string) (*WhereExpression, error) {
+	expression, err := expr.Parse(where)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range expression.Paths() {
+		name, _, _ := strings.Cut(path, ".")
+		if !whereFields[name] {
+			return nil, errorcategory.Errorf(errorcategory.UserInput, "invalid expression %q: unknown field %q, fields are %s", where, path, strings.Join(sortedWhereFields(), ", "))
+		}
+	}
+
+	return &WhereExpression{expression: expression}, nil
+}
+
+// String returns the expression it was parsed from.
+func (w *WhereExpression) String() string {
+	return w.expression.String()
+}
+
+// Match reports whether the request log satisfies the expression.
+func (w *WhereExpression) Match(payload EventPayload) bool {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return false
+	}
+
+	return w.expression.Match(string(data))
+}
+
+// eventPayloadFields returns the names of the fields of EventPayload, as
+// they're named in JSON. The detail of expanded requests isn't fetched yet
+// when expressions are evaluated, so it can't be used.
+func eventPayloadFields() map[string]bool {
+	fields := make(map[string]bool)
+
+	t := reflect.TypeOf(EventPayload{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" && name != "detail" {
+			fields[name] = true
+		}
+	}
+
+	return fields
+}
+
+func sortedWhereFields() []string {
+	fields := make([]string, 0, len(whereFields))
+	for field := range whereFields {
+		fields = append(fields, field)
+	}
+
+	sort.Strings(fields)
+
+	return fields
+}
@@ -0,0 +1,72 @@
+package logtailing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhereExpression_Match(t *testing.T) {
+	declined := EventPayload{
+		Method:    "POST",
+		URL:       "/v1/payment_intents/pi_123/confirm",
+		Status:    402,
+		RequestID: "req_123",
+		Error:     RedactedError{Type: "card_error", Code: "card_declined", DeclineCode: "insufficient_funds"},
+	}
+	missing := EventPayload{
+		Method: "GET",
+		URL:    "/v1/customers/cus_123",
+		Status: 404,
+		Error:  RedactedError{Code: "resource_missing"},
+	}
+	succeeded := EventPayload{
+		Method:   "POST",
+		URL:      "/v1/payment_intents",
+		Status:   200,
+		Livemode: false,
+	}
+
+	for expr, expected := range map[string][]bool{
+		`status >= 400`: {true, true, false},
+		`status >= 400 && url ~ "/v1/payment_intents"`:        {true, false, false},
+		`status >= 400 && error.code != "card_declined"`:      {false, true, false},
+		`url ~ "^/v1/payment_intents$" || status == 404`:      {false, true, true},
+		`method == POST && !(status == 200)`:                  {true, false, false},
+		`error.decline_code == 'insufficient_funds'`:          {true, false, false},
+		`url !~ "customers"`:                                  {true, false, true},
+		`error.code`:                                          {true, true, false},
+		`!error.code`:                                         {false, false, true},
+		`!livemode && status < 300`:                           {false, false, true},
+		`(status == 402 || status == 404) && method == "GET"`: {false, true, false},
+		`error.charge == null`:                                {false, false, false},
+	} {
+		where, err := ParseWhereExpression(expr)
+		require.NoError(t, err, expr)
+
+		for i, payload := range []EventPayload{declined, missing, succeeded} {
+			require.Equal(t, expected[i], where.Match(payload), "%s on payload %d", expr, i)
+		}
+	}
+}
+
+func TestParseWhereExpression_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		``,
+		`   `,
+		`status >=`,
+		`status > "400"`,
+		`amount > 10`,
+		`url ~ "["`,
+		`url ~ 10`,
+		`(status == 400`,
+		`status == 400)`,
+		`status == 400 &&`,
+		`url == "unterminated`,
+		`status $ 400`,
+		`== 400`,
+	} {
+		_, err := ParseWhereExpression(expr)
+		require.Error(t, err, expr)
+	}
+}
@@ -0,0 +1,143 @@
+// Package matcher implements the glob and negation patterns used to select
+// event types and request paths, e.g. "invoice.*,!invoice.upcoming".
+package matcher
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Matcher matches names against a list of patterns. A pattern is either an
+// exact name, "*" to match everything, a glob where * matches any sequence of
+// characters and ? matches a single character, or any of these prefixed with
+// "!" to exclude the names it matches.
+//
+// A name matches when it matches at least one positive pattern and no
+// negative pattern. When only negative patterns are given, every name that
+// isn't excluded matches. An empty list of patterns matches nothing.
+type Matcher struct {
+	all   bool
+	exact map[string]bool
+	globs []*regexp.Regexp
+
+	excludedExact map[string]bool
+	excludedGlobs []*regexp.Regexp
+
+	empty bool
+}
+
+// New builds a Matcher from a list of patterns. Blank patterns are ignored.
+func New(patterns []string) *Matcher {
+	m := &Matcher{
+		exact:         make(map[string]bool),
+		excludedExact: make(map[string]bool),
+	}
+
+	hasPositive := false
+	hasNegative := false
+
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+
+		negate := strings.HasPrefix(pattern, "!")
+		if negate {
+			pattern = strings.TrimSpace(strings.TrimPrefix(pattern, "!"))
+		}
+
+		if pattern == "" {
+			continue
+		}
+
+		switch {
+		case negate && isGlob(pattern):
+			m.excludedGlobs = append(m.excludedGlobs, compileGlob(pattern))
+		case negate:
+			m.excludedExact[pattern] = true
+		case pattern == "*":
+			m.all = true
+		case isGlob(pattern):
+			m.globs = append(m.globs, compileGlob(pattern))
+		default:
+			m.exact[pattern] = true
+		}
+
+		if negate {
+			hasNegative = true
+		} else {
+			hasPositive = true
+		}
+	}
+
+	if !hasPositive && hasNegative {
+		m.all = true
+	}
+
+	m.empty = !hasPositive && !hasNegative
+
+	return m
+}
+
+// Match reports whether name is selected by the patterns.
+func (m *Matcher) Match(name string) bool {
+	if m.excludedExact[name] {
+		return false
+	}
+
+	for _, glob := range m.excludedGlobs {
+		if glob.MatchString(name) {
+			return false
+		}
+	}
+
+	if m.all || m.exact[name] {
+		return true
+	}
+
+	for _, glob := range m.globs {
+		if glob.MatchString(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Empty reports whether the matcher was built without any pattern.
+func (m *Matcher) Empty() bool {
+	return m.empty
+}
+
+// IsPattern reports whether s uses glob or negation syntax, as opposed to
+// being a plain name.
+func IsPattern(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.HasPrefix(s, "!") || isGlob(s)
+}
+
+// Name returns the pattern with its negation prefix removed.
+func Name(pattern string) string {
+	return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(pattern), "!"))
+}
+
+func isGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?")
+}
+
+func compileGlob(pattern string) *regexp.Regexp {
+	var sb strings.Builder
+
+	sb.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+
+	return regexp.MustCompile(sb.String())
+}
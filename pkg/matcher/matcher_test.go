@@ -0,0 +1,62 @@
+package matcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		name     string
+		expected bool
+	}{
+		{[]string{"*"}, "charge.succeeded", true},
+		{[]string{"charge.succeeded"}, "charge.succeeded", true},
+		{[]string{"charge.succeeded"}, "charge.failed", false},
+		{[]string{"invoice.*"}, "invoice.paid", true},
+		{[]string{"invoice.*"}, "invoice.payment_action_required", true},
+		{[]string{"invoice.*"}, "invoiceitem.created", false},
+		{[]string{"customer.subscription.*"}, "customer.subscription.created", true},
+		{[]string{"customer.subscription.*"}, "customer.created", false},
+		{[]string{"charge.?ailed"}, "charge.failed", true},
+		{[]string{"invoice.*", "!invoice.upcoming"}, "invoice.paid", true},
+		{[]string{"invoice.*", "!invoice.upcoming"}, "invoice.upcoming", false},
+		{[]string{"*", "!invoice.*"}, "invoice.paid", false},
+		{[]string{"*", "!invoice.*"}, "charge.succeeded", true},
+		{[]string{"!invoice.upcoming"}, "charge.succeeded", true},
+		{[]string{"!invoice.upcoming"}, "invoice.upcoming", false},
+		{[]string{" invoice.paid "}, "invoice.paid", true},
+		{[]string{"/v1/payment_intents*"}, "/v1/payment_intents/pi_123/confirm", true},
+		{[]string{"/v1/payment_intents*"}, "/v1/customers", false},
+		{[]string{"v1.billing.*"}, "v1.billing.meter.no_meter_found", true},
+		{[]string{"invoice.[paid]"}, "invoice.[paid]", true},
+		{[]string{}, "charge.succeeded", false},
+		{[]string{""}, "charge.succeeded", false},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, New(tt.patterns).Match(tt.name), "patterns %v with %s", tt.patterns, tt.name)
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	require.True(t, New(nil).Empty())
+	require.True(t, New([]string{"", " "}).Empty())
+	require.False(t, New([]string{"!invoice.upcoming"}).Empty())
+	require.False(t, New([]string{"*"}).Empty())
+}
+
+func TestIsPattern(t *testing.T) {
+	require.True(t, IsPattern("invoice.*"))
+	require.True(t, IsPattern("!invoice.upcoming"))
+	require.True(t, IsPattern("charge.?ailed"))
+	require.False(t, IsPattern("invoice.paid"))
+	require.False(t, IsPattern("/v1/customers"))
+}
+
+func TestName(t *testing.T) {
+	require.Equal(t, "invoice.upcoming", Name("!invoice.upcoming"))
+	require.Equal(t, "invoice.paid", Name("invoice.paid"))
+}
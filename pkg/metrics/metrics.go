@@ -0,0 +1,288 @@
+// Package metrics implements the counters, gauges and histograms reported by
+// long-running commands, and serves them in the Prometheus text and
+// OpenMetrics exposition formats.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the histogram buckets used when none are given, suited to
+// request latencies measured in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type metricType string
+
+const (
+	counterType   metricType = "counter"
+	gaugeType     metricType = "gauge"
+	histogramType metricType = "histogram"
+)
+
+// Registry holds a set of metrics.
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// family is a metric and all of its labeled series.
+type family struct {
+	mu         sync.Mutex
+	name       string
+	help       string
+	typ        metricType
+	labelNames []string
+	buckets    []float64
+	series     map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+
+	// histograms only
+	bucketCounts []uint64
+	count        uint64
+}
+
+// Counter is a metric that only goes up. Its methods do nothing on a nil
+// Counter, so that components can report metrics unconditionally.
+type Counter struct {
+	f *family
+}
+
+// Gauge is a metric that can go up and down. Its methods do nothing on a nil
+// Gauge.
+type Gauge struct {
+	f *family
+}
+
+// Histogram counts observations in configurable buckets. Its methods do
+// nothing on a nil Histogram.
+type Histogram struct {
+	f *family
+}
+
+// NewCounter registers a counter. name shouldn't end with _total, which is
+// added when the counter is written.
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+	return &Counter{f: r.register(name, help, counterType, labelNames, nil)}
+}
+
+// NewGauge registers a gauge.
+func (r *Registry) NewGauge(name, help string, labelNames ...string) *Gauge {
+	return &Gauge{f: r.register(name, help, gaugeType, labelNames, nil)}
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds, or
+// DefaultBuckets when buckets is nil.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+
+	sorted := append([]float64{}, buckets...)
+	sort.Float64s(sorted)
+
+	return &Histogram{f: r.register(name, help, histogramType, labelNames, sorted)}
+}
+
+func (r *Registry) register(name, help string, typ metricType, labelNames []string, buckets []float64) *family {
+	f := &family{
+		name:       name,
+		help:       help,
+		typ:        typ,
+		labelNames: labelNames,
+		buckets:    buckets,
+		series:     make(map[string]*series),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.families = append(r.families, f)
+
+	return f
+}
+
+// Inc increments the counter for the given label values by 1.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments the counter for the given label values by v, which must not
+// be negative.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if c == nil || v < 0 {
+		return
+	}
+
+	c.f.update(labelValues, func(s *series) {
+		s.value += v
+	})
+}
+
+// Set sets the gauge for the given label values to v.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	if g == nil {
+		return
+	}
+
+	g.f.update(labelValues, func(s *series) {
+		s.value = v
+	})
+}
+
+// Observe records a value in the histogram for the given label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	if h == nil {
+		return
+	}
+
+	h.f.update(labelValues, func(s *series) {
+		if s.bucketCounts == nil {
+			s.bucketCounts = make([]uint64, len(h.f.buckets))
+		}
+
+		for i, upperBound := range h.f.buckets {
+			if v <= upperBound {
+				s.bucketCounts[i]++
+			}
+		}
+
+		s.count++
+		s.value += v
+	})
+}
+
+func (f *family) update(labelValues []string, fn func(*series)) {
+	// missing label values are reported as empty rather than dropping the sample
+	values := make([]string, len(f.labelNames))
+	copy(values, labelValues)
+
+	key := strings.Join(values, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: values}
+		f.series[key] = s
+	}
+
+	fn(s)
+}
+
+// Write writes every metric of the registry in the Prometheus text exposition
+// format, or in the OpenMetrics format when openMetrics is true.
+func (r *Registry) Write(w io.Writer, openMetrics bool) error {
+	r.mu.Lock()
+	families := append([]*family{}, r.families...)
+	r.mu.Unlock()
+
+	var sb strings.Builder
+
+	for _, f := range families {
+		f.write(&sb, openMetrics)
+	}
+
+	if openMetrics {
+		sb.WriteString("# EOF\n")
+	}
+
+	_, err := io.WriteString(w, sb.String())
+
+	return err
+}
+
+func (f *family) write(sb *strings.Builder, openMetrics bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// OpenMetrics names counter families without their _total suffix
+	familyName := f.name
+	if f.typ == counterType && !openMetrics {
+		familyName += "_total"
+	}
+
+	fmt.Fprintf(sb, "# HELP %s %s\n", familyName, escapeHelp(f.help))
+	fmt.Fprintf(sb, "# TYPE %s %s\n", familyName, f.typ)
+
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := f.series[k]
+
+		switch f.typ {
+		case counterType:
+			writeSample(sb, f.name+"_total", f.labelNames, s.labelValues, "", "", s.value)
+		case gaugeType:
+			writeSample(sb, f.name, f.labelNames, s.labelValues, "", "", s.value)
+		case histogramType:
+			for i, upperBound := range f.buckets {
+				writeSample(sb, f.name+"_bucket", f.labelNames, s.labelValues, "le", formatFloat(upperBound), float64(s.bucketCounts[i]))
+			}
+			writeSample(sb, f.name+"_bucket", f.labelNames, s.labelValues, "le", "+Inf", float64(s.count))
+			writeSample(sb, f.name+"_sum", f.labelNames, s.labelValues, "", "", s.value)
+			writeSample(sb, f.name+"_count", f.labelNames, s.labelValues, "", "", float64(s.count))
+		}
+	}
+}
+
+func writeSample(sb *strings.Builder, name string, labelNames, labelValues []string, extraName, extraValue string, value float64) {
+	sb.WriteString(name)
+
+	pairs := make([]string, 0, len(labelNames)+1)
+	for i, labelName := range labelNames {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labelName, escapeLabelValue(labelValues[i])))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extraName, extraValue))
+	}
+
+	if len(pairs) > 0 {
+		sb.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+
+	sb.WriteString(" " + formatFloat(value) + "\n")
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	registry := NewRegistry()
+
+	events := registry.NewCounter("events_received", "Number of events received.", "type")
+	events.Inc("charge.succeeded")
+	events.Inc("charge.succeeded")
+	events.Add(3, "invoice.paid")
+
+	lastEvent := registry.NewGauge("last_event_timestamp_seconds", "Time of the last event.")
+	lastEvent.Set(1700000000.5)
+
+	var buf bytes.Buffer
+	require.NoError(t, registry.Write(&buf, false))
+	require.Equal(t, `# HELP events_received_total Number of events received.
+# TYPE events_received_total counter
+events_received_total{type="charge.succeeded"} 2
+events_received_total{type="invoice.paid"} 3
+# HELP last_event_timestamp_seconds Time of the last event.
+# TYPE last_event_timestamp_seconds gauge
+last_event_timestamp_seconds 1.7000000005e+09
+`, buf.String())
+}
+
+func TestWrite_OpenMetrics(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("reconnects", "Number of reconnections.").Inc()
+
+	var buf bytes.Buffer
+	require.NoError(t, registry.Write(&buf, true))
+	require.Equal(t, `# HELP reconnects Number of reconnections.
+# TYPE reconnects counter
+reconnects_total 1
+# EOF
+`, buf.String())
+}
+
+func TestHistogram(t *testing.T) {
+	registry := NewRegistry()
+
+	latency := registry.NewHistogram("latency_seconds", "Forward latency.", []float64{1, 0.1}, "endpoint")
+	latency.Observe(0.05, "http://localhost:3000")
+	latency.Observe(0.5, "http://localhost:3000")
+	latency.Observe(2, "http://localhost:3000")
+
+	var buf bytes.Buffer
+	require.NoError(t, registry.Write(&buf, false))
+	require.Equal(t, `# HELP latency_seconds Forward latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{endpoint="http://localhost:3000",le="0.1"} 1
+latency_seconds_bucket{endpoint="http://localhost:3000",le="1"} 2
+latency_seconds_bucket{endpoint="http://localhost:3000",le="+Inf"} 3
+latency_seconds_sum{endpoint="http://localhost:3000"} 2.55
+latency_seconds_count{endpoint="http://localhost:3000"} 3
+`, buf.String())
+}
+
+func TestWrite_EscapesLabelValues(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("requests", "Number of\nrequests.", "path").Inc("/v1/\"quoted\"\\path\n")
+
+	var buf bytes.Buffer
+	require.NoError(t, registry.Write(&buf, false))
+	require.Contains(t, buf.String(), `# HELP requests_total Number of\nrequests.`)
+	require.Contains(t, buf.String(), `requests_total{path="/v1/\"quoted\"\\path\n"} 1`)
+}
+
+func TestNilMetricsDoNothing(t *testing.T) {
+	var counter *Counter
+	var gauge *Gauge
+	var histogram *Histogram
+
+	require.NotPanics(t, func() {
+		counter.Inc()
+		gauge.Set(1)
+		histogram.Observe(1)
+	})
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stripe/stripe-cli/pkg/errorcategory"
+)
+
+const (
+	textContentType        = "text/plain; version=0.0.4; charset=utf-8"
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	shutdownTimeout        = 5 * time.Second
+)
+
+// ServeHTTP writes the registry's metrics, in the OpenMetrics format when the
+// client accepts it and in the Prometheus text format otherwise.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	openMetrics := strings.Contains(req.Header.Get("Accept"), "application/openmetrics-text")
+
+	if openMetrics {
+		w.Header().Set("Content-Type", openMetricsContentType)
+	} else {
+		w.Header().Set("Content-Type", textContentType)
+	}
+
+	if err := r.Write(w, openMetrics); err != nil {
+		log.WithFields(log.Fields{
+			"prefix": "metrics.Registry.ServeHTTP",
+		}).Debugf("Failed to write metrics: %v", err)
+	}
+}
+
+// ListenAndServe serves handler at addr until ctx is done. It returns once the
+// server is listening, or with an error if it can't.
+func ListenAndServe(ctx context.Context, addr string, handler http.Handler) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errorcategory.Errorf(errorcategory.UserInput, "failed to listen on %s: %v", addr, err)
+	}
+
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.WithFields(log.Fields{
+				"prefix": "metrics.ListenAndServe",
+			}).Debugf("Server stopped: %v", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		server.Shutdown(shutdownCtx) // #nosec G104
+	}()
+
+	return nil
+}
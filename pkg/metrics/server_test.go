@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeHTTP(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("events_received", "Number of events received.").Inc()
+
+	rec := httptest.NewRecorder()
+	registry.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, textContentType, rec.Header().Get("Content-Type"))
+	require.Contains(t, rec.Body.String(), "# TYPE events_received_total counter")
+	require.NotContains(t, rec.Body.String(), "# EOF")
+}
+
+func TestServeHTTP_OpenMetrics(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("events_received", "Number of events received.").Inc()
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5")
+
+	rec := httptest.NewRecorder()
+	registry.ServeHTTP(rec, req)
+
+	require.Equal(t, openMetricsContentType, rec.Header().Get("Content-Type"))
+	require.Contains(t, rec.Body.String(), "# TYPE events_received counter")
+	require.Contains(t, rec.Body.String(), "# EOF")
+}
+
+func TestListenAndServe_InvalidAddress(t *testing.T) {
+	err := ListenAndServe(t.Context(), "not-an-address", NewRegistry())
+	require.Error(t, err)
+}
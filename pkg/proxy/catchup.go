@@ -0,0 +1,287 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+
+	"github.com/stripe/stripe-cli/pkg/errorcategory"
+	"github.com/stripe/stripe-cli/pkg/stripe"
+	"github.com/stripe/stripe-cli/pkg/webhooks"
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+const (
+	// maxCatchUpEvents is the maximum number of missed events fetched when
+	// catching up, to avoid flooding local endpoints after a long outage
+	maxCatchUpEvents = 1000
+
+	catchUpPageSize = 100
+
+	// maxDeliveredEvents is the number of delivered event IDs remembered
+	// before the ones older than the checkpoint are forgotten
+	maxDeliveredEvents = 1000
+
+	catchUpUserAgent = "Stripe/1.0 (+https://stripe.com/docs/webhooks)"
+)
+
+// catchUpState remembers the events delivered by the proxy, to know from when
+// to catch up on missed events and to avoid catching up on the same event
+// twice. Events delivered by Stripe are always forwarded, even when they were
+// already caught up. Its methods are safe to call on a nil *catchUpState,
+// which never catches up.
+type catchUpState struct {
+	mu sync.Mutex
+
+	// since is the creation time of the most recent event delivered
+	since int64
+
+	// delivered maps the IDs of the events delivered by Stripe to their
+	// creation time
+	delivered map[string]int64
+
+	// caughtUp maps the IDs of the events delivered when catching up to their
+	// creation time
+	caughtUp map[string]int64
+}
+
+func newCatchUpState() *catchUpState {
+	return &catchUpState{
+		delivered: make(map[string]int64),
+		caughtUp:  make(map[string]int64),
+	}
+}
+
+// markDelivered records that Stripe delivered an event.
+func (s *catchUpState) markDelivered(id string, created int64) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.record(s.delivered, id, created)
+}
+
+// markCaughtUp records that an event is delivered when catching up, and
+// returns false if it already was delivered, by Stripe or when catching up.
+func (s *catchUpState) markCaughtUp(id string, created int64) bool {
+	if s == nil {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.delivered[id]; ok {
+		return false
+	}
+
+	if _, ok := s.caughtUp[id]; ok {
+		return false
+	}
+
+	s.record(s.caughtUp, id, created)
+
+	return true
+}
+
+func (s *catchUpState) record(events map[string]int64, id string, created int64) {
+	events[id] = created
+	if created > s.since {
+		s.since = created
+	}
+
+	// events created before the checkpoint won't be listed again when
+	// catching up, so there's no need to remember them
+	if len(events) > maxDeliveredEvents {
+		for eventID, eventCreated := range events {
+			if eventCreated < s.since {
+				delete(events, eventID)
+			}
+		}
+	}
+}
+
+// checkpoint returns the creation time of the most recent event delivered,
+// and false if no event was delivered yet.
+func (s *catchUpState) checkpoint() (int64, bool) {
+	if s == nil {
+		return 0, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.since, s.since > 0
+}
+
+// CatchUp fetches the events created since the most recent event delivered
+// from the Events API and delivers the ones that were missed, oldest first.
+// It does nothing until an event was delivered, or when the processor wasn't
+// configured to catch up.
+func (p *WebhookEventProcessor) CatchUp(ctx context.Context, client stripe.RequestPerformer) error {
+	since, ok := p.catchUp.checkpoint()
+	if !ok {
+		return nil
+	}
+
+	payloads, err := listEventsSince(ctx, client, since)
+	if err != nil {
+		return err
+	}
+
+	p.cfg.Log.WithFields(log.Fields{
+		"prefix": "proxy.WebhookEventProcessor.CatchUp",
+		"since":  since,
+	}).Debugf("Fetched %d events to catch up on", len(payloads))
+
+	if len(payloads) >= maxCatchUpEvents {
+		p.cfg.OutCh <- websocket.WarningElement{
+			Warning: fmt.Sprintf("More than %d events were missed while disconnected, only the most recent ones are forwarded", maxCatchUpEvents),
+		}
+	}
+
+	// events are listed newest first
+	for i := len(payloads) - 1; i >= 0; i-- {
+		p.processCaughtUpEvent(payloads[i])
+	}
+
+	return nil
+}
+
+// seedCatchUp marks the last snapshot event of a journal as delivered, so that
+// the events missed since then are caught up after connecting.
+func (p *WebhookEventProcessor) seedCatchUp(entries []JournalEntry) {
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.Kind != JournalEntryEvent || entry.Thin {
+			continue
+		}
+
+		created := gjson.Get(entry.Payload, "created").Int()
+		if created > 0 {
+			p.catchUp.markDelivered(entry.EventID, created)
+			return
+		}
+	}
+}
+
+func (p *WebhookEventProcessor) processCaughtUpEvent(payload string) {
+	var evt StripeEvent
+	if err := json.Unmarshal([]byte(payload), &evt); err != nil {
+		p.cfg.Log.Debug("Received malformed event from the Events API, ignoring")
+		return
+	}
+
+	req, err := ExtractRequestData(evt.RequestData)
+	if err != nil {
+		p.cfg.Log.Debug("Received malformed event from the Events API, ignoring")
+		return
+	}
+
+	evt.Request = req
+	evt.LoggedInAccountID = p.cfg.LoggedInAccountID
+
+	if !p.catchUp.markCaughtUp(evt.ID, int64(evt.Created)) {
+		p.cfg.Log.WithFields(log.Fields{
+			"prefix":   "proxy.WebhookEventProcessor.processCaughtUpEvent",
+			"event_id": evt.ID,
+		}).Debug("Event was already delivered, not catching up")
+
+		return
+	}
+
+	// the events weren't sent by Stripe, so they're signed like Stripe would
+	// have with the session's secret
+	headers := map[string]string{
+		"Content-Type": "application/json; charset=utf-8",
+		"User-Agent":   catchUpUserAgent,
+	}
+	if secret := p.getSigningSecret(); secret != "" {
+		headers[webhooks.SignatureHeader] = webhooks.GenerateSignatureHeader(time.Now(), []byte(payload), secret)
+	}
+
+	p.deliverEvent(&evt, eventContext{
+		event:          &evt,
+		requestBody:    payload,
+		requestHeaders: headers,
+	})
+}
+
+// listEventsSince pages through the events created at or after since, newest
+// first, up to maxCatchUpEvents.
+func listEventsSince(ctx context.Context, client stripe.RequestPerformer, since int64) ([]string, error) {
+	var payloads []string
+
+	startingAfter := ""
+	for len(payloads) < maxCatchUpEvents {
+		params := url.Values{}
+		params.Set("created[gte]", strconv.FormatInt(since, 10))
+		params.Set("limit", strconv.Itoa(catchUpPageSize))
+		if startingAfter != "" {
+			params.Set("starting_after", startingAfter)
+		}
+
+		resp, err := client.PerformRequest(ctx, http.MethodGet, "/v1/events", params.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode >= 300 {
+			return nil, errorcategory.Errorf(errorcategory.API, "listing events failed with status %d", resp.StatusCode)
+		}
+
+		var page struct {
+			Data    []json.RawMessage `json:"data"`
+			HasMore bool              `json:"has_more"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, err
+		}
+
+		for _, evt := range page.Data {
+			payloads = append(payloads, string(evt))
+		}
+
+		if !page.HasMore || len(page.Data) == 0 {
+			break
+		}
+
+		startingAfter = gjson.GetBytes(page.Data[len(page.Data)-1], "id").String()
+	}
+
+	if len(payloads) > maxCatchUpEvents {
+		payloads = payloads[:maxCatchUpEvents]
+	}
+
+	return payloads, nil
+}
+
+// readCatchUpJournal returns the entries of the journal at path, or none if
+// the journal doesn't exist yet.
+func readCatchUpJournal(path string) ([]JournalEntry, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	return ReadJournal(path)
+}
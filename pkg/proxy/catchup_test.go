@@ -0,0 +1,186 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/stripe"
+	"github.com/stripe/stripe-cli/pkg/webhooks"
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+func TestCatchUpState(t *testing.T) {
+	var nilState *catchUpState
+	nilState.markDelivered("evt_1", 100)
+	require.True(t, nilState.markCaughtUp("evt_1", 100))
+
+	state := newCatchUpState()
+
+	_, ok := state.checkpoint()
+	require.False(t, ok)
+
+	state.markDelivered("evt_2", 200)
+	state.markDelivered("evt_1", 100)
+
+	since, ok := state.checkpoint()
+	require.True(t, ok)
+	require.Equal(t, int64(200), since)
+
+	// events are caught up once, unless Stripe delivered them
+	require.False(t, state.markCaughtUp("evt_2", 200))
+	require.True(t, state.markCaughtUp("evt_3", 300))
+	require.False(t, state.markCaughtUp("evt_3", 300))
+
+	since, _ = state.checkpoint()
+	require.Equal(t, int64(300), since)
+}
+
+func TestCatchUp(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/events", r.URL.Path)
+		require.Equal(t, "100", r.URL.Query().Get("created[gte]"))
+
+		// events are listed newest first, over two pages
+		switch r.URL.Query().Get("starting_after") {
+		case "":
+			fmt.Fprint(w, `{"data":[{"id":"evt_4","type":"charge.refunded","created":130},{"id":"evt_3","type":"charge.failed","created":120}],"has_more":true}`)
+		case "evt_3":
+			fmt.Fprint(w, `{"data":[{"id":"evt_2","type":"charge.succeeded","created":110},{"id":"evt_1","type":"charge.succeeded","created":100}],"has_more":false}`)
+		default:
+			require.FailNow(t, "unexpected page")
+		}
+	}))
+	defer api.Close()
+
+	received := make(chan string, 10)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		_, err = webhooks.VerifySignatureHeader(body, r.Header.Get("Stripe-Signature"), "whsec_123", webhooks.DefaultTolerance, time.Now())
+		require.NoError(t, err)
+
+		received <- string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer endpoint.Close()
+
+	var sent []*websocket.OutgoingMessage
+	processor := NewWebhookEventProcessor(
+		func(msg *websocket.OutgoingMessage) { sent = append(sent, msg) },
+		[]EndpointRoute{{URL: endpoint.URL, EventTypes: []string{"*"}}},
+		&WebhookEventProcessorConfig{
+			Log:                 &log.Logger{Out: io.Discard},
+			Events:              []string{"*"},
+			OutCh:               make(chan websocket.IElement, 10),
+			DeliveryConcurrency: 1,
+			DeliveryQueueSize:   10,
+			CatchUp:             true,
+		},
+	)
+	processor.SetSigningSecret("whsec_123")
+
+	// the last event received before disconnecting
+	processor.seedCatchUp([]JournalEntry{
+		{Kind: JournalEntryEvent, EventID: "evt_1", Payload: `{"id":"evt_1","created":100}`},
+		{Kind: JournalEntryResponse, EventID: "evt_1", Status: 200},
+	})
+
+	baseURL, _ := url.Parse(api.URL)
+	err := processor.CatchUp(context.Background(), &stripe.Client{Credentials: stripe.NewAPIKeyCredentials("sk_test_123"), BaseURL: baseURL})
+	require.NoError(t, err)
+
+	for _, id := range []string{"evt_2", "evt_3", "evt_4"} {
+		select {
+		case body := <-received:
+			require.Contains(t, body, `"id":"`+id+`"`)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for "+id)
+		}
+	}
+
+	// caught up events were never sent over the websocket, so nothing is
+	// acknowledged or responded to
+	require.Empty(t, sent)
+
+	since, _ := processor.catchUp.checkpoint()
+	require.Equal(t, int64(130), since)
+}
+
+func TestCatchUp_LiveDeliveriesAreForwarded(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":[{"id":"evt_2","type":"charge.succeeded","created":110},{"id":"evt_1","type":"charge.succeeded","created":100}],"has_more":false}`)
+	}))
+	defer api.Close()
+
+	outCh := make(chan websocket.IElement, 10)
+	var sent []*websocket.OutgoingMessage
+	processor := NewWebhookEventProcessor(
+		func(msg *websocket.OutgoingMessage) { sent = append(sent, msg) },
+		nil,
+		&WebhookEventProcessorConfig{
+			Log:     &log.Logger{Out: io.Discard},
+			Events:  []string{"*"},
+			OutCh:   outCh,
+			CatchUp: true,
+		},
+	)
+
+	processor.ProcessEvent(websocket.IncomingMessage{
+		WebhookEvent: &websocket.WebhookEvent{WebhookID: "wh_1", EventPayload: `{"id":"evt_1","type":"charge.succeeded","created":100}`},
+	})
+	require.IsType(t, StripeEvent{}, (<-outCh).(websocket.DataElement).Data)
+
+	// the event Stripe delivered isn't caught up again
+	baseURL, _ := url.Parse(api.URL)
+	err := processor.CatchUp(context.Background(), &stripe.Client{Credentials: stripe.NewAPIKeyCredentials("sk_test_123"), BaseURL: baseURL})
+	require.NoError(t, err)
+	require.Len(t, outCh, 1)
+	require.Equal(t, "evt_2", (<-outCh).(websocket.DataElement).Data.(StripeEvent).ID)
+
+	// but an event that was caught up is still processed when Stripe
+	// delivers it
+	processor.ProcessEvent(websocket.IncomingMessage{
+		WebhookEvent: &websocket.WebhookEvent{WebhookID: "wh_2", EventPayload: `{"id":"evt_2","type":"charge.succeeded","created":110}`},
+	})
+	require.Equal(t, "evt_2", (<-outCh).(websocket.DataElement).Data.(StripeEvent).ID)
+	require.Len(t, sent, 2)
+}
+
+func TestProxy_CatchesUpAfterDisconnecting(t *testing.T) {
+	p := &Proxy{cfg: &Config{}}
+	p.catchUpPending.Store(true)
+
+	// the first connection catches up after a restart
+	p.onConnect(context.Background())
+	require.False(t, p.catchUpPending.Load())
+
+	// connections reset on purpose don't
+	p.onReconnect("reset")
+	require.False(t, p.catchUpPending.Load())
+
+	p.onReconnect("disconnected")
+	require.True(t, p.catchUpPending.Load())
+
+	p.onConnect(context.Background())
+	require.False(t, p.catchUpPending.Load())
+}
+
+func TestCatchUp_NothingDeliveredYet(t *testing.T) {
+	processor := NewWebhookEventProcessor(func(*websocket.OutgoingMessage) {}, nil, &WebhookEventProcessorConfig{
+		Log:     &log.Logger{Out: io.Discard},
+		CatchUp: true,
+	})
+
+	// the client isn't used since there's no event to catch up from
+	require.NoError(t, processor.CatchUp(context.Background(), nil))
+}
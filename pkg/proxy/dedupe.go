@@ -0,0 +1,210 @@
+package proxy
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultDedupeSize is the number of deliveries remembered to detect
+// duplicates when no size is configured
+const DefaultDedupeSize = 1000
+
+// DuplicateSuppressed describes an event that Stripe delivered again and that
+// wasn't forwarded, because it had already been forwarded to every endpoint.
+// Stripe is answered with the endpoints' responses to the first delivery.
+type DuplicateSuppressed struct {
+	Event   *StripeEvent
+	V2Event *V2EventPayload
+}
+
+type deliveryKey struct {
+	eventID  string
+	endpoint string
+}
+
+// delivery is an event forwarded to an endpoint
+type delivery struct {
+	key deliveryKey
+
+	// response is the endpoint's response to the last attempt to forward the
+	// event, nil until it responded
+	response *deliveryResponse
+
+	// duplicates are the deliveries suppressed before the endpoint responded,
+	// which are answered with its response
+	duplicates []eventContext
+}
+
+// deliveryResponse is the response of an endpoint, as reported to Stripe
+type deliveryResponse struct {
+	status  int
+	body    string
+	headers map[string]string
+}
+
+// deliveryCache is a least recently used set of the events forwarded to each
+// endpoint, with the endpoints' responses to them. Its methods are safe to
+// call on a nil *deliveryCache, which considers every delivery new.
+type deliveryCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[deliveryKey]*list.Element
+}
+
+func newDeliveryCache(size int) *deliveryCache {
+	if size <= 0 {
+		size = DefaultDedupeSize
+	}
+
+	return &deliveryCache{
+		size:  size,
+		order: list.New(),
+		items: make(map[deliveryKey]*list.Element),
+	}
+}
+
+// seen records that an event is delivered to the given endpoints, and returns
+// true if it was already delivered to all of them. Events that aren't
+// forwarded anywhere are only printed, so they're keyed by their ID alone.
+func (c *deliveryCache) seen(eventID string, endpoints []string) bool {
+	if c == nil {
+		return false
+	}
+
+	if len(endpoints) == 0 {
+		endpoints = []string{""}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	duplicate := true
+	for _, endpoint := range endpoints {
+		key := deliveryKey{eventID: eventID, endpoint: endpoint}
+
+		if el, ok := c.items[key]; ok {
+			c.order.MoveToFront(el)
+			continue
+		}
+
+		duplicate = false
+		c.items[key] = c.order.PushFront(&delivery{key: key})
+
+		if c.order.Len() > c.size {
+			oldest := c.order.Back()
+			c.order.Remove(oldest)
+			delete(c.items, oldest.Value.(*delivery).key)
+		}
+	}
+
+	return duplicate
+}
+
+// suppress records a duplicate delivery of an event to an endpoint, and
+// returns the endpoint's response to the first delivery. When the endpoint
+// didn't respond yet, it returns nil and the duplicate is returned by
+// responded once it does.
+func (c *deliveryCache) suppress(endpoint string, evtCtx eventContext) *deliveryResponse {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[deliveryKey{eventID: eventContextID(evtCtx), endpoint: endpoint}]
+	if !ok {
+		return nil
+	}
+
+	d := el.Value.(*delivery)
+	if d.response == nil {
+		d.duplicates = append(d.duplicates, evtCtx)
+	}
+
+	return d.response
+}
+
+// responded records the response of an endpoint to an event, and returns the
+// duplicate deliveries that were waiting for it.
+func (c *deliveryCache) responded(endpoint string, eventID string, response deliveryResponse) []eventContext {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[deliveryKey{eventID: eventID, endpoint: endpoint}]
+	if !ok {
+		return nil
+	}
+
+	d := el.Value.(*delivery)
+	d.response = &response
+
+	duplicates := d.duplicates
+	d.duplicates = nil
+
+	return duplicates
+}
+
+// failed forgets that an event was forwarded to an endpoint that never
+// responded to it, so that its next delivery is forwarded, and returns the
+// duplicate deliveries that were waiting for a response.
+func (c *deliveryCache) failed(endpoint string, eventID string) []eventContext {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := deliveryKey{eventID: eventID, endpoint: endpoint}
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+
+	d := el.Value.(*delivery)
+	if d.response != nil {
+		return nil
+	}
+
+	c.order.Remove(el)
+	delete(c.items, key)
+
+	return d.duplicates
+}
+
+// forget removes an event from the cache, so that its next delivery is
+// forwarded.
+func (c *deliveryCache) forget(eventID string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, el := range c.items {
+		if key.eventID == eventID {
+			c.order.Remove(el)
+			delete(c.items, key)
+		}
+	}
+}
+
+// eventContextID returns the ID of the event of an eventContext.
+func eventContextID(evtCtx eventContext) string {
+	switch {
+	case evtCtx.event != nil:
+		return evtCtx.event.ID
+	case evtCtx.v2Event != nil:
+		return evtCtx.v2Event.ID
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,274 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+func TestDeliveryCache(t *testing.T) {
+	cache := newDeliveryCache(3)
+
+	require.False(t, cache.seen("evt_1", []string{"http://localhost:3000", "http://localhost:4000"}))
+	require.True(t, cache.seen("evt_1", []string{"http://localhost:3000", "http://localhost:4000"}))
+
+	// an event is only a duplicate once it was forwarded to every endpoint
+	require.False(t, cache.seen("evt_1", []string{"http://localhost:3000", "http://localhost:5000"}))
+
+	// events that aren't forwarded are keyed by their ID
+	require.False(t, cache.seen("evt_2", nil))
+	require.True(t, cache.seen("evt_2", nil))
+
+	cache.forget("evt_2")
+	require.False(t, cache.seen("evt_2", nil))
+}
+
+func TestDeliveryCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newDeliveryCache(2)
+
+	require.False(t, cache.seen("evt_1", nil))
+	require.False(t, cache.seen("evt_2", nil))
+	require.True(t, cache.seen("evt_1", nil))
+
+	// evt_2 is the least recently used, so it's evicted
+	require.False(t, cache.seen("evt_3", nil))
+	require.True(t, cache.seen("evt_1", nil))
+	require.False(t, cache.seen("evt_2", nil))
+}
+
+func TestDeliveryCache_Nil(t *testing.T) {
+	var cache *deliveryCache
+
+	require.False(t, cache.seen("evt_1", nil))
+	require.False(t, cache.seen("evt_1", nil))
+	cache.forget("evt_1")
+}
+
+func TestWebhookEventProcessor_SuppressesDuplicates(t *testing.T) {
+	for _, dedupe := range []bool{true, false} {
+		outCh := make(chan websocket.IElement, 3)
+		processor := NewWebhookEventProcessor(func(*websocket.OutgoingMessage) {}, nil, &WebhookEventProcessorConfig{
+			Log:    &log.Logger{Out: io.Discard},
+			Events: []string{"*"},
+			OutCh:  outCh,
+			Dedupe: dedupe,
+		})
+
+		for i := 0; i < 2; i++ {
+			processor.ProcessEvent(websocket.IncomingMessage{
+				WebhookEvent: &websocket.WebhookEvent{EventPayload: `{"id":"evt_1","type":"charge.succeeded"}`},
+			})
+		}
+
+		require.Len(t, outCh, 2)
+		require.IsType(t, StripeEvent{}, (<-outCh).(websocket.DataElement).Data)
+
+		if !dedupe {
+			require.IsType(t, StripeEvent{}, (<-outCh).(websocket.DataElement).Data)
+			continue
+		}
+
+		duplicate := (<-outCh).(websocket.DataElement).Data.(DuplicateSuppressed)
+		require.Equal(t, "evt_1", duplicate.Event.ID)
+
+		// events resent on purpose are forwarded again
+		processor.AllowRedelivery("evt_1")
+		processor.ProcessEvent(websocket.IncomingMessage{
+			WebhookEvent: &websocket.WebhookEvent{EventPayload: `{"id":"evt_1","type":"charge.succeeded"}`},
+		})
+		require.IsType(t, StripeEvent{}, (<-outCh).(websocket.DataElement).Data)
+	}
+}
+
+func TestWebhookEventProcessor_AnswersDuplicates(t *testing.T) {
+	release := make(chan struct{})
+	var n int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&n, 1)
+		<-release
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	responses := make(chan *websocket.WebhookResponse, 3)
+	sendMessage := func(msg *websocket.OutgoingMessage) {
+		if msg.WebhookResponse != nil {
+			responses <- msg.WebhookResponse
+		}
+	}
+
+	processor := NewWebhookEventProcessor(sendMessage, []EndpointRoute{{URL: ts.URL, EventTypes: []string{"*"}}}, &WebhookEventProcessorConfig{
+		Log:    &log.Logger{Out: io.Discard},
+		Events: []string{"*"},
+		OutCh:  make(chan websocket.IElement, 20),
+		Dedupe: true,
+	})
+
+	deliver := func(webhookID string) {
+		processor.ProcessEvent(websocket.IncomingMessage{
+			WebhookEvent: &websocket.WebhookEvent{
+				WebhookID:    webhookID,
+				EventPayload: `{"id":"evt_1","type":"charge.succeeded"}`,
+			},
+		})
+	}
+
+	receive := func() *websocket.WebhookResponse {
+		select {
+		case resp := <-responses:
+			return resp
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "No response was sent to Stripe")
+			return nil
+		}
+	}
+
+	// the duplicate is answered once the endpoint responded to the first
+	// delivery
+	deliver("wh_1")
+	deliver("wh_2")
+	close(release)
+
+	webhookIDs := []string{receive().WebhookID, receive().WebhookID}
+	require.ElementsMatch(t, []string{"wh_1", "wh_2"}, webhookIDs)
+
+	// later duplicates are answered right away
+	deliver("wh_3")
+	resp := receive()
+	require.Equal(t, "wh_3", resp.WebhookID)
+	require.Equal(t, http.StatusAccepted, resp.Status)
+	require.Equal(t, ts.URL, resp.ForwardURL)
+
+	require.EqualValues(t, 1, atomic.LoadInt32(&n))
+}
+
+func TestWebhookEventProcessor_ForwardsDuplicatesOfFailedDeliveries(t *testing.T) {
+	// nothing listens on the port once the listener is closed
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	endpointURL := "http://" + listener.Addr().String()
+	listener.Close()
+
+	outCh := make(chan websocket.IElement, 20)
+	processor := NewWebhookEventProcessor(func(*websocket.OutgoingMessage) {}, []EndpointRoute{{URL: endpointURL, EventTypes: []string{"*"}}}, &WebhookEventProcessorConfig{
+		Log:    &log.Logger{Out: io.Discard},
+		Events: []string{"*"},
+		OutCh:  outCh,
+		Dedupe: true,
+	})
+
+	deliver := func(webhookID string) {
+		processor.ProcessEvent(websocket.IncomingMessage{
+			WebhookEvent: &websocket.WebhookEvent{
+				WebhookID:    webhookID,
+				EventPayload: `{"id":"evt_1","type":"charge.succeeded"}`,
+			},
+		})
+	}
+
+	// waitForFailure returns the elements sent until a forward failed
+	waitForFailure := func() []websocket.IElement {
+		var elements []websocket.IElement
+		for {
+			select {
+			case element := <-outCh:
+				elements = append(elements, element)
+				if e, ok := element.(websocket.ErrorElement); ok {
+					require.IsType(t, FailedToPostError{}, e.Error)
+					return elements
+				}
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "Event wasn't forwarded")
+				return nil
+			}
+		}
+	}
+
+	deliver("wh_1")
+	waitForFailure()
+
+	// Stripe's retry of the event is forwarded rather than suppressed
+	deliver("wh_2")
+
+	attempted := false
+	for _, element := range waitForFailure() {
+		if de, ok := element.(websocket.DataElement); ok {
+			_, suppressed := de.Data.(DuplicateSuppressed)
+			require.False(t, suppressed)
+			_, isAttempt := de.Data.(EndpointAttempt)
+			attempted = attempted || isAttempt
+		}
+	}
+	require.True(t, attempted)
+
+	processor.Close()
+}
+
+func TestWebhookEventProcessor_ForwardsWaitingDuplicatesOfFailedDeliveries(t *testing.T) {
+	release := make(chan struct{})
+	var n int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&n, 1) == 1 {
+			// the first delivery fails without a response
+			<-release
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			conn.Close()
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	responses := make(chan *websocket.WebhookResponse, 3)
+	sendMessage := func(msg *websocket.OutgoingMessage) {
+		if msg.WebhookResponse != nil {
+			responses <- msg.WebhookResponse
+		}
+	}
+
+	processor := NewWebhookEventProcessor(sendMessage, []EndpointRoute{{URL: ts.URL, EventTypes: []string{"*"}}}, &WebhookEventProcessorConfig{
+		Log:    &log.Logger{Out: io.Discard},
+		Events: []string{"*"},
+		OutCh:  make(chan websocket.IElement, 20),
+		Dedupe: true,
+	})
+
+	for _, webhookID := range []string{"wh_1", "wh_2", "wh_3"} {
+		processor.ProcessEvent(websocket.IncomingMessage{
+			WebhookEvent: &websocket.WebhookEvent{
+				WebhookID:    webhookID,
+				EventPayload: `{"id":"evt_1","type":"charge.succeeded"}`,
+			},
+		})
+	}
+	close(release)
+
+	// the first duplicate is forwarded, and the second one is answered with
+	// its response
+	var webhookIDs []string
+	for i := 0; i < 2; i++ {
+		select {
+		case resp := <-responses:
+			require.Equal(t, http.StatusAccepted, resp.Status)
+			webhookIDs = append(webhookIDs, resp.WebhookID)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "No response was sent to Stripe")
+		}
+	}
+
+	require.ElementsMatch(t, []string{"wh_2", "wh_3"}, webhookIDs)
+	require.EqualValues(t, 2, atomic.LoadInt32(&n))
+
+	processor.Close()
+}
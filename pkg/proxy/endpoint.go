@@ -3,14 +3,19 @@ package proxy
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"net/http"
+	"net/http/httptrace"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/stripe/stripe-cli/pkg/matcher"
+	"github.com/stripe/stripe-cli/pkg/webhooks"
 	"github.com/stripe/stripe-cli/pkg/websocket"
 )
 
@@ -26,8 +31,41 @@ type EndpointConfig struct {
 
 	ResponseHandler EndpointResponseHandler
 
+	// FailureHandler, when set, is called when an event couldn't be
+	// delivered, because its last attempt failed without a response or its
+	// payload couldn't be transformed
+	FailureHandler func(evtCtx eventContext, forwardURL string, err error)
+
 	// OutCh is the channel to send data and statuses to for processing in other packages
 	OutCh chan websocket.IElement
+
+	// RetryPolicy controls how failed forwards are retried
+	RetryPolicy RetryPolicy
+
+	// Concurrency is the maximum number of events forwarded to the endpoint at
+	// the same time. Events are delivered one at a time, in order, when set to
+	// 1, and without any limit when set to 0.
+	Concurrency int
+
+	// QueueSize is the number of events that can wait for delivery before
+	// Enqueue blocks. Only used when Concurrency is set. Since the proxy
+	// enqueues events in order as they are read from the websocket when
+	// Concurrency is set, a full queue stops the proxy from reading new events
+	// until the endpoint catches up.
+	QueueSize int
+
+	// SigningSecret, when set, returns the secret used to re-compute the
+	// Stripe-Signature header of every forwarded event. The header sent by
+	// Stripe is forwarded unchanged when it is nil or returns an empty secret.
+	SigningSecret func() string
+
+	// Metrics, when set, records the result and latency of every forward
+	Metrics *Metrics
+
+	// Transforms rewrite the payload of every event, in order, before it is
+	// forwarded. They should be used with SigningSecret, since the signature
+	// sent by Stripe doesn't match transformed payloads.
+	Transforms []PayloadTransform
 }
 
 // EndpointResponseHandler handles a response from the endpoint.
@@ -46,6 +84,19 @@ func (f EndpointResponseHandlerFunc) ProcessResponse(evtCtx eventContext, forwar
 	f(evtCtx, forwardURL, resp)
 }
 
+// EndpointAttempt describes an attempt to forward an event to a local
+// endpoint, which is followed by an EndpointResponse or a FailedToPostError
+type EndpointAttempt struct {
+	Event   *StripeEvent
+	V2Event *V2EventPayload
+
+	// URL is the local endpoint the event is forwarded to
+	URL string
+
+	// Attempt is the number of the attempt, starting at 1
+	Attempt int
+}
+
 // FailedToPostError describes a failure to send a POST request to an endpoint
 type FailedToPostError struct {
 	Err error
@@ -64,12 +115,26 @@ type EndpointClient struct {
 
 	connect bool
 
-	events map[string]bool
+	events *matcher.Matcher
 
 	// Optional configuration parameters
 	cfg *EndpointConfig
 
 	isEventDestination bool
+
+	queue *deliveryQueue
+
+	// ctx is canceled by Close to stop waiting for retries
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// mu guards closed, which stops Enqueue from scheduling deliveries once
+	// the client is closed
+	mu     sync.RWMutex
+	closed bool
+
+	// deliveries tracks the deliveries scheduled by Enqueue
+	deliveries sync.WaitGroup
 }
 
 // SupportsEventType takes an event of a webhook and compares it to the internal
@@ -79,12 +144,7 @@ func (c *EndpointClient) SupportsEventType(connect bool, eventType string) bool
 		return false
 	}
 
-	// Endpoint supports all events, always return true
-	if c.events["*"] || c.events[eventType] {
-		return true
-	}
-
-	return false
+	return c.events.Match(eventType)
 }
 
 // SupportsContext takes the context string of an event, and determines whether the endpoint supports
@@ -97,56 +157,173 @@ func (c *EndpointClient) SupportsContext(context string) bool {
 	return context == ""
 }
 
+// Enqueue schedules the event for delivery to the local endpoint and returns
+// without waiting for the endpoint's response, unless the delivery queue is
+// full. Events enqueued after Close are dropped.
+func (c *EndpointClient) Enqueue(evtCtx eventContext) {
+	post := c.Post
+	if c.isEventDestination {
+		post = c.PostV2
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		c.cfg.Log.WithFields(log.Fields{
+			"prefix": "proxy.EndpointClient.Enqueue",
+			"url":    c.URL,
+		}).Debug("Endpoint client is closed, dropping event")
+		return
+	}
+
+	c.deliveries.Add(1)
+
+	if c.queue == nil {
+		// TODO: handle errors returned by endpointClients
+		go func() {
+			defer c.deliveries.Done()
+			post(evtCtx)
+		}()
+		return
+	}
+
+	if c.queue.full() {
+		c.cfg.Log.WithFields(log.Fields{
+			"prefix": "proxy.EndpointClient.Enqueue",
+			"url":    c.URL,
+		}).Debug("Delivery queue is full, waiting for the endpoint to catch up")
+	}
+
+	c.queue.push(func() {
+		defer c.deliveries.Done()
+		post(evtCtx)
+	})
+}
+
 // Post sends a message to the local endpoint.
 func (c *EndpointClient) Post(evtCtx eventContext) error {
 	c.cfg.Log.WithFields(log.Fields{
 		"prefix": "proxy.EndpointClient.Post",
 	}).Debug("Forwarding event to local endpoint")
 
-	req, err := http.NewRequest(http.MethodPost, c.URL, bytes.NewBuffer([]byte(evtCtx.requestBody)))
-	if err != nil {
-		return err
+	return c.postWithRetries(evtCtx)
+}
+
+// PostV2 sends a message to a local event destination
+func (c *EndpointClient) PostV2(evtCtx eventContext) error {
+	return c.postWithRetries(evtCtx)
+}
+
+// Close stops accepting new events and waits for the events that were
+// already enqueued to be delivered. Failed forwards aren't retried anymore,
+// and attempts that are waiting to be retried give up.
+func (c *EndpointClient) Close() {
+	c.cancel()
+
+	// waits for Enqueue calls blocked on a full queue to return
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
 	}
+	c.closed = true
+	c.mu.Unlock()
 
-	for k, v := range evtCtx.requestHeaders {
-		req.Header.Add(k, v)
+	if c.queue != nil {
+		c.queue.close()
 	}
 
-	// add custom headers
-	for k, v := range c.headers {
-		if strings.ToLower(k) == "host" {
-			req.Host = v
-		} else {
-			req.Header.Add(k, v)
-		}
+	c.deliveries.Wait()
+
+	// transports that keep a connection open, like gRPC ones, are closed
+	// once nothing is delivered anymore
+	if closer, ok := c.cfg.HTTPClient.Transport.(io.Closer); ok {
+		closer.Close()
 	}
+}
 
-	resp, err := c.cfg.HTTPClient.Do(req)
-	if err != nil {
-		c.cfg.OutCh <- websocket.ErrorElement{
-			Error: FailedToPostError{Err: err},
+// postWithRetries forwards the event, retrying failed attempts according to
+// the configured retry policy. Every attempt is reported on its own, but only
+// the last one is reported to Stripe.
+func (c *EndpointClient) postWithRetries(evtCtx eventContext) (err error) {
+	defer func() {
+		if err != nil && c.cfg.FailureHandler != nil {
+			c.cfg.FailureHandler(evtCtx, c.URL, err)
 		}
-		return err
+	}()
+
+	policy := c.cfg.RetryPolicy
+
+	// the payload is transformed once for every attempt, and the original one
+	// is kept for the journal and the response reported to Stripe
+	evtCtx.forwardedBody = evtCtx.requestBody
+	if len(c.cfg.Transforms) > 0 {
+		body, err := transformPayload(c.cfg.Transforms, evtCtx.requestBody)
+		if err != nil {
+			c.cfg.Metrics.forwardFailed(c.URL)
+			c.cfg.OutCh <- websocket.ErrorElement{
+				Error: FailedToPostError{Err: err},
+			}
+			return err
+		}
+
+		evtCtx.forwardedBody = body
 	}
 
-	defer resp.Body.Close()
+	for attempt := 1; ; attempt++ {
+		evtCtx.attempt = attempt
 
-	c.cfg.ResponseHandler.ProcessResponse(evtCtx, c.URL, resp)
+		status, err := c.post(evtCtx)
+		if attempt >= policy.maxAttempts() || !policy.shouldRetry(status, err) {
+			return err
+		}
 
-	return nil
+		delay := policy.delay(attempt)
+
+		c.cfg.Log.WithFields(log.Fields{
+			"prefix":  "proxy.EndpointClient.postWithRetries",
+			"attempt": attempt,
+			"status":  status,
+		}).Debugf("Retrying forward in %s", delay)
+
+		c.cfg.OutCh <- websocket.DataElement{
+			Data: EndpointRetry{
+				Event:       evtCtx.event,
+				V2Event:     evtCtx.v2Event,
+				URL:         c.URL,
+				Status:      status,
+				Attempt:     attempt,
+				MaxAttempts: policy.maxAttempts(),
+				Delay:       delay,
+			},
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-c.ctx.Done():
+			return err
+		}
+	}
 }
 
-// PostV2 sends a message to a local event destination
-func (c *EndpointClient) PostV2(evtCtx eventContext) error {
-	req, err := http.NewRequest(http.MethodPost, c.URL, bytes.NewBuffer([]byte(evtCtx.requestBody)))
+// post makes a single delivery attempt and returns the response status.
+func (c *EndpointClient) post(evtCtx eventContext) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, c.URL, bytes.NewBuffer([]byte(evtCtx.forwardedBody)))
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	for k, v := range evtCtx.requestHeaders {
 		req.Header.Add(k, v)
 	}
 
+	if c.cfg.SigningSecret != nil {
+		if secret := c.cfg.SigningSecret(); secret != "" {
+			req.Header.Set(webhooks.SignatureHeader, webhooks.GenerateSignatureHeader(time.Now(), []byte(evtCtx.forwardedBody), secret))
+		}
+	}
+
 	// add custom headers
 	for k, v := range c.headers {
 		if strings.ToLower(k) == "host" {
@@ -156,18 +333,40 @@ func (c *EndpointClient) PostV2(evtCtx eventContext) error {
 		}
 	}
 
+	if c.cfg.OutCh != nil {
+		c.cfg.OutCh <- websocket.DataElement{
+			Data: EndpointAttempt{
+				Event:   evtCtx.event,
+				V2Event: evtCtx.v2Event,
+				URL:     c.URL,
+				Attempt: evtCtx.attempt,
+			},
+		}
+	}
+
+	evtCtx.sentAt = time.Now()
+	evtCtx.trace = newForwardTrace(evtCtx.sentAt)
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), evtCtx.trace.clientTrace()))
+
 	resp, err := c.cfg.HTTPClient.Do(req)
 	if err != nil {
+		c.cfg.Metrics.forwardFailed(c.URL)
 		c.cfg.OutCh <- websocket.ErrorElement{
 			Error: FailedToPostError{Err: err},
 		}
-		return err
+		return 0, err
 	}
+
 	defer resp.Body.Close()
 
+	c.cfg.Metrics.forwarded(c.URL, resp.StatusCode, time.Since(evtCtx.sentAt))
+
+	policy := c.cfg.RetryPolicy
+	evtCtx.lastAttempt = evtCtx.attempt >= policy.maxAttempts() || !policy.shouldRetry(resp.StatusCode, nil) || c.ctx.Err() != nil
+
 	c.cfg.ResponseHandler.ProcessResponse(evtCtx, c.URL, resp)
 
-	return nil
+	return resp.StatusCode, nil
 }
 
 //
@@ -189,7 +388,8 @@ func NewEndpointClient(url string, headers []string, connect bool, events []stri
 			CheckRedirect: func(req *http.Request, via []*http.Request) error {
 				return http.ErrUseLastResponse
 			},
-			Timeout: defaultTimeout,
+			Timeout:   defaultTimeout,
+			Transport: newEndpointTransport(url, false),
 		}
 	}
 
@@ -197,14 +397,24 @@ func NewEndpointClient(url string, headers []string, connect bool, events []stri
 		cfg.ResponseHandler = EndpointResponseHandlerFunc(func(eventContext, string, *http.Response) {})
 	}
 
-	return &EndpointClient{
+	ctx, cancel := context.WithCancel(context.Background())
+
+	client := &EndpointClient{
+		ctx:                ctx,
+		cancel:             cancel,
 		URL:                url,
 		headers:            convertToMapAndSanitize(headers),
 		connect:            connect,
-		events:             convertToMap(events),
+		events:             matcher.New(events),
 		isEventDestination: isEventDestination,
 		cfg:                cfg,
 	}
+
+	if cfg.Concurrency > 0 {
+		client.queue = newDeliveryQueue(cfg.Concurrency, cfg.QueueSize)
+	}
+
+	return client
 }
 
 //
@@ -1,13 +1,19 @@
 package proxy
 
 import (
+	"bytes"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/metrics"
+	"github.com/stripe/stripe-cli/pkg/webhooks"
 )
 
 func TestClientHandler(t *testing.T) {
@@ -250,3 +256,63 @@ func TestPostV2_UsesConfiguredHTTPClient(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestSupportsEventType(t *testing.T) {
+	client := NewEndpointClient("http://localhost", []string{}, false, []string{"invoice.*", "!invoice.upcoming", "charge.succeeded"}, false, nil)
+
+	require.True(t, client.SupportsEventType(false, "invoice.paid"))
+	require.True(t, client.SupportsEventType(false, "charge.succeeded"))
+	require.False(t, client.SupportsEventType(false, "invoice.upcoming"))
+	require.False(t, client.SupportsEventType(false, "charge.failed"))
+	require.False(t, client.SupportsEventType(true, "invoice.paid"))
+}
+
+func TestPost_ResignsEvents(t *testing.T) {
+	var signature string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get("Stripe-Signature")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	client := NewEndpointClient(ts.URL, []string{}, false, []string{"*"}, false, &EndpointConfig{
+		SigningSecret: func() string { return "whsec_test" },
+	})
+
+	err := client.Post(eventContext{
+		event:          &StripeEvent{Type: "charge.succeeded"},
+		requestBody:    `{"id":"evt_123"}`,
+		requestHeaders: map[string]string{"Stripe-Signature": "t=123,v1=hunter2"},
+	})
+	require.NoError(t, err)
+
+	var timestamp int64
+	_, err = fmt.Sscanf(signature, "t=%d,", &timestamp)
+	require.NoError(t, err)
+	require.Equal(t, webhooks.GenerateSignatureHeader(time.Unix(timestamp, 0), []byte(`{"id":"evt_123"}`), "whsec_test"), signature)
+}
+
+func TestPost_RecordsMetrics(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	registry := metrics.NewRegistry()
+
+	client := NewEndpointClient(ts.URL, []string{}, false, []string{"*"}, false, &EndpointConfig{
+		Metrics: NewMetrics(registry),
+	})
+
+	err := client.Post(eventContext{
+		event:       &StripeEvent{Type: "charge.succeeded"},
+		requestBody: `{"id":"evt_123"}`,
+	})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, registry.Write(&buf, false))
+	require.Contains(t, buf.String(), `stripe_listen_forwards_total{endpoint="`+ts.URL+`",result="failure"} 1`)
+	require.Contains(t, buf.String(), `stripe_listen_forward_duration_seconds_count{endpoint="`+ts.URL+`"} 1`)
+}
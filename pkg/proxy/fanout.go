@@ -0,0 +1,380 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+
+	"github.com/stripe/stripe-cli/pkg/errorcategory"
+	"github.com/stripe/stripe-cli/pkg/matcher"
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+const (
+	// fanOutReadyType is the type of the message sent to attached processes
+	// with the signing secret of the session
+	fanOutReadyType = "fanout_ready"
+
+	// fanOutBufferSize is the number of messages that can wait to be sent to
+	// an attached process before messages are dropped
+	fanOutBufferSize = 1000
+
+	// maxFanOutMessageSize is the size of the largest message attached
+	// processes can read
+	maxFanOutMessageSize = 16 * 1024 * 1024
+)
+
+// fanOutReady is sent to attached processes when they connect, and again
+// whenever the session is renewed.
+type fanOutReady struct {
+	Type           string `json:"type"`
+	Secret         string `json:"secret"`
+	DefaultVersion string `json:"default_version,omitempty"`
+	LatestVersion  string `json:"latest_version,omitempty"`
+}
+
+// FanOut relays the messages a proxy receives from Stripe to the processes
+// attached to it with `stripe listen --attach`, over a Unix domain socket.
+// Messages are written as JSON, one per line, like Stripe sends them. Its
+// methods are safe to call on a nil *FanOut, which relays nothing.
+type FanOut struct {
+	listener   net.Listener
+	log        *log.Logger
+	events     *matcher.Matcher
+	thinEvents *matcher.Matcher
+
+	mu          sync.Mutex
+	ready       []byte
+	subscribers map[*fanOutSubscriber]struct{}
+}
+
+type fanOutSubscriber struct {
+	conn     net.Conn
+	messages chan []byte
+	done     chan struct{}
+	once     sync.Once
+}
+
+func (s *fanOutSubscriber) close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.conn.Close()
+	})
+}
+
+// ListenFanOut creates the socket at path that other processes attach to,
+// which receive the snapshot events selected by events and the thin events
+// selected by thinEvents. A socket left behind by a process that exited is
+// replaced, but a socket served by a running process isn't.
+func ListenFanOut(path string, events, thinEvents []string, logger *log.Logger) (*FanOut, error) {
+	if logger == nil {
+		logger = &log.Logger{Out: io.Discard}
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, errorcategory.Errorf(errorcategory.UserInput, "another process is already serving events on %s", path)
+		}
+
+		if err := os.Remove(path); err != nil {
+			return nil, errorcategory.Errorf(errorcategory.UserInput, "failed to remove stale socket %s: %v", path, err)
+		}
+	}
+
+	// attached processes receive the webhook signing secret, so only the
+	// user running the daemon can attach
+	listener, err := listenUnixSocket(path)
+	if err != nil {
+		return nil, errorcategory.Errorf(errorcategory.UserInput, "failed to listen on %s: %v", path, err)
+	}
+
+	return &FanOut{
+		listener:    listener,
+		log:         logger,
+		events:      matcher.New(events),
+		thinEvents:  matcher.New(thinEvents),
+		subscribers: make(map[*fanOutSubscriber]struct{}),
+	}, nil
+}
+
+// Serve accepts attaching processes until ctx is done, then closes the
+// socket and disconnects them.
+func (f *FanOut) Serve(ctx context.Context) {
+	if f == nil {
+		return
+	}
+
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				f.log.WithFields(log.Fields{
+					"prefix": "proxy.FanOut.Serve",
+				}).Debugf("Failed to accept connection: %v", err)
+			}
+
+			return
+		}
+
+		f.attach(conn)
+	}
+}
+
+func (f *FanOut) attach(conn net.Conn) {
+	sub := &fanOutSubscriber{
+		conn:     conn,
+		messages: make(chan []byte, fanOutBufferSize),
+		done:     make(chan struct{}),
+	}
+
+	f.mu.Lock()
+	f.subscribers[sub] = struct{}{}
+	if f.ready != nil {
+		sub.messages <- f.ready
+	}
+	f.mu.Unlock()
+
+	f.log.WithFields(log.Fields{
+		"prefix": "proxy.FanOut.attach",
+	}).Debug("Process attached")
+
+	go func() {
+		defer f.detach(sub)
+
+		for {
+			select {
+			case msg := <-sub.messages:
+				if _, err := sub.conn.Write(msg); err != nil {
+					return
+				}
+			case <-sub.done:
+				return
+			}
+		}
+	}()
+
+	// attached processes don't send anything, so reads only end when they
+	// disconnect
+	go func() {
+		defer f.detach(sub)
+
+		io.Copy(io.Discard, conn) // #nosec G104
+	}()
+}
+
+func (f *FanOut) detach(sub *fanOutSubscriber) {
+	f.mu.Lock()
+	delete(f.subscribers, sub)
+	f.mu.Unlock()
+
+	sub.close()
+}
+
+// Publish relays a message received from Stripe to every attached process,
+// unless its event isn't selected. Messages are dropped for processes that
+// can't keep up, rather than holding up the proxy.
+func (f *FanOut) Publish(msg websocket.IncomingMessage) {
+	if f == nil {
+		return
+	}
+
+	var data []byte
+	var err error
+
+	// attached processes tell events apart by their type, like the
+	// websocket client does
+	switch {
+	case msg.WebhookEvent != nil:
+		if !f.events.Match(gjson.Get(msg.WebhookEvent.EventPayload, "type").String()) {
+			return
+		}
+
+		evt := *msg.WebhookEvent
+		evt.Type = "webhook_event"
+		data, err = json.Marshal(evt)
+	case msg.StripeV2Event != nil:
+		if !f.thinEvents.Match(gjson.Get(msg.StripeV2Event.Payload, "type").String()) {
+			return
+		}
+
+		evt := *msg.StripeV2Event
+		evt.Type = "v2_event"
+		data, err = json.Marshal(evt)
+	default:
+		return
+	}
+
+	if err != nil {
+		return
+	}
+
+	f.broadcast(append(data, '\n'))
+}
+
+// SetReady sends the signing secret and API versions of a new session to
+// every attached process, and to the ones attaching later.
+func (f *FanOut) SetReady(secret, defaultVersion, latestVersion string) {
+	if f == nil {
+		return
+	}
+
+	data, err := json.Marshal(fanOutReady{
+		Type:           fanOutReadyType,
+		Secret:         secret,
+		DefaultVersion: defaultVersion,
+		LatestVersion:  latestVersion,
+	})
+	if err != nil {
+		return
+	}
+
+	data = append(data, '\n')
+
+	f.mu.Lock()
+	f.ready = data
+	f.mu.Unlock()
+
+	f.broadcast(data)
+}
+
+func (f *FanOut) broadcast(data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for sub := range f.subscribers {
+		select {
+		case sub.messages <- data:
+		default:
+			f.log.WithFields(log.Fields{
+				"prefix": "proxy.FanOut.broadcast",
+			}).Warn("An attached process isn't keeping up, dropping a message")
+		}
+	}
+}
+
+// Close closes the socket and disconnects attached processes.
+func (f *FanOut) Close() {
+	if f == nil {
+		return
+	}
+
+	f.listener.Close()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for sub := range f.subscribers {
+		sub.close()
+		delete(f.subscribers, sub)
+	}
+}
+
+// attachment is the connection of an attached process to the proxy serving
+// events, which it's ready with once the session's secret was received.
+type attachment struct {
+	connected atomic.Bool
+}
+
+func (a *attachment) IsConnected() bool {
+	return a.connected.Load()
+}
+
+// runAttached receives events from the proxy serving the fan-out socket at
+// cfg.AttachSocket, instead of opening a session with Stripe. Events are
+// processed like events received from Stripe, but responses are only
+// reported locally.
+func (p *Proxy) runAttached(ctx context.Context) error {
+	path := p.cfg.AttachSocket
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "unix", path)
+	if err != nil {
+		err = errorcategory.Errorf(errorcategory.UserInput, "failed to attach to %s, is `stripe listen --fanout-socket %s` running? %v", path, path, err)
+		p.cfg.OutCh <- websocket.ErrorElement{
+			Error: err,
+		}
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	attached := &attachment{}
+	attached.connected.Store(true)
+	defer attached.connected.Store(false)
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), maxFanOutMessageSize)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+
+		if gjson.GetBytes(line, "type").String() == fanOutReadyType {
+			var ready fanOutReady
+			if err := json.Unmarshal(line, &ready); err != nil {
+				continue
+			}
+
+			p.attachReady(attached, ready)
+			continue
+		}
+
+		var msg websocket.IncomingMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			p.cfg.Log.WithFields(log.Fields{
+				"prefix": "proxy.Proxy.runAttached",
+			}).Debugf("Received malformed message, ignoring: %v", err)
+			continue
+		}
+
+		p.webhookEventProcessor.ProcessEvent(msg)
+	}
+
+	if ctx.Err() != nil {
+		p.cfg.OutCh <- &websocket.StateElement{
+			State: websocket.Done,
+		}
+		return nil
+	}
+
+	err = errorcategory.Errorf(errorcategory.UserInput, "the process serving events on %s stopped", path)
+	p.cfg.OutCh <- websocket.ErrorElement{
+		Error: err,
+	}
+	return err
+}
+
+func (p *Proxy) attachReady(conn connection, ready fanOutReady) {
+	p.webhookEventProcessor.SetSigningSecret(ready.Secret)
+
+	displayedAPIVersion := ""
+	if p.cfg.UseLatestAPIVersion && ready.LatestVersion != "" {
+		displayedAPIVersion = "You are using Stripe API Version [" + ready.LatestVersion + "]. "
+	} else if !p.cfg.UseLatestAPIVersion && ready.DefaultVersion != "" {
+		displayedAPIVersion = "You are using Stripe API Version [" + ready.DefaultVersion + "]. "
+	}
+
+	p.setReady(conn, ready.Secret)
+	p.writeSecretFile(ready.Secret)
+
+	p.cfg.OutCh <- websocket.StateElement{
+		State: websocket.Ready,
+		Data:  []string{"Attached to " + p.cfg.AttachSocket + ". " + displayedAPIVersion, ready.Secret},
+	}
+}
@@ -0,0 +1,274 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+// fanOutSocketPath returns a socket path short enough for the limits of Unix
+// domain sockets, which temporary test directories can exceed.
+func fanOutSocketPath(t *testing.T) string {
+	dir, err := os.MkdirTemp("", "fanout")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return filepath.Join(dir, "listen.sock")
+}
+
+func serveFanOut(t *testing.T, path string) *FanOut {
+	fanOut, err := ListenFanOut(path, []string{"*"}, []string{"*"}, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	go fanOut.Serve(ctx)
+
+	return fanOut
+}
+
+func TestFanOut_Publish(t *testing.T) {
+	path := fanOutSocketPath(t)
+	fanOut := serveFanOut(t, path)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	fanOut.SetReady("whsec_123", "2020-08-27", "2024-06-20")
+
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+
+	line, err := reader.ReadBytes('\n')
+	require.NoError(t, err)
+
+	var ready fanOutReady
+	require.NoError(t, json.Unmarshal(line, &ready))
+	require.Equal(t, fanOutReady{Type: fanOutReadyType, Secret: "whsec_123", DefaultVersion: "2020-08-27", LatestVersion: "2024-06-20"}, ready)
+
+	fanOut.Publish(websocket.IncomingMessage{
+		WebhookEvent: &websocket.WebhookEvent{
+			WebhookID:    "wh_123",
+			EventPayload: `{"id":"evt_123","type":"charge.succeeded"}`,
+		},
+	})
+	fanOut.Publish(websocket.IncomingMessage{
+		StripeV2Event: &websocket.StripeV2Event{
+			Payload: `{"id":"evt_456","type":"v1.billing.meter.no_meter_found"}`,
+		},
+	})
+
+	line, err = reader.ReadBytes('\n')
+	require.NoError(t, err)
+
+	var msg websocket.IncomingMessage
+	require.NoError(t, json.Unmarshal(line, &msg))
+	require.NotNil(t, msg.WebhookEvent)
+	require.Equal(t, "wh_123", msg.WebhookEvent.WebhookID)
+	require.Equal(t, `{"id":"evt_123","type":"charge.succeeded"}`, msg.WebhookEvent.EventPayload)
+
+	line, err = reader.ReadBytes('\n')
+	require.NoError(t, err)
+
+	msg = websocket.IncomingMessage{}
+	require.NoError(t, json.Unmarshal(line, &msg))
+	require.NotNil(t, msg.StripeV2Event)
+	require.Equal(t, `{"id":"evt_456","type":"v1.billing.meter.no_meter_found"}`, msg.StripeV2Event.Payload)
+
+	fanOut.Close()
+
+	_, err = reader.ReadBytes('\n')
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestFanOut_PublishSelectedEvents(t *testing.T) {
+	path := fanOutSocketPath(t)
+
+	fanOut, err := ListenFanOut(path, []string{"charge.*"}, nil, nil)
+	require.NoError(t, err)
+	defer fanOut.Close()
+
+	go fanOut.Serve(context.Background())
+
+	fanOut.SetReady("whsec_123", "", "")
+
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+
+	// the secret is sent once the process is attached
+	_, err = reader.ReadBytes('\n')
+	require.NoError(t, err)
+
+	fanOut.Publish(websocket.IncomingMessage{
+		WebhookEvent: &websocket.WebhookEvent{EventPayload: `{"id":"evt_1","type":"invoice.paid"}`},
+	})
+	fanOut.Publish(websocket.IncomingMessage{
+		StripeV2Event: &websocket.StripeV2Event{Payload: `{"id":"evt_2","type":"v1.billing.meter.no_meter_found"}`},
+	})
+	fanOut.Publish(websocket.IncomingMessage{
+		WebhookEvent: &websocket.WebhookEvent{EventPayload: `{"id":"evt_3","type":"charge.succeeded"}`},
+	})
+
+	line, err := reader.ReadBytes('\n')
+	require.NoError(t, err)
+
+	var msg websocket.IncomingMessage
+	require.NoError(t, json.Unmarshal(line, &msg))
+	require.NotNil(t, msg.WebhookEvent)
+	require.Equal(t, `{"id":"evt_3","type":"charge.succeeded"}`, msg.WebhookEvent.EventPayload)
+}
+
+func TestListenFanOut_Socket(t *testing.T) {
+	path := fanOutSocketPath(t)
+	serveFanOut(t, path)
+
+	_, err := ListenFanOut(path, []string{"*"}, []string{"*"}, nil)
+	require.Error(t, err)
+
+	// a socket nobody serves anymore is replaced
+	stale := fanOutSocketPath(t)
+	require.NoError(t, os.WriteFile(stale, nil, 0o600))
+
+	fanOut, err := ListenFanOut(stale, []string{"*"}, []string{"*"}, nil)
+	require.NoError(t, err)
+	fanOut.Close()
+}
+
+func TestFanOut_Nil(t *testing.T) {
+	var fanOut *FanOut
+
+	fanOut.Publish(websocket.IncomingMessage{WebhookEvent: &websocket.WebhookEvent{}})
+	fanOut.SetReady("whsec_123", "", "")
+	fanOut.Serve(context.Background())
+	fanOut.Close()
+}
+
+func TestProxy_RunAttached(t *testing.T) {
+	type forward struct {
+		signature string
+		body      string
+	}
+
+	forwards := make(chan forward, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		forwards <- forward{signature: r.Header.Get("Stripe-Signature"), body: string(body)}
+	}))
+	defer server.Close()
+
+	path := fanOutSocketPath(t)
+	fanOut := serveFanOut(t, path)
+	fanOut.SetReady("whsec_123", "2020-08-27", "")
+
+	outCh := make(chan websocket.IElement, 10)
+	p, err := Init(context.Background(), &Config{
+		ForwardURL:   server.URL,
+		Events:       []string{"charge.*"},
+		OutCh:        outCh,
+		AttachSocket: path,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Run(ctx)
+	}()
+
+	var ready websocket.StateElement
+	require.Eventually(t, func() bool {
+		for {
+			select {
+			case elem := <-outCh:
+				if state, ok := elem.(websocket.StateElement); ok && state.State == websocket.Ready {
+					ready = state
+					return true
+				}
+			default:
+				return false
+			}
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.Equal(t, []string{"Attached to " + path + ". You are using Stripe API Version [2020-08-27]. ", "whsec_123"}, ready.Data)
+	require.True(t, p.Health().Ready)
+
+	// events are filtered with the attached process's own --events
+	fanOut.Publish(websocket.IncomingMessage{
+		WebhookEvent: &websocket.WebhookEvent{
+			WebhookID:    "wh_456",
+			EventPayload: `{"id":"evt_456","type":"invoice.paid"}`,
+		},
+	})
+	fanOut.Publish(websocket.IncomingMessage{
+		WebhookEvent: &websocket.WebhookEvent{
+			WebhookID:    "wh_123",
+			EventPayload: `{"id":"evt_123","type":"charge.succeeded"}`,
+			HTTPHeaders:  map[string]string{"Stripe-Signature": "t=123,v1=abc"},
+		},
+	})
+
+	select {
+	case f := <-forwards:
+		require.Equal(t, "t=123,v1=abc", f.signature)
+		require.Contains(t, f.body, "evt_123")
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "The event wasn't forwarded")
+	}
+
+	// the proxy stops after the response was processed
+	require.Eventually(t, func() bool {
+		for {
+			select {
+			case elem := <-outCh:
+				if data, ok := elem.(websocket.DataElement); ok {
+					if _, ok := data.Data.(EndpointResponse); ok {
+						return true
+					}
+				}
+			default:
+				return false
+			}
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "The attached proxy didn't stop")
+	}
+}
+
+func TestProxy_RunAttached_NoDaemon(t *testing.T) {
+	outCh := make(chan websocket.IElement, 10)
+	p, err := Init(context.Background(), &Config{
+		OutCh:        outCh,
+		AttachSocket: fanOutSocketPath(t),
+	})
+	require.NoError(t, err)
+
+	require.Error(t, p.Run(context.Background()))
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+// +build !windows
+
+package proxy
+
+import (
+	"net"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// umaskMu serializes the changes of the process's umask made to create
+// sockets.
+var umaskMu sync.Mutex
+
+// listenUnixSocket creates a socket at path only the current user can
+// connect to. The socket is created with these permissions rather than
+// restricted afterwards, so that no other user can connect in between.
+func listenUnixSocket(path string) (net.Listener, error) {
+	umaskMu.Lock()
+	defer umaskMu.Unlock()
+
+	umask := unix.Umask(0o177)
+	defer unix.Umask(umask)
+
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,14 @@
+//go:build windows
+// +build windows
+
+package proxy
+
+import (
+	"net"
+)
+
+// listenUnixSocket creates a socket at path. Windows has no permission bits,
+// so access to the socket is controlled by the ACL of its directory.
+func listenUnixSocket(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
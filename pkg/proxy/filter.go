@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"github.com/stripe/stripe-cli/pkg/expr"
+)
+
+// PayloadFilter is a predicate evaluated against the JSON payload of an event,
+// written as an expression over the fields of the payload, e.g.:
+//
+//	data.object.metadata.tenant == "acme"
+//	data.object.amount > 10000 && data.object.currency != usd
+//	data.object.livemode
+//
+// See expr.Expression for the syntax of expressions.
+type PayloadFilter struct {
+	expression *expr.Expression
+}
+
+// ParsePayloadFilter parses a filter expression.
+func ParsePayloadFilter(filter string) (*PayloadFilter, error) {
+	expression, err := expr.Parse(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PayloadFilter{expression: expression}, nil
+}
+
+// ParsePayloadFilters parses a list of filter expressions.
+func ParsePayloadFilters(exprs []string) ([]*PayloadFilter, error) {
+	filters := make([]*PayloadFilter, 0, len(exprs))
+
+	for _, raw := range exprs {
+		filter, err := ParsePayloadFilter(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		filters = append(filters, filter)
+	}
+
+	return filters, nil
+}
+
+// String returns the expression the filter was parsed from.
+func (f *PayloadFilter) String() string {
+	return f.expression.String()
+}
+
+// Match reports whether the JSON payload satisfies the filter.
+func (f *PayloadFilter) Match(payload string) bool {
+	return f.expression.Match(payload)
+}
+
+// matchPayloadFilters reports whether the payload satisfies every filter.
+func matchPayloadFilters(filters []*PayloadFilter, payload string) bool {
+	for _, filter := range filters {
+		if !filter.Match(payload) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"io"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+const filterTestPayload = `{
+  "id": "evt_123",
+  "type": "payment_intent.succeeded",
+  "data": {
+    "object": {
+      "amount": 20000,
+      "livemode": false,
+      "description": null,
+      "metadata": {"tenant": "acme", "note": "a == b"}
+    }
+  }
+}`
+
+func TestPayloadFilterMatch(t *testing.T) {
+	tests := []struct {
+		expr  string
+		match bool
+	}{
+		{`data.object.metadata.tenant == "acme"`, true},
+		{`data.object.metadata.tenant == 'acme'`, true},
+		{`data.object.metadata.tenant == acme`, true},
+		{`data.object.metadata.tenant != "acme"`, false},
+		{`data.object.metadata.tenant == "globex"`, false},
+		{`data.object.metadata.note == "a == b"`, true},
+		{`data.object.amount > 10000`, true},
+		{`data.object.amount >= 20000`, true},
+		{`data.object.amount < 20000`, false},
+		{`data.object.amount <= 20000`, true},
+		{`data.object.amount == 20000`, true},
+		{`data.object.missing > 1`, false},
+		{`data.object.livemode == false`, true},
+		{`data.object.livemode == true`, false},
+		{`data.object.description == null`, true},
+		{`data.object.missing == null`, true},
+		{`data.object.metadata.tenant`, true},
+		{`data.object.livemode`, false},
+		{`data.object.description`, false},
+		{`data.object.missing`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			filter, err := ParsePayloadFilter(tt.expr)
+			require.NoError(t, err)
+			require.Equal(t, tt.match, filter.Match(filterTestPayload))
+		})
+	}
+}
+
+func TestParsePayloadFilter_Errors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"== 1",
+		"data.object.amount >",
+		`data.object.amount > "ten"`,
+		`data.object.metadata.tenant == "acme`,
+	} {
+		_, err := ParsePayloadFilter(expr)
+		require.Error(t, err, expr)
+	}
+}
+
+func TestWebhookEventProcessor_Filters(t *testing.T) {
+	filters, err := ParsePayloadFilters([]string{`data.object.metadata.tenant == "acme"`, "data.object.amount > 10000"})
+	require.NoError(t, err)
+
+	outCh := make(chan websocket.IElement, 2)
+	processor := NewWebhookEventProcessor(func(*websocket.OutgoingMessage) {}, nil, &WebhookEventProcessorConfig{
+		Log:     &log.Logger{Out: io.Discard},
+		Events:  []string{"*"},
+		OutCh:   outCh,
+		Filters: filters,
+	})
+
+	for _, payload := range []string{
+		`{"id":"evt_1","type":"charge.succeeded","data":{"object":{"amount":500,"metadata":{"tenant":"acme"}}}}`,
+		`{"id":"evt_2","type":"charge.succeeded","data":{"object":{"amount":50000,"metadata":{"tenant":"globex"}}}}`,
+		`{"id":"evt_3","type":"charge.succeeded","data":{"object":{"amount":50000,"metadata":{"tenant":"acme"}}}}`,
+	} {
+		processor.ProcessEvent(websocket.IncomingMessage{
+			WebhookEvent: &websocket.WebhookEvent{EventPayload: payload},
+		})
+	}
+
+	require.Len(t, outCh, 1)
+	require.Equal(t, "evt_3", (<-outCh).(websocket.DataElement).Data.(StripeEvent).ID)
+}
@@ -0,0 +1,273 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	grpcURLPrefix  = "grpc://"
+	grpcsURLPrefix = "grpcs://"
+
+	// defaultGRPCMethod is the method events are delivered to when the
+	// forward URL has no path
+	defaultGRPCMethod = "/stripe.webhooks.WebhookReceiver/Deliver"
+)
+
+// grpcTransport delivers requests to a gRPC server with a unary call. Servers
+// implement the following service, or any method with the same request and
+// response messages, which is then set as the path of the forward URL
+// (grpc://localhost:50051/my.package.Service/Method):
+//
+//	syntax = "proto3";
+//
+//	package stripe.webhooks;
+//
+//	service WebhookReceiver {
+//	  rpc Deliver(WebhookDelivery) returns (WebhookDeliveryResponse);
+//	}
+//
+//	message WebhookDelivery {
+//	  // payload is the JSON payload of the event
+//	  string payload = 1;
+//	  // headers are the headers of the webhook request, like Stripe-Signature
+//	  map<string, string> headers = 2;
+//	}
+//
+//	message WebhookDeliveryResponse {
+//	  // status is reported like an HTTP status code, 200 when unset
+//	  int32 status = 1;
+//	  string body = 2;
+//	}
+//
+// Calls that fail with a gRPC status are reported as responses with the
+// equivalent HTTP status code, and the status message as the body.
+type grpcTransport struct {
+	conn   *grpc.ClientConn
+	method string
+	err    error
+}
+
+func newGRPCTransport(forwardURL string, skipVerify bool) *grpcTransport {
+	u, err := url.Parse(forwardURL)
+	if err != nil {
+		return &grpcTransport{err: err}
+	}
+
+	creds := insecure.NewCredentials()
+	if u.Scheme == "grpcs" {
+		// #nosec G402: skipping verification is requested with --skip-verify
+		creds = credentials.NewTLS(&tls.Config{InsecureSkipVerify: skipVerify})
+	}
+
+	conn, err := grpc.NewClient(u.Host, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return &grpcTransport{err: err}
+	}
+
+	method := defaultGRPCMethod
+	if u.Path != "" && u.Path != "/" {
+		method = u.Path
+	}
+
+	return &grpcTransport{conn: conn, method: method}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *grpcTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+
+	var payload []byte
+	if req.Body != nil {
+		var err error
+
+		payload, err = io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	in := encodeWebhookDelivery(payload, req.Header)
+
+	var out []byte
+
+	statusCode := http.StatusOK
+	body := ""
+
+	err := t.conn.Invoke(req.Context(), t.method, in, &out, grpc.ForceCodec(rawCodec{}))
+	if err != nil {
+		st, ok := status.FromError(err)
+		if !ok || st.Code() == codes.Unavailable || st.Code() == codes.Canceled || st.Code() == codes.DeadlineExceeded {
+			return nil, err
+		}
+
+		statusCode = grpcCodeToHTTPStatus(st.Code())
+		body = st.Message()
+	} else {
+		statusCode, body, err = decodeWebhookDeliveryResponse(out)
+		if err != nil {
+			return nil, fmt.Errorf("invalid response from %s: %w", t.method, err)
+		}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"text/plain"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// Close closes the connection to the gRPC server.
+func (t *grpcTransport) Close() error {
+	if t.conn == nil {
+		return nil
+	}
+
+	return t.conn.Close()
+}
+
+// encodeWebhookDelivery encodes a WebhookDelivery message.
+func encodeWebhookDelivery(payload []byte, header http.Header) []byte {
+	var b []byte
+
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendBytes(b, payload)
+
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// map entries are messages with the key as field 1 and the value as
+	// field 2
+	for _, k := range keys {
+		var entry []byte
+		entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+		entry = protowire.AppendString(entry, k)
+		entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+		entry = protowire.AppendString(entry, strings.Join(header[k], ", "))
+
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+
+	return b
+}
+
+// decodeWebhookDeliveryResponse decodes a WebhookDeliveryResponse message,
+// skipping unknown fields.
+func decodeWebhookDeliveryResponse(b []byte) (int, string, error) {
+	statusCode := 0
+	body := ""
+
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return 0, "", protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return 0, "", protowire.ParseError(n)
+			}
+			statusCode = int(int32(v)) // #nosec G115: status is an int32 field
+			b = b[n:]
+		case num == 2 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			if n < 0 {
+				return 0, "", protowire.ParseError(n)
+			}
+			body = v
+			b = b[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return 0, "", protowire.ParseError(n)
+			}
+			b = b[n:]
+		}
+	}
+
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+
+	return statusCode, body, nil
+}
+
+// grpcCodeToHTTPStatus maps a gRPC status code to the closest HTTP status
+// code.
+func grpcCodeToHTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// rawCodec passes already encoded protobuf messages through, so that the
+// messages of the delivery service don't need generated code.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+
+	return b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+
+	*b = append((*b)[:0], data...)
+
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
@@ -0,0 +1,172 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+// decodeWebhookDelivery decodes the WebhookDelivery messages received by the
+// test server.
+func decodeWebhookDelivery(t *testing.T, b []byte) (string, map[string]string) {
+	payload := ""
+	headers := make(map[string]string)
+
+	for len(b) > 0 {
+		num, _, n := protowire.ConsumeTag(b)
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+
+		v, n := protowire.ConsumeBytes(b)
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+
+		switch num {
+		case 1:
+			payload = string(v)
+		case 2:
+			var key, value string
+			for len(v) > 0 {
+				entryNum, _, n := protowire.ConsumeTag(v)
+				require.GreaterOrEqual(t, n, 0)
+				v = v[n:]
+
+				s, n := protowire.ConsumeString(v)
+				require.GreaterOrEqual(t, n, 0)
+				v = v[n:]
+
+				if entryNum == 1 {
+					key = s
+				} else {
+					value = s
+				}
+			}
+			headers[key] = value
+		}
+	}
+
+	return payload, headers
+}
+
+func TestGRPCTransport(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	var method string
+	var payload string
+	var headers map[string]string
+
+	server := grpc.NewServer(
+		grpc.ForceServerCodec(rawCodec{}),
+		grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+			method, _ = grpc.MethodFromServerStream(stream)
+
+			var in []byte
+			if err := stream.RecvMsg(&in); err != nil {
+				return err
+			}
+
+			payload, headers = decodeWebhookDelivery(t, in)
+			if headers["X-Fail"] == "yes" {
+				return status.Error(codes.InvalidArgument, "invalid event")
+			}
+
+			var out []byte
+			out = protowire.AppendTag(out, 1, protowire.VarintType)
+			out = protowire.AppendVarint(out, http.StatusAccepted)
+			out = protowire.AppendTag(out, 2, protowire.BytesType)
+			out = protowire.AppendString(out, "OK!")
+
+			return stream.SendMsg(out)
+		}),
+	)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	tests := []struct {
+		name           string
+		path           string
+		headers        []string
+		expectedMethod string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"default method", "", []string{}, defaultGRPCMethod, http.StatusAccepted, "OK!"},
+		{"custom method", "/acme.Webhooks/Handle", []string{}, "/acme.Webhooks/Handle", http.StatusAccepted, "OK!"},
+		{"error status", "", []string{"X-Fail: yes"}, defaultGRPCMethod, http.StatusBadRequest, "invalid event"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rcvStatus int
+			var rcvBody string
+			var rcvURL string
+
+			forwardURL := "grpc://" + listener.Addr().String() + tt.path
+
+			client := NewEndpointClient(
+				parseURL(forwardURL),
+				tt.headers,
+				false,
+				[]string{"*"},
+				false,
+				&EndpointConfig{
+					ResponseHandler: EndpointResponseHandlerFunc(func(evtCtx eventContext, forwardURL string, resp *http.Response) {
+						buf, err := io.ReadAll(resp.Body)
+						require.NoError(t, err)
+
+						rcvStatus = resp.StatusCode
+						rcvBody = string(buf)
+						rcvURL = resp.Request.URL.String()
+					}),
+				},
+			)
+			defer client.Close()
+
+			err := client.Post(eventContext{
+				event:          &StripeEvent{ID: "evt_123"},
+				requestBody:    `{"id":"evt_123"}`,
+				requestHeaders: map[string]string{"Stripe-Signature": "t=123,v1=hunter2"},
+			})
+			require.NoError(t, err)
+
+			require.Equal(t, tt.expectedMethod, method)
+			require.Equal(t, `{"id":"evt_123"}`, payload)
+			require.Equal(t, "t=123,v1=hunter2", headers["Stripe-Signature"])
+			require.Equal(t, tt.expectedStatus, rcvStatus)
+			require.Equal(t, tt.expectedBody, rcvBody)
+			require.Equal(t, forwardURL, rcvURL)
+		})
+	}
+}
+
+func TestGRPCTransport_Unavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	client := NewEndpointClient(
+		parseURL("grpc://"+addr),
+		[]string{},
+		false,
+		[]string{"*"},
+		false,
+		&EndpointConfig{
+			OutCh: make(chan websocket.IElement, 10),
+		},
+	)
+	defer client.Close()
+
+	err = client.Post(eventContext{event: &StripeEvent{ID: "evt_123"}, requestBody: "{}"})
+	require.Error(t, err)
+}
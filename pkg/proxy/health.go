@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Health is the readiness of the proxy, as served by HealthHandler.
+type Health struct {
+	// Ready is true once the proxy is connected to Stripe and can receive
+	// events
+	Ready bool `json:"ready"`
+
+	// Secret is the webhook signing secret of the current session, set when
+	// the proxy is ready
+	Secret string `json:"secret,omitempty"`
+}
+
+// Health returns whether the proxy is connected to Stripe and ready to
+// receive events, along with the session's webhook signing secret.
+func (p *Proxy) Health() Health {
+	p.healthMu.RLock()
+	defer p.healthMu.RUnlock()
+
+	if p.readyClient == nil || p.readySecret == "" || !p.readyClient.IsConnected() {
+		return Health{}
+	}
+
+	return Health{
+		Ready:  true,
+		Secret: p.readySecret,
+	}
+}
+
+// HealthHandler returns a handler serving /healthz, which always succeeds
+// while the proxy runs, and /readyz, which fails with 503 Service
+// Unavailable until the proxy is ready. Both respond with the proxy's Health
+// as JSON.
+func (p *Proxy) HealthHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, http.StatusOK, p.Health())
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		health := p.Health()
+
+		status := http.StatusOK
+		if !health.Ready {
+			status = http.StatusServiceUnavailable
+		}
+
+		writeHealth(w, status, health)
+	})
+
+	return mux
+}
+
+// connection is the part of websocket.Client that readiness depends on.
+type connection interface {
+	IsConnected() bool
+}
+
+func (p *Proxy) setReady(client connection, secret string) {
+	p.healthMu.Lock()
+	defer p.healthMu.Unlock()
+
+	p.readyClient = client
+	p.readySecret = secret
+}
+
+func writeHealth(w http.ResponseWriter, status int, health Health) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(health) // #nosec G104
+}
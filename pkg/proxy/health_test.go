@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeConnection bool
+
+func (c fakeConnection) IsConnected() bool {
+	return bool(c)
+}
+
+func TestHealthHandler(t *testing.T) {
+	p := &Proxy{}
+	handler := p.HealthHandler()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get("/healthz")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"ready":false}`, rec.Body.String())
+
+	rec = get("/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.JSONEq(t, `{"ready":false}`, rec.Body.String())
+
+	p.setReady(fakeConnection(true), "whsec_123")
+
+	rec = get("/readyz")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	require.JSONEq(t, `{"ready":true,"secret":"whsec_123"}`, rec.Body.String())
+
+	// a dropped connection isn't ready until it's re-established
+	p.setReady(fakeConnection(false), "whsec_123")
+
+	rec = get("/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/stripe/stripe-cli/pkg/errorcategory"
+)
+
+//
+// Public types
+//
+
+// JournalEntryKind describes what a journal entry records.
+type JournalEntryKind string
+
+const (
+	// JournalEntryEvent is written when an event is received from Stripe
+	JournalEntryEvent JournalEntryKind = "event"
+
+	// JournalEntryResponse is written when a local endpoint responds to a forwarded event
+	JournalEntryResponse JournalEntryKind = "response"
+)
+
+// JournalEntry is a single line of the on-disk event journal.
+type JournalEntry struct {
+	Kind      JournalEntryKind `json:"kind"`
+	Timestamp time.Time        `json:"timestamp"`
+
+	EventID   string `json:"event_id"`
+	EventType string `json:"event_type"`
+
+	// Thin indicates whether the entry belongs to a thin (v2) event
+	Thin bool `json:"thin,omitempty"`
+
+	WebhookID             string `json:"webhook_id,omitempty"`
+	WebhookConversationID string `json:"webhook_conversation_id,omitempty"`
+
+	// Payload and HTTPHeaders are the event as delivered by Stripe
+	Payload     string            `json:"payload,omitempty"`
+	HTTPHeaders map[string]string `json:"http_headers,omitempty"`
+
+	// ForwardURL, Status, ResponseBody and Attempt describe the local endpoint's response
+	ForwardURL   string `json:"forward_url,omitempty"`
+	Status       int    `json:"status,omitempty"`
+	ResponseBody string `json:"response_body,omitempty"`
+	Attempt      int    `json:"attempt,omitempty"`
+}
+
+// Journal appends received events and endpoint responses to a newline
+// delimited JSON file so they can be inspected or replayed later.
+type Journal struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+//
+// Public functions
+//
+
+// OpenJournal opens (or creates) the journal at path for appending.
+func OpenJournal(path string) (*Journal, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errorcategory.Errorf(errorcategory.UserInput, "failed to open journal file: %v", err)
+	}
+
+	return &Journal{file: file}, nil
+}
+
+// ReadJournal reads every entry of the journal at path, in the order they were written.
+func ReadJournal(path string) ([]JournalEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errorcategory.Errorf(errorcategory.UserInput, "failed to open journal file: %v", err)
+	}
+	defer file.Close()
+
+	var entries []JournalEntry
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxJournalLineSize)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, errorcategory.Errorf(errorcategory.UserInput, "malformed journal entry on line %d: %v", line, err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Write appends an entry to the journal. It is safe for concurrent use.
+func (j *Journal) Write(entry JournalEntry) error {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	_, err = j.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the underlying journal file.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.file.Close()
+}
+
+//
+// Private constants
+//
+
+// Event payloads can be large, allow journal lines up to 16MB.
+const maxJournalLineSize = 16 * 1024 * 1024
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+func TestJournalRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+
+	journal, err := OpenJournal(path)
+	require.NoError(t, err)
+
+	require.NoError(t, journal.Write(JournalEntry{
+		Kind:        JournalEntryEvent,
+		EventID:     "evt_123",
+		EventType:   "charge.succeeded",
+		Payload:     `{"id":"evt_123"}`,
+		HTTPHeaders: map[string]string{"Stripe-Signature": "t=123,v1=hunter2"},
+	}))
+	require.NoError(t, journal.Write(JournalEntry{
+		Kind:         JournalEntryResponse,
+		EventID:      "evt_123",
+		ForwardURL:   "http://localhost:4242",
+		Status:       500,
+		ResponseBody: "boom",
+	}))
+	require.NoError(t, journal.Close())
+
+	entries, err := ReadJournal(path)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	require.Equal(t, JournalEntryEvent, entries[0].Kind)
+	require.Equal(t, "evt_123", entries[0].EventID)
+	require.Equal(t, `{"id":"evt_123"}`, entries[0].Payload)
+	require.Equal(t, "t=123,v1=hunter2", entries[0].HTTPHeaders["Stripe-Signature"])
+	require.False(t, entries[0].Timestamp.IsZero())
+
+	require.Equal(t, JournalEntryResponse, entries[1].Kind)
+	require.Equal(t, 500, entries[1].Status)
+	require.Equal(t, "boom", entries[1].ResponseBody)
+}
+
+func TestReadJournal_Malformed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	require.NoError(t, os.WriteFile(path, []byte("{\"kind\":\"event\"}\nnot json\n"), 0600))
+
+	_, err := ReadJournal(path)
+	require.ErrorContains(t, err, "line 2")
+}
+
+func TestWebhookEventProcessor_WritesJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+
+	journal, err := OpenJournal(path)
+	require.NoError(t, err)
+
+	outCh := make(chan websocket.IElement, 2)
+	processor := NewWebhookEventProcessor(func(*websocket.OutgoingMessage) {}, nil, &WebhookEventProcessorConfig{
+		Log:     &log.Logger{Out: io.Discard},
+		Events:  []string{"*"},
+		OutCh:   outCh,
+		Journal: journal,
+	})
+
+	processor.ProcessEvent(websocket.IncomingMessage{
+		WebhookEvent: &websocket.WebhookEvent{
+			EventPayload:          `{"id":"evt_123","type":"charge.succeeded"}`,
+			HTTPHeaders:           map[string]string{"Stripe-Signature": "t=123,v1=hunter2"},
+			WebhookConversationID: "wc_123",
+			WebhookID:             "wh_123",
+		},
+	})
+	require.NoError(t, journal.Close())
+
+	entries, err := ReadJournal(path)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "evt_123", entries[0].EventID)
+	require.Equal(t, "charge.succeeded", entries[0].EventType)
+	require.Equal(t, "wh_123", entries[0].WebhookID)
+	require.Equal(t, "wc_123", entries[0].WebhookConversationID)
+}
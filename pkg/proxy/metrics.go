@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"time"
+
+	"github.com/stripe/stripe-cli/pkg/metrics"
+)
+
+const (
+	forwardResultSuccess = "success"
+	forwardResultFailure = "failure"
+)
+
+// Metrics are the metrics reported by the proxy. A nil *Metrics reports
+// nothing.
+type Metrics struct {
+	eventsReceived  *metrics.Counter
+	forwards        *metrics.Counter
+	forwardDuration *metrics.Histogram
+	reconnects      *metrics.Counter
+	lastEvent       *metrics.Gauge
+}
+
+// NewMetrics registers the proxy's metrics with registry.
+func NewMetrics(registry *metrics.Registry) *Metrics {
+	return &Metrics{
+		eventsReceived: registry.NewCounter(
+			"stripe_listen_events_received",
+			"Number of events received from Stripe.",
+			"type",
+		),
+		forwards: registry.NewCounter(
+			"stripe_listen_forwards",
+			"Number of attempts to forward an event to a local endpoint. Responses with a non-2xx status and requests that couldn't be sent are failures.",
+			"endpoint", "result",
+		),
+		forwardDuration: registry.NewHistogram(
+			"stripe_listen_forward_duration_seconds",
+			"Time taken by local endpoints to respond to forwarded events.",
+			nil,
+			"endpoint",
+		),
+		reconnects: registry.NewCounter(
+			"stripe_listen_websocket_reconnects",
+			"Number of times the websocket connection to Stripe was re-established.",
+			"reason",
+		),
+		lastEvent: registry.NewGauge(
+			"stripe_listen_last_event_timestamp_seconds",
+			"Unix time at which the last event was received from Stripe.",
+		),
+	}
+}
+
+func (m *Metrics) eventReceived(eventType string) {
+	if m == nil {
+		return
+	}
+
+	m.eventsReceived.Inc(eventType)
+	m.lastEvent.Set(float64(time.Now().UnixNano()) / float64(time.Second))
+}
+
+func (m *Metrics) forwarded(endpoint string, status int, latency time.Duration) {
+	if m == nil {
+		return
+	}
+
+	result := forwardResultFailure
+	if status >= 200 && status < 300 {
+		result = forwardResultSuccess
+	}
+
+	m.forwards.Inc(endpoint, result)
+	m.forwardDuration.Observe(latency.Seconds(), endpoint)
+}
+
+func (m *Metrics) forwardFailed(endpoint string) {
+	if m == nil {
+		return
+	}
+
+	m.forwards.Inc(endpoint, forwardResultFailure)
+}
+
+func (m *Metrics) reconnected(reason string) {
+	if m == nil {
+		return
+	}
+
+	m.reconnects.Inc(reason)
+}
@@ -0,0 +1,243 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"gopkg.in/yaml.v3"
+
+	"github.com/stripe/stripe-cli/pkg/errorcategory"
+	"github.com/stripe/stripe-cli/pkg/matcher"
+)
+
+// mockEndpointURL is the URL reported for the responses of a MockResponder
+const mockEndpointURL = "mock:"
+
+// MockResponse is a response returned to Stripe by a MockResponder.
+type MockResponse struct {
+	// Events is the list of event types, or globs like invoice.*, the
+	// response is returned for. Every event type matches when empty.
+	Events []string `json:"events" yaml:"events"`
+
+	// Status is the response status (default: 200)
+	Status int `json:"status" yaml:"status"`
+
+	// Delay, like 35s, is how long to wait before responding
+	Delay string `json:"delay" yaml:"delay"`
+
+	// Body is the response body. It's sent as application/json when it is
+	// valid JSON, and as text/plain otherwise.
+	Body string `json:"body" yaml:"body"`
+
+	// Headers are added to the response
+	Headers map[string]string `json:"headers" yaml:"headers"`
+
+	// Times is the number of events the response is returned for, after
+	// which the next matching response is used. 0 means unlimited.
+	Times int `json:"times" yaml:"times"`
+}
+
+// mockResponseScript is the format of the file passed to
+// `stripe listen --respond-script`. Responses are matched in order:
+//
+//	responses:
+//	  - events: ["invoice.*"]
+//	    status: 500
+//	    body: '{"error": "boom"}'
+//	    times: 2
+//	  - events: ["customer.subscription.created"]
+//	    delay: 35s
+//	  - status: 200
+type mockResponseScript struct {
+	Responses []MockResponse `json:"responses" yaml:"responses"`
+}
+
+type mockRule struct {
+	response MockResponse
+	events   *matcher.Matcher
+	delay    time.Duration
+	used     int
+}
+
+// MockResponder is an http.RoundTripper answering forwarded events itself
+// with configured responses, instead of sending them to a local endpoint. It
+// lets you see how Stripe handles failing or slow endpoints.
+type MockResponder struct {
+	mu       sync.Mutex
+	rules    []*mockRule
+	fallback *mockRule
+}
+
+// NewMockResponder returns a MockResponder returning the responses of
+// scriptPath, when set, for the events they match, and responding to other
+// events with status after delay.
+func NewMockResponder(status int, delay time.Duration, scriptPath string) (*MockResponder, error) {
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if status < 100 || status > 599 {
+		return nil, errorcategory.Errorf(errorcategory.UserInput, "invalid response status %d", status)
+	}
+
+	if delay < 0 {
+		return nil, errorcategory.New(errorcategory.UserInput, "the response delay cannot be negative")
+	}
+
+	responder := &MockResponder{
+		fallback: &mockRule{
+			response: MockResponse{Status: status},
+			events:   matcher.New([]string{"*"}),
+			delay:    delay,
+		},
+	}
+
+	if scriptPath == "" {
+		return responder, nil
+	}
+
+	rules, err := readMockResponseScript(scriptPath)
+	if err != nil {
+		return nil, err
+	}
+
+	responder.rules = rules
+
+	return responder, nil
+}
+
+func readMockResponseScript(path string) ([]*mockRule, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errorcategory.Errorf(errorcategory.UserInput, "failed to read response script: %v", err)
+	}
+
+	var script mockResponseScript
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(content, &script)
+	} else {
+		err = yaml.Unmarshal(content, &script)
+	}
+	if err != nil {
+		return nil, errorcategory.Errorf(errorcategory.UserInput, "failed to parse response script %s: %v", path, err)
+	}
+
+	if len(script.Responses) == 0 {
+		return nil, errorcategory.Errorf(errorcategory.UserInput, "response script %s does not define any responses", path)
+	}
+
+	rules := make([]*mockRule, 0, len(script.Responses))
+
+	for i, response := range script.Responses {
+		if response.Status == 0 {
+			response.Status = http.StatusOK
+		}
+
+		if response.Status < 100 || response.Status > 599 {
+			return nil, errorcategory.Errorf(errorcategory.UserInput, "response %d in %s has an invalid status %d", i+1, path, response.Status)
+		}
+
+		var delay time.Duration
+		if response.Delay != "" {
+			delay, err = time.ParseDuration(response.Delay)
+			if err != nil || delay < 0 {
+				return nil, errorcategory.Errorf(errorcategory.UserInput, "response %d in %s has an invalid delay %q", i+1, path, response.Delay)
+			}
+		}
+
+		events := response.Events
+		if len(events) == 0 {
+			events = []string{"*"}
+		}
+
+		rules = append(rules, &mockRule{
+			response: response,
+			events:   matcher.New(events),
+			delay:    delay,
+		})
+	}
+
+	return rules, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (m *MockResponder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var eventType string
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		eventType = gjson.GetBytes(body, "type").String()
+	}
+
+	rule := m.match(eventType)
+
+	if rule.delay > 0 {
+		timer := time.NewTimer(rule.delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+
+	contentType := "text/plain"
+	if rule.response.Body != "" && json.Valid([]byte(rule.response.Body)) {
+		contentType = "application/json"
+	}
+
+	header := http.Header{"Content-Type": []string{contentType}}
+	for k, v := range rule.response.Headers {
+		header.Set(k, v)
+	}
+
+	status := rule.response.Status
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(rule.response.Body)),
+		ContentLength: int64(len(rule.response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// match returns the first rule matching eventType that wasn't used up, or
+// the fallback rule.
+func (m *MockResponder) match(eventType string) *mockRule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, rule := range m.rules {
+		if !rule.events.Match(eventType) {
+			continue
+		}
+
+		if rule.response.Times > 0 && rule.used >= rule.response.Times {
+			continue
+		}
+
+		rule.used++
+
+		return rule
+	}
+
+	return m.fallback
+}
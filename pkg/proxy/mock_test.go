@@ -0,0 +1,167 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+func mockRequest(t *testing.T, eventType string) *http.Request {
+	req, err := http.NewRequest(http.MethodPost, mockEndpointURL, strings.NewReader(`{"id":"evt_123","type":"`+eventType+`"}`))
+	require.NoError(t, err)
+	return req
+}
+
+func TestMockResponder(t *testing.T) {
+	responder, err := NewMockResponder(503, 0, "")
+	require.NoError(t, err)
+
+	resp, err := responder.RoundTrip(mockRequest(t, "charge.succeeded"))
+	require.NoError(t, err)
+	require.Equal(t, 503, resp.StatusCode)
+	require.Equal(t, "503 Service Unavailable", resp.Status)
+
+	responder, err = NewMockResponder(0, 0, "")
+	require.NoError(t, err)
+
+	resp, err = responder.RoundTrip(mockRequest(t, "charge.succeeded"))
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+
+	_, err = NewMockResponder(1000, 0, "")
+	require.Error(t, err)
+
+	_, err = NewMockResponder(200, -time.Second, "")
+	require.Error(t, err)
+}
+
+func TestMockResponder_Script(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "responses.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+responses:
+  - events: ["invoice.*"]
+    status: 500
+    body: '{"error": "boom"}'
+    headers:
+      X-Request-Id: req_123
+    times: 2
+  - events: ["customer.*"]
+    status: 400
+    body: bad request
+`), 0600))
+
+	responder, err := NewMockResponder(202, 0, path)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		resp, err := responder.RoundTrip(mockRequest(t, "invoice.paid"))
+		require.NoError(t, err)
+		require.Equal(t, 500, resp.StatusCode)
+		require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		require.Equal(t, "req_123", resp.Header.Get("X-Request-Id"))
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, `{"error": "boom"}`, string(body))
+	}
+
+	// the invoice response was used up
+	resp, err := responder.RoundTrip(mockRequest(t, "invoice.paid"))
+	require.NoError(t, err)
+	require.Equal(t, 202, resp.StatusCode)
+
+	resp, err = responder.RoundTrip(mockRequest(t, "customer.created"))
+	require.NoError(t, err)
+	require.Equal(t, 400, resp.StatusCode)
+	require.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+}
+
+func TestMockResponder_InvalidScript(t *testing.T) {
+	dir := t.TempDir()
+
+	for name, content := range map[string]string{
+		"empty.yaml":   "responses: []\n",
+		"status.yaml":  "responses:\n  - status: 1000\n",
+		"delay.yaml":   "responses:\n  - delay: soon\n",
+		"invalid.json": "{",
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+		_, err := NewMockResponder(200, 0, path)
+		require.Error(t, err, name)
+	}
+
+	_, err := NewMockResponder(200, 0, filepath.Join(dir, "nope.yaml"))
+	require.Error(t, err)
+}
+
+func TestMockResponder_DelayIsCanceled(t *testing.T) {
+	responder, err := NewMockResponder(200, time.Hour, "")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = responder.RoundTrip(mockRequest(t, "charge.succeeded").WithContext(ctx))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestInitWithMockResponses(t *testing.T) {
+	_, err := Init(context.Background(), &Config{ForwardURL: "localhost:3000", RespondWith: 500})
+	require.Error(t, err)
+
+	p, err := Init(context.Background(), &Config{RespondWith: 500})
+	require.NoError(t, err)
+
+	clients := p.webhookEventProcessor.endpointClients
+	require.Len(t, clients, 2)
+	require.Equal(t, mockEndpointURL, clients[0].URL)
+	require.True(t, clients[0].SupportsEventType(false, "charge.succeeded"))
+	require.True(t, clients[1].SupportsEventType(true, "charge.succeeded"))
+}
+
+func TestWebhookEventProcessor_RespondsWithMockResponses(t *testing.T) {
+	responder, err := NewMockResponder(500, 0, "")
+	require.NoError(t, err)
+
+	responses := make(chan *websocket.WebhookResponse, 1)
+	sendMessage := func(msg *websocket.OutgoingMessage) {
+		if msg.WebhookResponse != nil {
+			responses <- msg.WebhookResponse
+		}
+	}
+
+	processor := NewWebhookEventProcessor(sendMessage, buildMockRoutes(&Config{Events: []string{"*"}}, responder), &WebhookEventProcessorConfig{
+		Log:    &log.Logger{Out: io.Discard},
+		Events: []string{"*"},
+		OutCh:  make(chan websocket.IElement, 10),
+	})
+
+	processor.ProcessEvent(websocket.IncomingMessage{
+		WebhookEvent: &websocket.WebhookEvent{
+			WebhookID:             "wh_123",
+			WebhookConversationID: "wc_123",
+			EventPayload:          `{"id":"evt_123","type":"charge.succeeded"}`,
+		},
+	})
+
+	select {
+	case resp := <-responses:
+		require.Equal(t, "wh_123", resp.WebhookID)
+		require.Equal(t, mockEndpointURL, resp.ForwardURL)
+		require.Equal(t, 500, resp.Status)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "No response was sent to Stripe")
+	}
+}
@@ -10,16 +10,22 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
 
 	"github.com/stripe/stripe-cli/pkg/ansi"
 	"github.com/stripe/stripe-cli/pkg/config"
 	"github.com/stripe/stripe-cli/pkg/errorcategory"
+	"github.com/stripe/stripe-cli/pkg/matcher"
+	"github.com/stripe/stripe-cli/pkg/metrics"
 	"github.com/stripe/stripe-cli/pkg/requests"
 	"github.com/stripe/stripe-cli/pkg/stripe"
 	"github.com/stripe/stripe-cli/pkg/stripeauth"
+	"github.com/stripe/stripe-cli/pkg/webhooks"
 	"github.com/stripe/stripe-cli/pkg/websocket"
 )
 
@@ -46,6 +52,14 @@ type EndpointRoute struct {
 
 	// IsEventDestination indicates whether this is a Thin endpoint
 	IsEventDestination bool
+
+	// Timeout, when set, overrides the time to wait for the endpoint to
+	// respond
+	Timeout time.Duration
+
+	// Responder, when set, answers the events of the route instead of the
+	// endpoint at URL
+	Responder *MockResponder
 }
 
 // EndpointResponse describes the response to a Stripe event from an endpoint
@@ -53,6 +67,30 @@ type EndpointResponse struct {
 	Event   *StripeEvent
 	V2Event *V2EventPayload
 	Resp    *http.Response
+
+	// Attempt is the delivery attempt this response belongs to, starting at 1
+	Attempt int
+
+	// RequestBody is the event payload, as delivered by Stripe
+	RequestBody string
+
+	// ForwardedBody is the payload posted to the endpoint, which differs from
+	// RequestBody when transforms rewrote it
+	ForwardedBody string
+
+	// RequestHeaders are the headers Stripe delivered the event with, before
+	// custom headers were added
+	RequestHeaders map[string]string
+
+	// ResponseBody is the endpoint's response body, truncated if too long
+	ResponseBody string
+
+	// Latency is the time between sending the request and reading the
+	// endpoint's full response
+	Latency time.Duration
+
+	// Timing breaks Latency down into the phases of the request
+	Timing ForwardTiming
 }
 
 // FailedToReadResponseError describes a failure to read the response from an endpoint
@@ -109,14 +147,92 @@ type Config struct {
 	Log *log.Logger
 	// Force use of unencrypted ws:// protocol instead of wss://
 	NoWSS bool
-	// Override default timeout
+	// Override default timeout, in seconds
 	Timeout int64
+	// ForwardTimeout is the time to wait for local endpoints to respond. It
+	// takes precedence over Timeout when set (default: 30s)
+	ForwardTimeout time.Duration
 
 	// OutCh is the channel to send logs and statuses to for processing in other packages
 	OutCh chan websocket.IElement
 
 	// LoggedInAccountID is the currently logged-in account ID
 	LoggedInAccountID string
+
+	// JournalPath is the file received events and endpoint responses are appended to
+	JournalPath string
+
+	// RetryPolicy controls how forwards that fail are retried
+	RetryPolicy RetryPolicy
+
+	// DeliveryConcurrency is the maximum number of events forwarded to each
+	// endpoint at the same time. When set to 1, events are delivered one at a
+	// time in the order they were received. 0 means unlimited.
+	DeliveryConcurrency int
+
+	// DeliveryQueueSize is the number of events that can wait for delivery to
+	// each endpoint before the proxy stops reading new events. Events that are
+	// still queued when Run returns are delivered before it does.
+	DeliveryQueueSize int
+
+	// RoutesFile is a YAML or JSON file mapping event types to local endpoints
+	RoutesFile string
+
+	// Filters are payload predicates, like data.object.amount > 1000, that
+	// events must all satisfy to be printed and forwarded
+	Filters []string
+
+	// ResignEvents indicates whether to re-compute the Stripe-Signature header
+	// of forwarded events, with SigningSecret or the session's signing secret
+	ResignEvents bool
+
+	// SigningSecret is a webhook signing secret (whsec_...) used instead of
+	// the session's secret to re-sign forwarded events
+	SigningSecret string
+
+	// Metrics, when set, is the registry the proxy reports its metrics to
+	Metrics *metrics.Registry
+
+	// SecretFile, when set, is a dotenv file the session's webhook signing
+	// secret is written to once the proxy is ready
+	SecretFile string
+	// SecretEnvVar is the variable the signing secret is written to in
+	// SecretFile (default: STRIPE_WEBHOOK_SECRET)
+	SecretEnvVar string
+
+	// CatchUp indicates whether to fetch the snapshot events missed while
+	// disconnected from the Events API after reconnecting. When JournalPath
+	// is set, the events missed since the journal's last event are also
+	// caught up on startup.
+	CatchUp bool
+
+	// Dedupe indicates whether to suppress the deliveries of events that were
+	// already forwarded, like when Stripe retries a delivery, instead of
+	// forwarding them every time
+	Dedupe bool
+
+	// RespondWith, RespondDelay and ResponseScript make the proxy answer
+	// events itself instead of forwarding them. Events are answered with the
+	// responses of ResponseScript they match, or with the RespondWith status
+	// (default: 200) after RespondDelay.
+	RespondWith    int
+	RespondDelay   time.Duration
+	ResponseScript string
+
+	// Transforms are expressions, like data.object.customer = "cus_123" or
+	// del(data.object.metadata), rewriting the payload of forwarded events
+	Transforms []string
+	// TransformCommand is a command the payload of forwarded events is piped
+	// through, after Transforms are applied
+	TransformCommand string
+
+	// FanOutSocket, when set, is the path of a Unix domain socket other
+	// processes attach to, to receive the events of this proxy's session
+	FanOutSocket string
+	// AttachSocket, when set, is the FanOutSocket of another proxy this
+	// proxy receives events from, instead of opening its own session with
+	// Stripe
+	AttachSocket string
 }
 
 // A Proxy opens a websocket connection with Stripe, listens for incoming
@@ -128,6 +244,19 @@ type Proxy struct {
 	stripeAuthClient      *stripeauth.Client
 	webSocketClient       *websocket.Client
 	webhookEventProcessor *WebhookEventProcessor
+	journal               *Journal
+	metrics               *Metrics
+	fanOut                *FanOut
+
+	healthMu    sync.RWMutex
+	readyClient connection
+	readySecret string
+
+	catchUpMu sync.Mutex
+
+	// catchUpPending is set when events may have been missed, after a restart
+	// or a disconnection, and cleared by the next connection catching up
+	catchUpPending atomic.Bool
 }
 
 const maxConnectAttempts = 3
@@ -152,10 +281,25 @@ func (p *Proxy) sendMessage(msg *websocket.OutgoingMessage) {
 func (p *Proxy) Run(ctx context.Context) error {
 	defer close(p.cfg.OutCh)
 
+	if p.journal != nil {
+		defer p.journal.Close()
+	}
+
+	defer p.webhookEventProcessor.Close()
+
 	p.cfg.OutCh <- websocket.StateElement{
 		State: websocket.Loading,
 	}
 
+	if p.cfg.AttachSocket != "" {
+		return p.runAttached(ctx)
+	}
+
+	if p.fanOut != nil {
+		defer p.fanOut.Close()
+		go p.fanOut.Serve(ctx)
+	}
+
 	nAttempts := 0
 
 	for nAttempts < maxConnectAttempts {
@@ -168,6 +312,7 @@ func (p *Proxy) Run(ctx context.Context) error {
 		}
 
 		*p.cfg.DeviceToken = session.DeviceToken
+		p.webhookEventProcessor.SetSigningSecret(session.Secret)
 		p.webSocketClient = websocket.NewClient(
 			session.WebSocketURL,
 			session.WebSocketID,
@@ -176,12 +321,23 @@ func (p *Proxy) Run(ctx context.Context) error {
 				Log:               p.cfg.Log,
 				NoWSS:             p.cfg.NoWSS,
 				ReconnectInterval: time.Duration(session.ReconnectDelay) * time.Second,
-				EventHandler:      p.webhookEventProcessor,
+				EventHandler: websocket.EventHandlerFunc(func(msg websocket.IncomingMessage) {
+					p.fanOut.Publish(msg)
+					p.webhookEventProcessor.ProcessEvent(msg)
+				}),
+				// events must reach the delivery queues in the order they were received
+				ProcessEventsInOrder: p.cfg.DeliveryConcurrency > 0,
+				OnReconnect:          p.onReconnect,
+				OnConnect: func() {
+					p.onConnect(ctx)
+				},
 			},
 		)
 
+		wsClient := p.webSocketClient
+
 		go func() {
-			<-p.webSocketClient.Connected()
+			<-wsClient.Connected()
 			nAttempts = 0
 
 			displayedAPIVersion := ""
@@ -191,6 +347,10 @@ func (p *Proxy) Run(ctx context.Context) error {
 				displayedAPIVersion = "You are using Stripe API Version [" + session.DefaultVersion + "]. "
 			}
 
+			p.setReady(wsClient, session.Secret)
+			p.writeSecretFile(session.Secret)
+			p.fanOut.SetReady(session.Secret, session.DefaultVersion, session.LatestVersion)
+
 			p.cfg.OutCh <- websocket.StateElement{
 				State: websocket.Ready,
 				Data:  []string{displayedAPIVersion, session.Secret},
@@ -207,7 +367,11 @@ func (p *Proxy) Run(ctx context.Context) error {
 			}
 			return nil
 		case <-p.webSocketClient.NotifyExpired:
+			p.setReady(nil, "")
+			p.catchUpPending.Store(true)
+
 			if nAttempts < maxConnectAttempts {
+				p.metrics.reconnected("expired")
 				p.cfg.OutCh <- &websocket.StateElement{
 					State: websocket.Reconnecting,
 				}
@@ -232,6 +396,64 @@ func (p *Proxy) Run(ctx context.Context) error {
 	return nil
 }
 
+func (p *Proxy) writeSecretFile(secret string) {
+	if p.cfg.SecretFile == "" {
+		return
+	}
+
+	envVar := p.cfg.SecretEnvVar
+	if envVar == "" {
+		envVar = webhooks.DefaultSecretEnvVar
+	}
+
+	if err := webhooks.WriteSecretToDotEnv(afero.NewOsFs(), p.cfg.SecretFile, envVar, secret); err != nil {
+		p.cfg.OutCh <- websocket.WarningElement{
+			Warning: fmt.Sprintf("Failed to write the webhook signing secret to %s: %v", p.cfg.SecretFile, err),
+		}
+	}
+}
+
+// AllowRedelivery makes the proxy forward the next delivery of an event that
+// was already forwarded, like when asking Stripe to resend it, instead of
+// suppressing it as a duplicate.
+func (p *Proxy) AllowRedelivery(eventID string) {
+	p.webhookEventProcessor.AllowRedelivery(eventID)
+}
+
+// onReconnect is called when the websocket connection is dropped to
+// reconnect.
+func (p *Proxy) onReconnect(reason string) {
+	p.metrics.reconnected(reason)
+
+	// connections reset on purpose don't miss events
+	if reason == "disconnected" {
+		p.catchUpPending.Store(true)
+	}
+}
+
+// onConnect is called when the websocket connection is established, and
+// catches up on the events that may have been missed since the last one.
+func (p *Proxy) onConnect(ctx context.Context) {
+	if p.catchUpPending.Swap(false) {
+		go p.catchUp(ctx)
+	}
+}
+
+// catchUp delivers the events missed while disconnected, unless a catch up
+// is already running.
+func (p *Proxy) catchUp(ctx context.Context) {
+	if !p.cfg.CatchUp || !p.catchUpMu.TryLock() {
+		return
+	}
+	defer p.catchUpMu.Unlock()
+
+	if err := p.webhookEventProcessor.CatchUp(ctx, p.cfg.Client); err != nil && ctx.Err() == nil {
+		p.cfg.OutCh <- websocket.WarningElement{
+			Warning: fmt.Sprintf("Failed to catch up on events missed while disconnected: %v", err),
+		}
+	}
+}
+
 // GetSessionSecret creates a session and returns the webhook signing secret.
 func GetSessionSecret(ctx context.Context, client stripe.RequestPerformer, deviceName string) (string, error) {
 	p, err := Init(ctx, &Config{
@@ -331,6 +553,12 @@ func formatOutput(format string, eventPayload string) string {
 // Public functions
 //
 
+// Reforward forwards a previously received event to the local endpoints again,
+// synchronously. Nothing is acknowledged to Stripe.
+func (p *Proxy) Reforward(entry JournalEntry) {
+	p.webhookEventProcessor.Replay(entry)
+}
+
 // Init initializes a new Proxy
 func Init(ctx context.Context, cfg *Config) (*Proxy, error) {
 	if cfg.Log == nil {
@@ -354,7 +582,7 @@ func Init(ctx context.Context, cfg *Config) (*Proxy, error) {
 		cfg.Events = []string{"*"}
 	} else {
 		for _, event := range cfg.Events {
-			if _, found := validEvents[event]; !found {
+			if !isKnownEventPattern(event, validEvents) {
 				cfg.Log.Warningf("You're attempting to listen for \"%s\", which isn't a valid event\n", event)
 			}
 		}
@@ -362,14 +590,12 @@ func Init(ctx context.Context, cfg *Config) (*Proxy, error) {
 
 	if len(cfg.ThinEvents) > 0 {
 		for _, event := range cfg.ThinEvents {
-			if _, found := validThinEvents[event]; !found {
-				// If not found in validThinEvents, check in validPreviewThinEvents
-				if _, foundInPreview := validPreviewThinEvents[event]; !foundInPreview {
-					if event == "*" {
-						cfg.Log.Infof("* is only supported in the CLI, thin event destinations do not support selecting all event types\n")
-					} else {
-						cfg.Log.Warningf("You're attempting to listen for \"%s\", which isn't a valid thin event or preview event\n", event)
-					}
+			// check in both validThinEvents and validPreviewThinEvents
+			if !isKnownEventPattern(event, validThinEvents, validPreviewThinEvents) {
+				if event == "*" {
+					cfg.Log.Infof("* is only supported in the CLI, thin event destinations do not support selecting all event types\n")
+				} else {
+					cfg.Log.Warningf("You're attempting to listen for \"%s\", which isn't a valid thin event or preview event\n", event)
 				}
 			}
 		}
@@ -401,49 +627,91 @@ func Init(ctx context.Context, cfg *Config) (*Proxy, error) {
 			return nil, err
 		}
 	} else {
-		if len(cfg.ForwardURL) > 0 {
-			// non-connect endpoints
-			endpointRoutes = append(endpointRoutes, EndpointRoute{
-				URL:            parseURL(cfg.ForwardURL),
-				ForwardHeaders: cfg.ForwardHeaders,
-				Connect:        false,
-				EventTypes:     cfg.Events,
-			})
+		endpointRoutes = buildForwardRoutes(cfg)
+	}
+
+	if cfg.RespondWith != 0 || cfg.RespondDelay != 0 || cfg.ResponseScript != "" {
+		if len(endpointRoutes) > 0 || cfg.RoutesFile != "" {
+			return nil, errorcategory.New(errorcategory.UserInput, "responding to events with --respond-with, --respond-delay or --respond-script cannot be combined with forwarding them")
 		}
 
-		if len(cfg.ForwardConnectURL) > 0 {
-			// connect endpoints
-			endpointRoutes = append(endpointRoutes, EndpointRoute{
-				URL:            parseURL(cfg.ForwardConnectURL),
-				ForwardHeaders: cfg.ForwardConnectHeaders,
-				Connect:        true,
-				EventTypes:     cfg.Events,
-			})
+		responder, err := NewMockResponder(cfg.RespondWith, cfg.RespondDelay, cfg.ResponseScript)
+		if err != nil {
+			return nil, err
 		}
 
-		if len(cfg.ForwardThinURL) > 0 {
-			// Thin endpoints
-			endpointRoutes = append(endpointRoutes, EndpointRoute{
-				URL:                parseURL(cfg.ForwardThinURL),
-				ForwardHeaders:     cfg.ForwardHeaders,
-				Connect:            false,
-				EventTypes:         cfg.ThinEvents,
-				IsEventDestination: true,
-			})
+		endpointRoutes = buildMockRoutes(cfg, responder)
+	}
+
+	if cfg.RoutesFile != "" {
+		fileRoutes, err := buildEndpointRoutesFromFile(cfg.RoutesFile)
+		if err != nil {
+			return nil, err
 		}
 
-		if len(cfg.ForwardThinConnectURL) > 0 {
-			// Thin connect endpoints
-			endpointRoutes = append(endpointRoutes, EndpointRoute{
-				URL:                parseURL(cfg.ForwardThinConnectURL),
-				ForwardHeaders:     cfg.ForwardConnectHeaders,
-				Connect:            true,
-				EventTypes:         cfg.ThinEvents,
-				IsEventDestination: true,
-			})
+		for _, route := range fileRoutes {
+			if route.IsEventDestination && len(cfg.ThinEvents) == 0 {
+				cfg.Log.Warningf("Route to %s receives thin events, but no thin events were selected with --thin-events\n", route.URL)
+			}
+
+			for _, event := range route.EventTypes {
+				if !route.IsEventDestination && !isKnownEventPattern(event, validEvents) {
+					cfg.Log.Warningf("Route to %s is attempting to receive \"%s\", which isn't a valid event\n", route.URL, event)
+				}
+			}
+		}
+
+		endpointRoutes = append(endpointRoutes, fileRoutes...)
+	}
+
+	filters, err := ParsePayloadFilters(cfg.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	transforms, err := ParsePayloadTransforms(cfg.Transforms)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.TransformCommand != "" {
+		transforms = append(transforms, NewCommandTransform(cfg.TransformCommand))
+	}
+
+	var journaled []JournalEntry
+	if cfg.CatchUp && cfg.JournalPath != "" {
+		journaled, err = readCatchUpJournal(cfg.JournalPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.FanOutSocket != "" && cfg.AttachSocket != "" {
+		return nil, errorcategory.New(errorcategory.UserInput, "a proxy cannot both serve and attach to a fan-out socket")
+	}
+
+	var fanOut *FanOut
+	if cfg.FanOutSocket != "" {
+		fanOut, err = ListenFanOut(cfg.FanOutSocket, cfg.Events, cfg.ThinEvents, cfg.Log)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var journal *Journal
+	if cfg.JournalPath != "" {
+		journal, err = OpenJournal(cfg.JournalPath)
+		if err != nil {
+			fanOut.Close()
+			return nil, err
 		}
 	}
 
+	var proxyMetrics *Metrics
+	if cfg.Metrics != nil {
+		proxyMetrics = NewMetrics(cfg.Metrics)
+	}
+
 	processorConfig := &WebhookEventProcessorConfig{
 		Log:                 cfg.Log,
 		Events:              cfg.Events,
@@ -451,17 +719,36 @@ func Init(ctx context.Context, cfg *Config) (*Proxy, error) {
 		OutCh:               cfg.OutCh,
 		UseLatestAPIVersion: cfg.UseLatestAPIVersion,
 		SkipVerify:          cfg.SkipVerify,
-		Timeout:             cfg.Timeout,
+		Timeout:             cfg.forwardTimeout(),
 		LoggedInAccountID:   cfg.LoggedInAccountID,
+		Journal:             journal,
+		RetryPolicy:         cfg.RetryPolicy,
+		DeliveryConcurrency: cfg.DeliveryConcurrency,
+		DeliveryQueueSize:   cfg.DeliveryQueueSize,
+		Filters:             filters,
+		ResignEvents:        cfg.ResignEvents || cfg.SigningSecret != "",
+		SigningSecret:       cfg.SigningSecret,
+		Metrics:             proxyMetrics,
+		CatchUp:             cfg.CatchUp,
+		Dedupe:              cfg.Dedupe,
+		Transforms:          transforms,
 	}
 
 	p := &Proxy{
-		cfg: cfg,
+		cfg:     cfg,
+		journal: journal,
+		metrics: proxyMetrics,
+		fanOut:  fanOut,
 		stripeAuthClient: stripeauth.NewClient(cfg.Client, &stripeauth.Config{
 			Log: cfg.Log,
 		}),
 	}
 	p.webhookEventProcessor = NewWebhookEventProcessor(p.sendMessage, endpointRoutes, processorConfig)
+	p.webhookEventProcessor.seedCatchUp(journaled)
+
+	// the events missed since the journal's last event are caught up on the
+	// first connection
+	p.catchUpPending.Store(true)
 
 	return p, nil
 }
@@ -504,9 +791,14 @@ type eventContext struct {
 	webhookID             string
 	webhookConversationID string
 	requestBody           string
+	forwardedBody         string
 	requestHeaders        map[string]string
 	event                 *StripeEvent
 	v2Event               *V2EventPayload
+	attempt               int
+	lastAttempt           bool
+	sentAt                time.Time
+	trace                 *forwardTrace
 }
 
 //
@@ -564,6 +856,11 @@ func isUTF8ContinuationByte(b byte) bool {
 // parseURL parses the potentially incomplete URL provided in the configuration
 // and returns a full URL
 func parseURL(url string) string {
+	if isLocalTransportURL(url) {
+		// Unix sockets, gRPC servers and commands are used as-is
+		return url
+	}
+
 	_, err := strconv.Atoi(url)
 	if err == nil {
 		// If the input is just a number, assume it's a port number
@@ -631,6 +928,85 @@ func buildEndpointRoutes(endpoints requests.WebhookEndpointList, forwardURL, for
 	return endpointRoutes, nil
 }
 
+// buildForwardRoutes builds the endpoint routes described by the --forward-*
+// flags of the configuration.
+// forwardTimeout returns the time to wait for local endpoints to respond,
+// or 0 to use the default.
+func (cfg *Config) forwardTimeout() time.Duration {
+	if cfg.ForwardTimeout > 0 {
+		return cfg.ForwardTimeout
+	}
+
+	return time.Duration(cfg.Timeout) * time.Second
+}
+
+func buildForwardRoutes(cfg *Config) []EndpointRoute {
+	var endpointRoutes []EndpointRoute
+
+	if len(cfg.ForwardURL) > 0 {
+		// non-connect endpoints
+		endpointRoutes = append(endpointRoutes, EndpointRoute{
+			URL:            parseURL(cfg.ForwardURL),
+			ForwardHeaders: cfg.ForwardHeaders,
+			Connect:        false,
+			EventTypes:     cfg.Events,
+		})
+	}
+
+	if len(cfg.ForwardConnectURL) > 0 {
+		// connect endpoints
+		endpointRoutes = append(endpointRoutes, EndpointRoute{
+			URL:            parseURL(cfg.ForwardConnectURL),
+			ForwardHeaders: cfg.ForwardConnectHeaders,
+			Connect:        true,
+			EventTypes:     cfg.Events,
+		})
+	}
+
+	if len(cfg.ForwardThinURL) > 0 {
+		// Thin endpoints
+		endpointRoutes = append(endpointRoutes, EndpointRoute{
+			URL:                parseURL(cfg.ForwardThinURL),
+			ForwardHeaders:     cfg.ForwardHeaders,
+			Connect:            false,
+			EventTypes:         cfg.ThinEvents,
+			IsEventDestination: true,
+		})
+	}
+
+	if len(cfg.ForwardThinConnectURL) > 0 {
+		// Thin connect endpoints
+		endpointRoutes = append(endpointRoutes, EndpointRoute{
+			URL:                parseURL(cfg.ForwardThinConnectURL),
+			ForwardHeaders:     cfg.ForwardConnectHeaders,
+			Connect:            true,
+			EventTypes:         cfg.ThinEvents,
+			IsEventDestination: true,
+		})
+	}
+
+	return endpointRoutes
+}
+
+// buildMockRoutes returns the routes answering account and Connect events
+// with responder.
+func buildMockRoutes(cfg *Config, responder *MockResponder) []EndpointRoute {
+	return []EndpointRoute{
+		{
+			URL:        mockEndpointURL,
+			Connect:    false,
+			EventTypes: cfg.Events,
+			Responder:  responder,
+		},
+		{
+			URL:        mockEndpointURL,
+			Connect:    true,
+			EventTypes: cfg.Events,
+			Responder:  responder,
+		},
+	}
+}
+
 func buildForwardURL(forwardURL string, destination *url.URL) (string, error) {
 	f, err := url.Parse(forwardURL)
 	if err != nil {
@@ -652,6 +1028,33 @@ func buildForwardURL(forwardURL string, destination *url.URL) (string, error) {
 	return newForwardURL, nil
 }
 
+// isKnownEventPattern reports whether an --events entry names a known event
+// type or, for globs and negations, matches at least one.
+func isKnownEventPattern(pattern string, knownEvents ...map[string]bool) bool {
+	name := matcher.Name(pattern)
+	if name == "*" {
+		return knownEvents[0]["*"]
+	}
+
+	m := matcher.New([]string{name})
+
+	for _, events := range knownEvents {
+		if events[name] {
+			return true
+		}
+
+		if matcher.IsPattern(name) {
+			for event := range events {
+				if m.Match(event) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
 func getAPIVersionString(str *string) string {
 	var APIVersion string
 
@@ -5,7 +5,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -38,6 +41,18 @@ func TestFilterWebhookEvent(t *testing.T) {
 	require.False(t, proxyUseLatest.webhookEventProcessor.filterWebhookEvent(evtLatest))
 }
 
+func TestIsKnownEventPattern(t *testing.T) {
+	require.True(t, isKnownEventPattern("*", validEvents))
+	require.True(t, isKnownEventPattern("charge.succeeded", validEvents))
+	require.True(t, isKnownEventPattern("invoice.*", validEvents))
+	require.True(t, isKnownEventPattern("!invoice.upcoming", validEvents))
+	require.False(t, isKnownEventPattern("nope.*", validEvents))
+	require.False(t, isKnownEventPattern("charge.nope", validEvents))
+
+	require.False(t, isKnownEventPattern("*", validThinEvents, validPreviewThinEvents))
+	require.True(t, isKnownEventPattern("v1.billing.*", validThinEvents, validPreviewThinEvents))
+}
+
 func TestTruncate(t *testing.T) {
 	require.Equal(t, "Hello, World", truncate("Hello, World", 12, false))
 	require.Equal(t, "Hello, Worl", truncate("Hello, World", 11, false))
@@ -288,3 +303,39 @@ func TestRun_NoRetryOnAuthorizationClientError_TooManyRequests(t *testing.T) {
 	require.ErrorContains(t, err, "you have too many `stripe listen` sessions open, please close some and try again")
 	require.Equal(t, 1, nAttempts)
 }
+
+func TestWriteSecretFile(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), ".env.local")
+
+	p, err := Init(context.Background(), &Config{SecretFile: secretFile})
+	require.NoError(t, err)
+
+	p.writeSecretFile("whsec_123")
+
+	content, err := os.ReadFile(secretFile)
+	require.NoError(t, err)
+	require.Equal(t, "STRIPE_WEBHOOK_SECRET=\"whsec_123\"\n", string(content))
+}
+
+func TestWriteSecretFile_WarnsOnFailure(t *testing.T) {
+	outCh := make(chan websocket.IElement, 1)
+
+	p, err := Init(context.Background(), &Config{
+		SecretFile:   filepath.Join(t.TempDir(), "missing", ".env"),
+		SecretEnvVar: "WEBHOOK_SECRET",
+		OutCh:        outCh,
+	})
+	require.NoError(t, err)
+
+	p.writeSecretFile("whsec_123")
+
+	el := <-outCh
+	require.IsType(t, websocket.WarningElement{}, el)
+	require.Contains(t, el.(websocket.WarningElement).Warning, "Failed to write the webhook signing secret")
+}
+
+func TestConfigForwardTimeout(t *testing.T) {
+	require.Equal(t, time.Duration(0), (&Config{}).forwardTimeout())
+	require.Equal(t, 10*time.Second, (&Config{Timeout: 10}).forwardTimeout())
+	require.Equal(t, 500*time.Millisecond, (&Config{Timeout: 10, ForwardTimeout: 500 * time.Millisecond}).forwardTimeout())
+}
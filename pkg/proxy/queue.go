@@ -0,0 +1,46 @@
+package proxy
+
+// deliveryQueue forwards events to a single endpoint with a bounded number of
+// workers. With a single worker, events are delivered strictly in the order
+// they were pushed. Pushing to a full queue blocks until a worker frees a
+// slot, which applies backpressure to the caller. Closing the queue stops its
+// workers once the jobs already pushed are done.
+type deliveryQueue struct {
+	jobs chan func()
+}
+
+func newDeliveryQueue(concurrency int, size int) *deliveryQueue {
+	if size < 0 {
+		size = 0
+	}
+
+	q := &deliveryQueue{
+		jobs: make(chan func(), size),
+	}
+
+	for i := 0; i < concurrency; i++ {
+		go q.work()
+	}
+
+	return q
+}
+
+func (q *deliveryQueue) work() {
+	for job := range q.jobs {
+		job()
+	}
+}
+
+// push enqueues a job, waiting for room in the queue if it is full.
+func (q *deliveryQueue) push(job func()) {
+	q.jobs <- job
+}
+
+// full reports whether the next push will have to wait.
+func (q *deliveryQueue) full() bool {
+	return len(q.jobs) >= cap(q.jobs)
+}
+
+func (q *deliveryQueue) close() {
+	close(q.jobs)
+}
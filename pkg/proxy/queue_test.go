@@ -0,0 +1,163 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliveryQueue_Ordered(t *testing.T) {
+	q := newDeliveryQueue(1, 10)
+	defer q.close()
+
+	var mu sync.Mutex
+	var order []int
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		q.push(func() {
+			defer wg.Done()
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, i)
+		})
+	}
+
+	wg.Wait()
+
+	for i := range order {
+		require.Equal(t, i, order[i])
+	}
+}
+
+func TestDeliveryQueue_BoundedConcurrency(t *testing.T) {
+	q := newDeliveryQueue(2, 10)
+	defer q.close()
+
+	var inFlight, maxInFlight int32
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		q.push(func() {
+			defer wg.Done()
+			n := atomic.AddInt32(&inFlight, 1)
+			for {
+				m := atomic.LoadInt32(&maxInFlight)
+				if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&inFlight, -1)
+		})
+	}
+
+	wg.Wait()
+	require.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(2))
+}
+
+func TestDeliveryQueue_Full(t *testing.T) {
+	block := make(chan struct{})
+	q := newDeliveryQueue(1, 1)
+	defer q.close()
+
+	// the first job occupies the worker, the second one fills the queue
+	started := make(chan struct{})
+	q.push(func() {
+		close(started)
+		<-block
+	})
+	<-started
+	q.push(func() {})
+
+	require.True(t, q.full())
+	close(block)
+}
+
+func TestClientHandler_EnqueueOrdered(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	wg := &sync.WaitGroup{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		mu.Lock()
+		received = append(received, string(body))
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	client := NewEndpointClient(
+		ts.URL,
+		[]string{},
+		false,
+		[]string{"*"},
+		false,
+		&EndpointConfig{
+			Concurrency: 1,
+			QueueSize:   5,
+			ResponseHandler: EndpointResponseHandlerFunc(func(evtCtx eventContext, forwardURL string, resp *http.Response) {
+				wg.Done()
+			}),
+		},
+	)
+
+	var expected []string
+	for i := 0; i < 20; i++ {
+		body := fmt.Sprintf(`{"id":"evt_%d"}`, i)
+		expected = append(expected, body)
+
+		wg.Add(1)
+		client.Enqueue(eventContext{event: &StripeEvent{ID: fmt.Sprintf("evt_%d", i)}, requestBody: body})
+	}
+
+	wg.Wait()
+	require.Equal(t, expected, received)
+}
+
+func TestClientHandler_CloseDrainsQueue(t *testing.T) {
+	var n int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&n, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	client := NewEndpointClient(
+		ts.URL,
+		[]string{},
+		false,
+		[]string{"*"},
+		false,
+		&EndpointConfig{
+			Concurrency: 1,
+			QueueSize:   5,
+		},
+	)
+
+	for i := 0; i < 5; i++ {
+		client.Enqueue(eventContext{event: &StripeEvent{ID: fmt.Sprintf("evt_%d", i)}, requestBody: "{}"})
+	}
+
+	client.Close()
+	require.EqualValues(t, 5, atomic.LoadInt32(&n))
+
+	// events enqueued once the client is closed are dropped
+	client.Enqueue(eventContext{event: &StripeEvent{ID: "evt_5"}, requestBody: "{}"})
+	client.Close()
+	require.EqualValues(t, 5, atomic.LoadInt32(&n))
+}
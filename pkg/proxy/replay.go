@@ -0,0 +1,158 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stripe/stripe-cli/pkg/errorcategory"
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+// ReplayConfig provides the configuration of an offline journal replay
+type ReplayConfig struct {
+	// JournalPath is the journal file events are read from
+	JournalPath string
+
+	// EventIDs restricts the replay to the given event IDs. All events are replayed when empty.
+	EventIDs []string
+
+	// URL to which events are forwarded to
+	ForwardURL string
+	// URL to which Thin events are forwarded to
+	ForwardThinURL string
+	// Headers to inject when forwarding events
+	ForwardHeaders []string
+	// URL to which Connect events are forwarded to
+	ForwardConnectURL string
+	// URL to which Connect Thin events are forwarded to
+	ForwardThinConnectURL string
+	// Headers to inject when forwarding Connect events
+	ForwardConnectHeaders []string
+
+	// List of events to replay
+	Events []string
+	// List of Thin-type events to replay
+	ThinEvents []string
+	// Payload predicates that events must all satisfy to be replayed
+	Filters []string
+	// SigningSecret, when set, is used to re-compute the Stripe-Signature
+	// header of replayed events, whose original signature has likely expired
+	SigningSecret string
+
+	// Indicates whether to skip certificate verification when forwarding webhooks to HTTPS endpoints
+	SkipVerify bool
+	// The logger used to log messages to stdin/err
+	Log *log.Logger
+	// ForwardTimeout is the time to wait for local endpoints to respond
+	// (default: 30s)
+	ForwardTimeout time.Duration
+
+	// OutCh is the channel to send logs and statuses to for processing in other packages
+	OutCh chan websocket.IElement
+
+	// LoggedInAccountID is the currently logged-in account ID
+	LoggedInAccountID string
+}
+
+// Replay re-forwards the events recorded in a journal to the local endpoints,
+// without opening a websocket session with Stripe. Events are forwarded one at
+// a time, in the order they were originally received.
+func Replay(ctx context.Context, cfg *ReplayConfig) error {
+	defer close(cfg.OutCh)
+
+	if cfg.Log == nil {
+		cfg.Log = &log.Logger{Out: io.Discard}
+	}
+
+	entries, err := ReadJournal(cfg.JournalPath)
+	if err != nil {
+		cfg.OutCh <- websocket.ErrorElement{Error: err}
+		return err
+	}
+
+	if len(cfg.ForwardURL) == 0 && len(cfg.ForwardConnectURL) == 0 && len(cfg.ForwardThinURL) == 0 && len(cfg.ForwardThinConnectURL) == 0 {
+		err := errorcategory.New(errorcategory.UserInput, "replay requires a location to forward to with forward_to")
+		cfg.OutCh <- websocket.ErrorElement{Error: err}
+		return err
+	}
+
+	filters, err := ParsePayloadFilters(cfg.Filters)
+	if err != nil {
+		cfg.OutCh <- websocket.ErrorElement{Error: err}
+		return err
+	}
+
+	if len(cfg.Events) == 0 {
+		cfg.Events = []string{"*"}
+	}
+
+	if len(cfg.ThinEvents) == 0 {
+		cfg.ThinEvents = []string{"*"}
+	}
+
+	routeCfg := &Config{
+		ForwardURL:            cfg.ForwardURL,
+		ForwardThinURL:        cfg.ForwardThinURL,
+		ForwardHeaders:        cfg.ForwardHeaders,
+		ForwardConnectURL:     cfg.ForwardConnectURL,
+		ForwardThinConnectURL: cfg.ForwardThinConnectURL,
+		ForwardConnectHeaders: cfg.ForwardConnectHeaders,
+		Events:                cfg.Events,
+		ThinEvents:            cfg.ThinEvents,
+	}
+
+	if len(routeCfg.ForwardConnectURL) == 0 {
+		routeCfg.ForwardConnectURL = routeCfg.ForwardURL
+	}
+	if len(routeCfg.ForwardConnectHeaders) == 0 {
+		routeCfg.ForwardConnectHeaders = routeCfg.ForwardHeaders
+	}
+	if len(routeCfg.ForwardThinConnectURL) == 0 {
+		routeCfg.ForwardThinConnectURL = routeCfg.ForwardThinURL
+	}
+
+	// Responses are not sent anywhere since there is no session with Stripe
+	processor := NewWebhookEventProcessor(func(*websocket.OutgoingMessage) {}, buildForwardRoutes(routeCfg), &WebhookEventProcessorConfig{
+		Log:               cfg.Log,
+		Events:            cfg.Events,
+		ThinEvents:        cfg.ThinEvents,
+		OutCh:             cfg.OutCh,
+		SkipVerify:        cfg.SkipVerify,
+		Timeout:           cfg.ForwardTimeout,
+		LoggedInAccountID: cfg.LoggedInAccountID,
+		Filters:           filters,
+		ResignEvents:      cfg.SigningSecret != "",
+		SigningSecret:     cfg.SigningSecret,
+	})
+
+	eventIDs := convertToMap(cfg.EventIDs)
+
+	for _, entry := range entries {
+		if entry.Kind != JournalEntryEvent {
+			continue
+		}
+
+		if len(eventIDs) > 0 && !eventIDs[entry.EventID] {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		cfg.Log.WithFields(log.Fields{
+			"prefix":     "proxy.Replay",
+			"event_id":   entry.EventID,
+			"event_type": entry.EventType,
+		}).Debug("Replaying journaled event")
+
+		processor.Replay(entry)
+	}
+
+	return nil
+}
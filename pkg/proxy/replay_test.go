@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+func TestReplay(t *testing.T) {
+	var received []string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, "t=123,v1=hunter2", r.Header.Get("Stripe-Signature"))
+
+		received = append(received, string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	journal, err := OpenJournal(path)
+	require.NoError(t, err)
+
+	for _, entry := range []JournalEntry{
+		{Kind: JournalEntryEvent, EventID: "evt_1", Payload: `{"id":"evt_1","type":"charge.succeeded"}`, HTTPHeaders: map[string]string{"Stripe-Signature": "t=123,v1=hunter2"}},
+		{Kind: JournalEntryResponse, EventID: "evt_1", Status: 500},
+		{Kind: JournalEntryEvent, EventID: "evt_2", Payload: `{"id":"evt_2","type":"charge.failed"}`, HTTPHeaders: map[string]string{"Stripe-Signature": "t=123,v1=hunter2"}},
+		{Kind: JournalEntryEvent, EventID: "evt_3", Payload: `{"id":"evt_3","type":"customer.created"}`, HTTPHeaders: map[string]string{"Stripe-Signature": "t=123,v1=hunter2"}},
+	} {
+		require.NoError(t, journal.Write(entry))
+	}
+	require.NoError(t, journal.Close())
+
+	outCh := make(chan websocket.IElement, 10)
+	err = Replay(context.Background(), &ReplayConfig{
+		JournalPath: path,
+		ForwardURL:  ts.URL,
+		Events:      []string{"charge.succeeded", "charge.failed"},
+		OutCh:       outCh,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		`{"id":"evt_1","type":"charge.succeeded"}`,
+		`{"id":"evt_2","type":"charge.failed"}`,
+	}, received)
+
+	var responses int
+	for el := range outCh {
+		if de, ok := el.(websocket.DataElement); ok {
+			if _, ok := de.Data.(EndpointResponse); ok {
+				responses++
+			}
+		}
+	}
+	require.Equal(t, 2, responses)
+}
+
+func TestReplay_EventIDs(t *testing.T) {
+	var received []string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		received = append(received, string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	journal, err := OpenJournal(path)
+	require.NoError(t, err)
+	require.NoError(t, journal.Write(JournalEntry{Kind: JournalEntryEvent, EventID: "evt_1", Payload: `{"id":"evt_1","type":"charge.succeeded"}`}))
+	require.NoError(t, journal.Write(JournalEntry{Kind: JournalEntryEvent, EventID: "evt_2", Payload: `{"id":"evt_2","type":"charge.succeeded"}`}))
+	require.NoError(t, journal.Close())
+
+	err = Replay(context.Background(), &ReplayConfig{
+		JournalPath: path,
+		EventIDs:    []string{"evt_2"},
+		ForwardURL:  ts.URL,
+		OutCh:       make(chan websocket.IElement, 10),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{`{"id":"evt_2","type":"charge.succeeded"}`}, received)
+}
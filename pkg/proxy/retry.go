@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"time"
+)
+
+// RetryPolicy describes how forwards that fail are retried against a local
+// endpoint. The zero value disables retries.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of delivery attempts, including the first one
+	MaxAttempts int
+
+	// Backoff is the delay before the first retry. It doubles after every attempt.
+	Backoff time.Duration
+
+	// MaxBackoff caps the delay between two attempts
+	MaxBackoff time.Duration
+
+	// RetryableStatusCodes lists the response statuses that trigger a retry.
+	// When empty, any non-2xx status is retried, like Stripe does.
+	RetryableStatusCodes []int
+}
+
+// EndpointRetry describes a failed forward attempt that is about to be retried
+type EndpointRetry struct {
+	Event   *StripeEvent
+	V2Event *V2EventPayload
+
+	// URL is the local endpoint the event is forwarded to
+	URL string
+
+	// Status is the response status of the failed attempt, or 0 if no response was received
+	Status int
+
+	// Attempt is the number of the attempt that failed, starting at 1
+	Attempt     int
+	MaxAttempts int
+
+	// Delay is how long the proxy waits before the next attempt
+	Delay time.Duration
+}
+
+func (r RetryPolicy) maxAttempts() int {
+	if r.MaxAttempts < 1 {
+		return 1
+	}
+
+	return r.MaxAttempts
+}
+
+// shouldRetry reports whether an attempt that ended with the given status and
+// error should be retried. Network errors are always retried.
+func (r RetryPolicy) shouldRetry(status int, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	if len(r.RetryableStatusCodes) == 0 {
+		return status < 200 || status >= 300
+	}
+
+	for _, code := range r.RetryableStatusCodes {
+		if code == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// delay returns the time to wait after the given failed attempt
+func (r RetryPolicy) delay(attempt int) time.Duration {
+	d := r.Backoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+
+		if r.MaxBackoff > 0 && d >= r.MaxBackoff {
+			break
+		}
+	}
+
+	if r.MaxBackoff > 0 && d > r.MaxBackoff {
+		return r.MaxBackoff
+	}
+
+	return d
+}
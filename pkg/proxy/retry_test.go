@@ -0,0 +1,222 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+func TestRetryPolicy_ShouldRetry(t *testing.T) {
+	policy := RetryPolicy{}
+	require.True(t, policy.shouldRetry(0, errors.New("connection refused")))
+	require.True(t, policy.shouldRetry(500, nil))
+	require.True(t, policy.shouldRetry(404, nil))
+	require.False(t, policy.shouldRetry(200, nil))
+	require.False(t, policy.shouldRetry(204, nil))
+
+	policy = RetryPolicy{RetryableStatusCodes: []int{503}}
+	require.True(t, policy.shouldRetry(503, nil))
+	require.False(t, policy.shouldRetry(500, nil))
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	require.Equal(t, time.Second, policy.delay(1))
+	require.Equal(t, 2*time.Second, policy.delay(2))
+	require.Equal(t, 4*time.Second, policy.delay(3))
+	require.Equal(t, 5*time.Second, policy.delay(4))
+	require.Equal(t, 5*time.Second, policy.delay(50))
+}
+
+func TestRetryPolicy_MaxAttempts(t *testing.T) {
+	require.Equal(t, 1, RetryPolicy{}.maxAttempts())
+	require.Equal(t, 3, RetryPolicy{MaxAttempts: 3}.maxAttempts())
+}
+
+func TestClientHandler_Retries(t *testing.T) {
+	var n int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&n, 1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	var statuses []int
+	var attempts []int
+	outCh := make(chan websocket.IElement, 10)
+	client := NewEndpointClient(
+		ts.URL,
+		[]string{},
+		false,
+		[]string{"*"},
+		false,
+		&EndpointConfig{
+			OutCh: outCh,
+			RetryPolicy: RetryPolicy{
+				MaxAttempts: 5,
+				Backoff:     time.Millisecond,
+			},
+			ResponseHandler: EndpointResponseHandlerFunc(func(evtCtx eventContext, forwardURL string, resp *http.Response) {
+				statuses = append(statuses, resp.StatusCode)
+				attempts = append(attempts, evtCtx.attempt)
+			}),
+		},
+	)
+
+	err := client.Post(eventContext{event: &StripeEvent{ID: "evt_123"}, requestBody: "{}"})
+	require.NoError(t, err)
+
+	require.Equal(t, []int{500, 500, 200}, statuses)
+	require.Equal(t, []int{1, 2, 3}, attempts)
+
+	close(outCh)
+	var retries []EndpointRetry
+	var attemptsReported []int
+	for el := range outCh {
+		switch data := el.(websocket.DataElement).Data.(type) {
+		case EndpointRetry:
+			retries = append(retries, data)
+		case EndpointAttempt:
+			attemptsReported = append(attemptsReported, data.Attempt)
+		}
+	}
+	require.Equal(t, []int{1, 2, 3}, attemptsReported)
+	require.Len(t, retries, 2)
+	require.Equal(t, 1, retries[0].Attempt)
+	require.Equal(t, 500, retries[0].Status)
+	require.Equal(t, 5, retries[0].MaxAttempts)
+	require.Equal(t, "evt_123", retries[0].Event.ID)
+	require.Equal(t, 2*time.Millisecond, retries[1].Delay)
+}
+
+func TestClientHandler_RetriesExhausted(t *testing.T) {
+	var n int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&n, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	client := NewEndpointClient(
+		ts.URL,
+		[]string{},
+		false,
+		[]string{"*"},
+		false,
+		&EndpointConfig{
+			OutCh: make(chan websocket.IElement, 10),
+			RetryPolicy: RetryPolicy{
+				MaxAttempts:          3,
+				Backoff:              time.Millisecond,
+				RetryableStatusCodes: []int{http.StatusServiceUnavailable},
+			},
+		},
+	)
+
+	err := client.Post(eventContext{event: &StripeEvent{ID: "evt_123"}, requestBody: "{}"})
+	require.NoError(t, err)
+	require.EqualValues(t, 3, atomic.LoadInt32(&n))
+}
+
+func TestClientHandler_RetriesStopOnClose(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	client := NewEndpointClient(
+		ts.URL,
+		[]string{},
+		false,
+		[]string{"*"},
+		false,
+		&EndpointConfig{
+			OutCh: make(chan websocket.IElement, 10),
+			RetryPolicy: RetryPolicy{
+				MaxAttempts: 3,
+				Backoff:     time.Hour,
+			},
+		},
+	)
+
+	done := make(chan error)
+	go func() {
+		done <- client.Post(eventContext{event: &StripeEvent{ID: "evt_123"}, requestBody: "{}"})
+	}()
+
+	// wait for the first attempt to be retried
+	for el := range client.cfg.OutCh {
+		if _, ok := el.(websocket.DataElement).Data.(EndpointRetry); ok {
+			break
+		}
+	}
+
+	client.Close()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "The retry wasn't interrupted")
+	}
+}
+
+func TestWebhookEventProcessor_RespondsWithLastAttempt(t *testing.T) {
+	var n int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&n, 1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	responses := make(chan *websocket.WebhookResponse, 3)
+	sendMessage := func(msg *websocket.OutgoingMessage) {
+		if msg.WebhookResponse != nil {
+			responses <- msg.WebhookResponse
+		}
+	}
+
+	processor := NewWebhookEventProcessor(sendMessage, []EndpointRoute{{URL: ts.URL, EventTypes: []string{"*"}}}, &WebhookEventProcessorConfig{
+		Log:    &log.Logger{Out: io.Discard},
+		Events: []string{"*"},
+		OutCh:  make(chan websocket.IElement, 20),
+		RetryPolicy: RetryPolicy{
+			MaxAttempts: 3,
+			Backoff:     time.Millisecond,
+		},
+	})
+
+	processor.ProcessEvent(websocket.IncomingMessage{
+		WebhookEvent: &websocket.WebhookEvent{
+			WebhookID:             "wh_123",
+			WebhookConversationID: "wc_123",
+			EventPayload:          `{"id":"evt_123","type":"charge.succeeded"}`,
+		},
+	})
+
+	select {
+	case resp := <-responses:
+		require.Equal(t, "wh_123", resp.WebhookID)
+		require.Equal(t, http.StatusOK, resp.Status)
+		require.EqualValues(t, 3, atomic.LoadInt32(&n))
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "No response was sent to Stripe")
+	}
+
+	require.Empty(t, responses)
+}
@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/stripe/stripe-cli/pkg/errorcategory"
+)
+
+// routesFile is the format of the file passed to `stripe listen --routes`.
+//
+//	routes:
+//	  - forward_to: localhost:3000/invoices
+//	    events: ["invoice.*"]
+//	    headers:
+//	      X-Service: billing
+//	  - forward_to: localhost:3001/subscriptions
+//	    events: ["customer.subscription.*"]
+//	    connect: true
+//	    timeout: 5s
+type routesFile struct {
+	Routes []routeConfig `json:"routes" yaml:"routes"`
+}
+
+type routeConfig struct {
+	// ForwardTo is the URL events matching the route are forwarded to
+	ForwardTo string `json:"forward_to" yaml:"forward_to"`
+
+	// Events is the list of event types, or globs like invoice.*, sent to the route
+	Events []string `json:"events" yaml:"events"`
+
+	// Headers to inject when forwarding events to the route
+	Headers map[string]string `json:"headers" yaml:"headers"`
+
+	// Connect indicates whether the route receives Connect events instead of account events
+	Connect bool `json:"connect" yaml:"connect"`
+
+	// Thin indicates whether the route receives thin events
+	Thin bool `json:"thin" yaml:"thin"`
+
+	// Timeout, like 5s, is the time to wait for the route's endpoint to
+	// respond, overriding --forward-timeout
+	Timeout string `json:"timeout" yaml:"timeout"`
+}
+
+// buildEndpointRoutesFromFile reads a YAML or JSON routes file and builds an
+// endpoint route for each of its entries.
+func buildEndpointRoutesFromFile(path string) ([]EndpointRoute, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errorcategory.Errorf(errorcategory.UserInput, "failed to read routes file: %v", err)
+	}
+
+	var file routesFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(content, &file)
+	} else {
+		err = yaml.Unmarshal(content, &file)
+	}
+	if err != nil {
+		return nil, errorcategory.Errorf(errorcategory.UserInput, "failed to parse routes file %s: %v", path, err)
+	}
+
+	if len(file.Routes) == 0 {
+		return nil, errorcategory.Errorf(errorcategory.UserInput, "routes file %s does not define any routes", path)
+	}
+
+	endpointRoutes := make([]EndpointRoute, 0, len(file.Routes))
+
+	for i, route := range file.Routes {
+		if route.ForwardTo == "" {
+			return nil, errorcategory.Errorf(errorcategory.UserInput, "route %d in %s is missing forward_to", i+1, path)
+		}
+
+		events := route.Events
+		if len(events) == 0 {
+			events = []string{"*"}
+		}
+
+		var timeout time.Duration
+		if route.Timeout != "" {
+			timeout, err = time.ParseDuration(route.Timeout)
+			if err != nil || timeout <= 0 {
+				return nil, errorcategory.Errorf(errorcategory.UserInput, "route %d in %s has an invalid timeout %q", i+1, path, route.Timeout)
+			}
+		}
+
+		endpointRoutes = append(endpointRoutes, EndpointRoute{
+			URL:                parseURL(route.ForwardTo),
+			ForwardHeaders:     headersToList(route.Headers),
+			Connect:            route.Connect,
+			EventTypes:         events,
+			IsEventDestination: route.Thin,
+			Timeout:            timeout,
+		})
+	}
+
+	return endpointRoutes, nil
+}
+
+// headersToList converts a header map to the "Key: Value" form used by the
+// --headers flag, sorted by key.
+func headersToList(headers map[string]string) []string {
+	list := make([]string, 0, len(headers))
+	for k, v := range headers {
+		list = append(list, fmt.Sprintf("%s: %s", k, v))
+	}
+
+	sort.Strings(list)
+
+	return list
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildEndpointRoutesFromFile_YAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+routes:
+  - forward_to: localhost:3000/invoices
+    events: ["invoice.*"]
+    headers:
+      X-Service: billing
+      Authorization: Bearer 123
+  - forward_to: 3001
+    events: ["customer.subscription.*"]
+    connect: true
+    timeout: 5s
+  - forward_to: localhost:3002/thin
+    thin: true
+`), 0600))
+
+	routes, err := buildEndpointRoutesFromFile(path)
+	require.NoError(t, err)
+	require.Len(t, routes, 3)
+
+	require.Equal(t, "http://localhost:3000/invoices", routes[0].URL)
+	require.Equal(t, []string{"invoice.*"}, routes[0].EventTypes)
+	require.Equal(t, []string{"Authorization: Bearer 123", "X-Service: billing"}, routes[0].ForwardHeaders)
+	require.False(t, routes[0].Connect)
+
+	require.Equal(t, "http://localhost:3001", routes[1].URL)
+	require.True(t, routes[1].Connect)
+	require.Equal(t, 5*time.Second, routes[1].Timeout)
+	require.Zero(t, routes[0].Timeout)
+
+	require.Equal(t, []string{"*"}, routes[2].EventTypes)
+	require.True(t, routes[2].IsEventDestination)
+}
+
+func TestBuildEndpointRoutesFromFile_JSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"routes":[{"forward_to":"localhost:3000","events":["charge.*"]}]}`), 0600))
+
+	routes, err := buildEndpointRoutesFromFile(path)
+	require.NoError(t, err)
+	require.Len(t, routes, 1)
+	require.Equal(t, "http://localhost:3000", routes[0].URL)
+	require.Equal(t, []string{"charge.*"}, routes[0].EventTypes)
+}
+
+func TestBuildEndpointRoutesFromFile_Invalid(t *testing.T) {
+	dir := t.TempDir()
+
+	missing := filepath.Join(dir, "missing.yaml")
+	require.NoError(t, os.WriteFile(missing, []byte("routes:\n  - events: [\"*\"]\n"), 0600))
+	_, err := buildEndpointRoutesFromFile(missing)
+	require.ErrorContains(t, err, "missing forward_to")
+
+	timeout := filepath.Join(dir, "timeout.yaml")
+	require.NoError(t, os.WriteFile(timeout, []byte("routes:\n  - forward_to: localhost:3000\n    timeout: soon\n"), 0600))
+	_, err = buildEndpointRoutesFromFile(timeout)
+	require.ErrorContains(t, err, "invalid timeout")
+
+	empty := filepath.Join(dir, "empty.yaml")
+	require.NoError(t, os.WriteFile(empty, []byte("routes: []\n"), 0600))
+	_, err = buildEndpointRoutesFromFile(empty)
+	require.ErrorContains(t, err, "does not define any routes")
+
+	_, err = buildEndpointRoutesFromFile(filepath.Join(dir, "nope.yaml"))
+	require.Error(t, err)
+}
+
+func TestInitWithRoutesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+routes:
+  - forward_to: localhost:3000/invoices
+    events: ["invoice.*"]
+  - forward_to: localhost:3001/subscriptions
+    events: ["customer.subscription.*"]
+`), 0600))
+
+	p, err := Init(context.Background(), &Config{RoutesFile: path})
+	require.NoError(t, err)
+
+	clients := p.webhookEventProcessor.endpointClients
+	require.Len(t, clients, 2)
+
+	require.True(t, clients[0].SupportsEventType(false, "invoice.paid"))
+	require.False(t, clients[0].SupportsEventType(false, "customer.subscription.created"))
+	require.False(t, clients[0].SupportsEventType(true, "invoice.paid"))
+
+	require.True(t, clients[1].SupportsEventType(false, "customer.subscription.created"))
+	require.False(t, clients[1].SupportsEventType(false, "customer.created"))
+}
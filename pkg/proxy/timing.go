@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// DefaultSlowThreshold is the response time above which forwards are flagged
+// as slow, because Stripe would likely time out waiting for an endpoint this
+// slow in production
+const DefaultSlowThreshold = 10 * time.Second
+
+// ForwardTiming breaks down the time taken to forward an event to a local
+// endpoint. DNS and Connect are zero when no lookup or new connection was
+// needed. Only Total is measured for transports that don't write HTTP requests
+// to a connection, like commands, mock responses and gRPC servers.
+type ForwardTiming struct {
+	// DNS is the time taken to resolve the endpoint's host
+	DNS time.Duration
+
+	// Connect is the time taken to open a connection to the endpoint,
+	// including the TLS handshake
+	Connect time.Duration
+
+	// TTFB is the time between sending the request and receiving the first
+	// byte of the response, zero when it wasn't measured
+	TTFB time.Duration
+
+	// Total is the time between sending the request and reading the full
+	// response
+	Total time.Duration
+}
+
+// Traced reports whether the phases of the request were measured.
+func (t ForwardTiming) Traced() bool {
+	return t.TTFB > 0
+}
+
+// Slow reports whether the endpoint took longer than threshold to respond.
+func (t ForwardTiming) Slow(threshold time.Duration) bool {
+	return threshold > 0 && t.Total > threshold
+}
+
+// forwardTrace records the httptrace events of a forward. Its hooks may be
+// called from other goroutines than the one sending the request.
+type forwardTrace struct {
+	mu sync.Mutex
+
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	gotConn      time.Time
+	firstByte    time.Time
+}
+
+func newForwardTrace(start time.Time) *forwardTrace {
+	return &forwardTrace{start: start}
+}
+
+func (t *forwardTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:             t.DNSStart,
+		DNSDone:              t.DNSDone,
+		ConnectStart:         t.ConnectStart,
+		GotConn:              t.GotConn,
+		GotFirstResponseByte: t.GotFirstResponseByte,
+	}
+}
+
+// DNSStart records the start of the lookup of the endpoint's host
+func (t *forwardTrace) DNSStart(httptrace.DNSStartInfo) {
+	t.mark(&t.dnsStart)
+}
+
+// DNSDone records the end of the lookup of the endpoint's host
+func (t *forwardTrace) DNSDone(httptrace.DNSDoneInfo) {
+	t.mark(&t.dnsDone)
+}
+
+// ConnectStart records the start of the first dial to the endpoint
+func (t *forwardTrace) ConnectStart(_, _ string) {
+	t.mark(&t.connectStart)
+}
+
+// GotConn records when a connection to the endpoint is ready to be used
+func (t *forwardTrace) GotConn(httptrace.GotConnInfo) {
+	t.mark(&t.gotConn)
+}
+
+// GotFirstResponseByte records when the first byte of the response arrives
+func (t *forwardTrace) GotFirstResponseByte() {
+	t.mark(&t.firstByte)
+}
+
+// mark sets ts to the current time, unless it was already set.
+func (t *forwardTrace) mark(ts *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if ts.IsZero() {
+		*ts = time.Now()
+	}
+}
+
+// timing returns the breakdown of a forward whose response was fully read
+// after total.
+func (t *forwardTrace) timing(total time.Duration) ForwardTiming {
+	timing := ForwardTiming{Total: total}
+	if t == nil {
+		return timing
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.dnsStart.IsZero() && !t.dnsDone.IsZero() {
+		timing.DNS = t.dnsDone.Sub(t.dnsStart)
+	}
+
+	if !t.connectStart.IsZero() && !t.gotConn.IsZero() {
+		timing.Connect = t.gotConn.Sub(t.connectStart)
+	}
+
+	if !t.firstByte.IsZero() {
+		timing.TTFB = t.firstByte.Sub(t.start)
+	}
+
+	return timing
+}
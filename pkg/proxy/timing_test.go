@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestForwardTiming_Slow(t *testing.T) {
+	timing := ForwardTiming{Total: 11 * time.Second}
+
+	require.True(t, timing.Slow(DefaultSlowThreshold))
+	require.False(t, timing.Slow(time.Minute))
+	require.False(t, timing.Slow(0))
+}
+
+func TestForwardTrace_Nil(t *testing.T) {
+	var trace *forwardTrace
+
+	require.Equal(t, ForwardTiming{Total: time.Second}, trace.timing(time.Second))
+}
+
+func TestPost_TracesTiming(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+
+	var timing ForwardTiming
+	client := NewEndpointClient(ts.URL, []string{}, false, []string{"*"}, false, &EndpointConfig{
+		ResponseHandler: EndpointResponseHandlerFunc(func(evtCtx eventContext, _ string, _ *http.Response) {
+			timing = evtCtx.trace.timing(time.Since(evtCtx.sentAt))
+			wg.Done()
+		}),
+	})
+
+	err := client.Post(eventContext{
+		event:       &StripeEvent{Type: "charge.succeeded"},
+		requestBody: `{"id":"evt_123"}`,
+	})
+	require.NoError(t, err)
+
+	wg.Wait()
+
+	// the test server listens on an IP address, so there's no DNS lookup
+	require.Zero(t, timing.DNS)
+	require.Positive(t, timing.Connect)
+	require.GreaterOrEqual(t, timing.TTFB, 20*time.Millisecond)
+	require.GreaterOrEqual(t, timing.Total, timing.TTFB)
+	require.True(t, timing.Traced())
+}
+
+func TestPost_UntracedTransport(t *testing.T) {
+	responder, err := NewMockResponder(http.StatusOK, 0, "")
+	require.NoError(t, err)
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+
+	var timing ForwardTiming
+	client := NewEndpointClient("http://localhost", []string{}, false, []string{"*"}, false, &EndpointConfig{
+		HTTPClient: &http.Client{Transport: responder},
+		ResponseHandler: EndpointResponseHandlerFunc(func(evtCtx eventContext, _ string, _ *http.Response) {
+			timing = evtCtx.trace.timing(time.Since(evtCtx.sentAt))
+			wg.Done()
+		}),
+	})
+
+	err = client.Post(eventContext{
+		event:       &StripeEvent{Type: "charge.succeeded"},
+		requestBody: `{"id":"evt_123"}`,
+	})
+	require.NoError(t, err)
+
+	wg.Wait()
+
+	// mock responses aren't written to a connection, so only the total time
+	// is known
+	require.False(t, timing.Traced())
+	require.Zero(t, timing.TTFB)
+}
@@ -0,0 +1,357 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/stripe/stripe-cli/pkg/errorcategory"
+)
+
+// PayloadTransform rewrites the JSON payload of an event before it's
+// forwarded to local endpoints.
+type PayloadTransform interface {
+	Transform(payload string) (string, error)
+}
+
+// expressionTransform sets or deletes a field of the payload. Fields are
+// written as gjson paths, like filters, and a leading dot is allowed so that
+// jq expressions can be used as is:
+//
+//	data.object.customer = "cus_local"
+//	.data.object.metadata = {"tenant": "acme"}
+//	del(data.object.payment_method_details)
+type expressionTransform struct {
+	expr  string
+	path  []string
+	value string // JSON encoded
+	del   bool
+}
+
+// ParsePayloadTransform parses a transform expression.
+func ParsePayloadTransform(expr string) (PayloadTransform, error) {
+	trimmed := strings.TrimSpace(expr)
+	if trimmed == "" {
+		return nil, errorcategory.New(errorcategory.UserInput, "transform expression cannot be empty")
+	}
+
+	if strings.HasPrefix(trimmed, "del(") && strings.HasSuffix(trimmed, ")") {
+		path, err := parseTransformPath(trimmed[len("del(") : len(trimmed)-1])
+		if err != nil {
+			return nil, errorcategory.Errorf(errorcategory.UserInput, "invalid transform %q: %v", expr, err)
+		}
+
+		return &expressionTransform{expr: expr, path: path, del: true}, nil
+	}
+
+	pos := findAssignment(trimmed)
+	if pos < 0 {
+		return nil, errorcategory.Errorf(errorcategory.UserInput, "invalid transform %q: expected path = value or del(path)", expr)
+	}
+
+	path, err := parseTransformPath(trimmed[:pos])
+	if err != nil {
+		return nil, errorcategory.Errorf(errorcategory.UserInput, "invalid transform %q: %v", expr, err)
+	}
+
+	rawValue := strings.TrimSpace(trimmed[pos+1:])
+	if rawValue == "" {
+		return nil, errorcategory.Errorf(errorcategory.UserInput, "invalid transform %q: missing value after =", expr)
+	}
+
+	value, err := parseTransformValue(rawValue)
+	if err != nil {
+		return nil, errorcategory.Errorf(errorcategory.UserInput, "invalid transform %q: %v", expr, err)
+	}
+
+	return &expressionTransform{expr: expr, path: path, value: value}, nil
+}
+
+// ParsePayloadTransforms parses a list of transform expressions.
+func ParsePayloadTransforms(exprs []string) ([]PayloadTransform, error) {
+	transforms := make([]PayloadTransform, 0, len(exprs))
+
+	for _, expr := range exprs {
+		transform, err := ParsePayloadTransform(expr)
+		if err != nil {
+			return nil, err
+		}
+
+		transforms = append(transforms, transform)
+	}
+
+	return transforms, nil
+}
+
+// String returns the expression the transform was parsed from.
+func (t *expressionTransform) String() string {
+	return t.expr
+}
+
+// Transform implements PayloadTransform. Objects missing along the path of a
+// field that is set are created. The payload is re-encoded, so its fields end
+// up sorted by name.
+func (t *expressionTransform) Transform(payload string) (string, error) {
+	doc, err := decodeJSON(payload)
+	if err != nil {
+		return "", err
+	}
+
+	// the value is decoded for every payload, so that later transforms
+	// setting fields inside of it don't modify it
+	var value interface{}
+	if !t.del {
+		value, err = decodeJSON(t.value)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	doc, err = t.apply(doc, t.path, value)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", t.expr, err)
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(doc); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// apply sets or deletes the field at path in node and returns the updated
+// node.
+func (t *expressionTransform) apply(node interface{}, path []string, value interface{}) (interface{}, error) {
+	key := path[0]
+	last := len(path) == 1
+
+	switch v := node.(type) {
+	case map[string]interface{}:
+		if last {
+			if t.del {
+				delete(v, key)
+			} else {
+				v[key] = value
+			}
+
+			return v, nil
+		}
+
+		child, ok := v[key]
+		if !ok || child == nil {
+			if t.del {
+				return v, nil
+			}
+
+			child = map[string]interface{}{}
+		}
+
+		updated, err := t.apply(child, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+
+		v[key] = updated
+
+		return v, nil
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(v) {
+			if t.del {
+				return v, nil
+			}
+
+			return nil, fmt.Errorf("%s is not an index of the array", key)
+		}
+
+		if last {
+			if t.del {
+				return append(v[:i], v[i+1:]...), nil
+			}
+
+			v[i] = value
+
+			return v, nil
+		}
+
+		updated, err := t.apply(v[i], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+
+		v[i] = updated
+
+		return v, nil
+	default:
+		if t.del {
+			return node, nil
+		}
+
+		return nil, fmt.Errorf("cannot set %s on a value that isn't an object", key)
+	}
+}
+
+// commandTransform pipes the payload through a command: the payload is
+// written to the command's standard input and its standard output, which
+// must be JSON, replaces the payload.
+type commandTransform struct {
+	command string
+}
+
+// NewCommandTransform returns a transform running command with the system
+// shell, e.g. jq -c '.data.object.customer = "cus_local"'.
+func NewCommandTransform(command string) PayloadTransform {
+	return &commandTransform{command: command}
+}
+
+// String returns the transform's command.
+func (t *commandTransform) String() string {
+	return t.command
+}
+
+// Transform implements PayloadTransform.
+func (t *commandTransform) Transform(payload string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := shellCommand(ctx, t.command)
+	cmd.Stdin = strings.NewReader(payload)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			return "", fmt.Errorf("%s: %w: %s", t.command, err, strings.TrimSpace(stderr.String()))
+		}
+
+		return "", fmt.Errorf("%s: %w", t.command, err)
+	}
+
+	transformed := strings.TrimSpace(stdout.String())
+	if !json.Valid([]byte(transformed)) {
+		return "", fmt.Errorf("%s: output isn't valid JSON", t.command)
+	}
+
+	return transformed, nil
+}
+
+// shellCommand returns a command running command with the system shell.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		// #nosec G204: the command is provided by the user through --transform-cmd or --forward-to
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+
+	// #nosec G204: the command is provided by the user through --transform-cmd or --forward-to
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// transformPayload applies every transform to the payload, in order.
+func transformPayload(transforms []PayloadTransform, payload string) (string, error) {
+	for _, transform := range transforms {
+		var err error
+
+		payload, err = transform.Transform(payload)
+		if err != nil {
+			return "", fmt.Errorf("transforming payload: %w", err)
+		}
+	}
+
+	return payload, nil
+}
+
+// findAssignment returns the position of the first = outside of a quoted
+// string that isn't part of a comparison operator.
+func findAssignment(expr string) int {
+	var quote byte
+
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '=':
+			if (i+1 < len(expr) && expr[i+1] == '=') || (i > 0 && strings.ContainsRune("!<>", rune(expr[i-1]))) {
+				return -1
+			}
+
+			return i
+		}
+	}
+
+	return -1
+}
+
+func parseTransformPath(raw string) ([]string, error) {
+	path := strings.TrimPrefix(strings.TrimSpace(raw), ".")
+	if path == "" {
+		return nil, errors.New("missing path")
+	}
+
+	segments := strings.Split(path, ".")
+	for _, segment := range segments {
+		if segment == "" {
+			return nil, fmt.Errorf("invalid path %s", raw)
+		}
+	}
+
+	return segments, nil
+}
+
+// parseTransformValue parses the right-hand side of an assignment into JSON.
+// JSON values are used as is, single-quoted and unquoted values are read as
+// strings.
+func parseTransformValue(raw string) (string, error) {
+	if raw[0] == '\'' {
+		if len(raw) < 2 || raw[len(raw)-1] != '\'' {
+			return "", errors.New("unterminated string")
+		}
+
+		raw = raw[1 : len(raw)-1]
+	} else if json.Valid([]byte(raw)) {
+		return raw, nil
+	} else if raw[0] == '"' || raw[0] == '{' || raw[0] == '[' {
+		return "", fmt.Errorf("invalid JSON value %s", raw)
+	}
+
+	value, err := json.Marshal(raw)
+	if err != nil {
+		return "", err
+	}
+
+	return string(value), nil
+}
+
+// decodeJSON decodes a JSON document, keeping numbers as they're written.
+func decodeJSON(data string) (interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
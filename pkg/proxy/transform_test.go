@@ -0,0 +1,190 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/stripe/stripe-cli/pkg/webhooks"
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+const transformTestPayload = `{
+  "id": "evt_123",
+  "type": "customer.subscription.created",
+  "data": {
+    "object": {
+      "customer": "cus_test",
+      "amount": 20000,
+      "url": "https://example.com/?a=1&b=2",
+      "items": [{"price": "price_1"}, {"price": "price_2"}],
+      "metadata": {"tenant": "acme"}
+    }
+  }
+}`
+
+func TestPayloadTransform(t *testing.T) {
+	tests := []struct {
+		expr  string
+		path  string
+		value string
+	}{
+		{`data.object.customer = "cus_local"`, "data.object.customer", `"cus_local"`},
+		{`.data.object.customer = 'cus_local'`, "data.object.customer", `"cus_local"`},
+		{`data.object.customer = cus_local`, "data.object.customer", `"cus_local"`},
+		{`data.object.amount = 100`, "data.object.amount", `100`},
+		{`data.object.metadata = {"tenant": "globex"}`, "data.object.metadata.tenant", `"globex"`},
+		{`data.object.items.1.price = "price_local"`, "data.object.items.1.price", `"price_local"`},
+		{`data.object.new.nested = true`, "data.object.new.nested", `true`},
+		{`del(data.object.metadata)`, "data.object.metadata", ``},
+		{`del(.data.object.items.0)`, "data.object.items.#", `1`},
+		{`del(data.object.missing.field)`, "data.object.missing", ``},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			transform, err := ParsePayloadTransform(test.expr)
+			require.NoError(t, err)
+
+			transformed, err := transform.Transform(transformTestPayload)
+			require.NoError(t, err)
+			require.Equal(t, test.value, gjson.Get(transformed, test.path).Raw)
+
+			// the rest of the payload is unchanged
+			require.Equal(t, "evt_123", gjson.Get(transformed, "id").String())
+			require.Equal(t, "https://example.com/?a=1&b=2", gjson.Get(transformed, "data.object.url").String())
+		})
+	}
+}
+
+func TestPayloadTransform_ValueIsntShared(t *testing.T) {
+	transforms, err := ParsePayloadTransforms([]string{
+		`data.object.metadata = {}`,
+		`data.object.metadata.tenant = "globex"`,
+	})
+	require.NoError(t, err)
+
+	transformed, err := transformPayload(transforms, transformTestPayload)
+	require.NoError(t, err)
+	require.Equal(t, "globex", gjson.Get(transformed, "data.object.metadata.tenant").String())
+
+	transformed, err = transforms[0].Transform(transformTestPayload)
+	require.NoError(t, err)
+	require.Equal(t, "{}", gjson.Get(transformed, "data.object.metadata").Raw)
+}
+
+func TestParsePayloadTransform_Errors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"data.object.customer",
+		"data.object.customer == 1",
+		"= 1",
+		"data.object.customer =",
+		"data..customer = 1",
+		`data.object.customer = "unterminated`,
+		`data.object.customer = 'unterminated`,
+		"del()",
+	} {
+		_, err := ParsePayloadTransform(expr)
+		require.Error(t, err, expr)
+	}
+}
+
+func TestPayloadTransform_InvalidPath(t *testing.T) {
+	transform, err := ParsePayloadTransform(`id.nested = 1`)
+	require.NoError(t, err)
+
+	_, err = transform.Transform(transformTestPayload)
+	require.Error(t, err)
+}
+
+func TestCommandTransform(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell commands are not available on windows")
+	}
+
+	transformed, err := NewCommandTransform(`sed 's/cus_test/cus_local/'`).Transform(transformTestPayload)
+	require.NoError(t, err)
+	require.Equal(t, "cus_local", gjson.Get(transformed, "data.object.customer").String())
+
+	_, err = NewCommandTransform("echo not json").Transform(transformTestPayload)
+	require.ErrorContains(t, err, "output isn't valid JSON")
+
+	_, err = NewCommandTransform("echo oops >&2; exit 1").Transform(transformTestPayload)
+	require.ErrorContains(t, err, "oops")
+}
+
+func TestPost_TransformsAndResignsPayload(t *testing.T) {
+	var body, signature string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf, _ := io.ReadAll(r.Body)
+		body = string(buf)
+		signature = r.Header.Get("Stripe-Signature")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	transforms, err := ParsePayloadTransforms([]string{`data.object.customer = "cus_local"`})
+	require.NoError(t, err)
+
+	var responded eventContext
+
+	client := NewEndpointClient(ts.URL, []string{}, false, []string{"*"}, false, &EndpointConfig{
+		SigningSecret: func() string { return "whsec_test" },
+		Transforms:    transforms,
+		ResponseHandler: EndpointResponseHandlerFunc(func(evtCtx eventContext, _ string, _ *http.Response) {
+			responded = evtCtx
+		}),
+	})
+
+	err = client.Post(eventContext{
+		event:          &StripeEvent{Type: "customer.subscription.created"},
+		requestBody:    transformTestPayload,
+		requestHeaders: map[string]string{"Stripe-Signature": "t=123,v1=hunter2"},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "cus_local", gjson.Get(body, "data.object.customer").String())
+
+	var timestamp int64
+	_, err = fmt.Sscanf(signature, "t=%d,", &timestamp)
+	require.NoError(t, err)
+	require.Equal(t, webhooks.GenerateSignatureHeader(time.Unix(timestamp, 0), []byte(body), "whsec_test"), signature)
+
+	// the original payload is kept for the journal and reforwarding
+	require.Equal(t, transformTestPayload, responded.requestBody)
+	require.Equal(t, body, responded.forwardedBody)
+}
+
+func TestPost_ReportsTransformFailures(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.FailNow(t, "Event shouldn't be forwarded")
+	}))
+	defer ts.Close()
+
+	transforms, err := ParsePayloadTransforms([]string{`id.nested = 1`})
+	require.NoError(t, err)
+
+	outCh := make(chan websocket.IElement, 1)
+	client := NewEndpointClient(ts.URL, []string{}, false, []string{"*"}, false, &EndpointConfig{
+		OutCh:      outCh,
+		Transforms: transforms,
+	})
+
+	err = client.Post(eventContext{
+		event:       &StripeEvent{Type: "customer.subscription.created"},
+		requestBody: transformTestPayload,
+	})
+	require.Error(t, err)
+
+	element := <-outCh
+	require.IsType(t, FailedToPostError{}, element.(websocket.ErrorElement).Error)
+}
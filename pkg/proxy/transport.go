@@ -0,0 +1,172 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	unixURLPrefix = "unix://"
+	execURLPrefix = "exec:"
+)
+
+// newEndpointTransport returns the http.RoundTripper used to reach the local
+// endpoint at forwardURL. Besides HTTP(S) URLs, events can be forwarded to an
+// HTTP server listening on a Unix domain socket (unix:///path/to/app.sock, or
+// unix:///path/to/app.sock:/webhooks to set the request path), to a gRPC
+// server (grpc://localhost:50051) or to a local command (exec:./handle.sh).
+func newEndpointTransport(forwardURL string, skipVerify bool) http.RoundTripper {
+	switch {
+	case strings.HasPrefix(forwardURL, unixURLPrefix):
+		return newUnixSocketTransport(strings.TrimPrefix(forwardURL, unixURLPrefix))
+	case strings.HasPrefix(forwardURL, grpcURLPrefix), strings.HasPrefix(forwardURL, grpcsURLPrefix):
+		return newGRPCTransport(forwardURL, skipVerify)
+	case strings.HasPrefix(forwardURL, execURLPrefix):
+		return &execTransport{command: strings.TrimPrefix(forwardURL, execURLPrefix)}
+	default:
+		return &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: skipVerify},
+		}
+	}
+}
+
+// isLocalTransportURL reports whether forwardURL targets a Unix socket, a
+// gRPC server or a command rather than an HTTP(S) endpoint.
+func isLocalTransportURL(forwardURL string) bool {
+	for _, prefix := range []string{unixURLPrefix, grpcURLPrefix, grpcsURLPrefix, execURLPrefix} {
+		if strings.HasPrefix(forwardURL, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unixSocketTransport sends requests to an HTTP server listening on a Unix
+// domain socket. Requests are sent to the path that follows the socket path
+// and a colon, like nginx does (/path/to/app.sock:/webhooks), and to the root
+// path when there is none.
+type unixSocketTransport struct {
+	transport *http.Transport
+	path      string
+	rawQuery  string
+}
+
+func newUnixSocketTransport(address string) *unixSocketTransport {
+	socketPath, requestPath := address, "/"
+	rawQuery := ""
+
+	if i := strings.Index(address, ":/"); i >= 0 {
+		socketPath, requestPath = address[:i], address[i+1:]
+
+		if j := strings.Index(requestPath, "?"); j >= 0 {
+			requestPath, rawQuery = requestPath[:j], requestPath[j+1:]
+		}
+	}
+
+	dialer := &net.Dialer{}
+
+	return &unixSocketTransport{
+		transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", socketPath)
+			},
+		},
+		path:     requestPath,
+		rawQuery: rawQuery,
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *unixSocketTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	outReq := req.Clone(req.Context())
+	outReq.URL = &url.URL{Scheme: "http", Host: "localhost", Path: t.path, RawQuery: t.rawQuery}
+
+	if outReq.Host == "" {
+		outReq.Host = "localhost"
+	}
+
+	resp, err := t.transport.RoundTrip(outReq)
+	if err != nil {
+		return nil, err
+	}
+
+	// report the response against the unix:// URL the user configured
+	resp.Request = req
+
+	return resp, nil
+}
+
+// execTransport delivers requests to a local command, which is run with the
+// system shell so that it can have arguments. The request body is
+// written to the command's standard input and every header is exposed as an
+// HTTP_* environment variable, the way CGI does. The command's output becomes
+// the response body; a zero exit code maps to a 200 status and any other exit
+// code to a 500.
+type execTransport struct {
+	command string
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *execTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := shellCommand(req.Context(), t.command)
+	cmd.Env = append(os.Environ(), headersToEnv(req)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if req.Body != nil {
+		cmd.Stdin = req.Body
+	}
+
+	status := http.StatusOK
+
+	err := cmd.Run()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, err
+		}
+
+		status = http.StatusInternalServerError
+		stdout.Write(stderr.Bytes())
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"text/plain"}},
+		Body:          io.NopCloser(&stdout),
+		ContentLength: int64(stdout.Len()),
+		Request:       req,
+	}, nil
+}
+
+func headersToEnv(req *http.Request) []string {
+	env := make([]string, 0, len(req.Header)+1)
+
+	if req.Host != "" {
+		env = append(env, "HTTP_HOST="+req.Host)
+	}
+
+	for k, v := range req.Header {
+		name := "HTTP_" + strings.ToUpper(strings.ReplaceAll(k, "-", "_"))
+		env = append(env, name+"="+strings.Join(v, ", "))
+	}
+
+	return env
+}
@@ -0,0 +1,171 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnixSocketTransport(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not available on windows")
+	}
+
+	socketPath := filepath.Join(t.TempDir(), "app.sock")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, "{}", string(body))
+		require.Equal(t, "t=123,v1=hunter2", r.Header.Get("Stripe-Signature"))
+
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("OK!"))
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	var rcvStatus int
+	var rcvBody string
+	var rcvURL string
+
+	client := NewEndpointClient(
+		parseURL("unix://"+socketPath),
+		[]string{},
+		false,
+		[]string{"*"},
+		false,
+		&EndpointConfig{
+			ResponseHandler: EndpointResponseHandlerFunc(func(evtCtx eventContext, forwardURL string, resp *http.Response) {
+				buf, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+
+				rcvStatus = resp.StatusCode
+				rcvBody = string(buf)
+				rcvURL = resp.Request.URL.String()
+			}),
+		},
+	)
+
+	err = client.Post(eventContext{
+		event:          &StripeEvent{ID: "evt_123"},
+		requestBody:    "{}",
+		requestHeaders: map[string]string{"Stripe-Signature": "t=123,v1=hunter2"},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusAccepted, rcvStatus)
+	require.Equal(t, "OK!", rcvBody)
+	require.Equal(t, "unix://"+socketPath, rcvURL)
+}
+
+func TestExecTransport(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not available on windows")
+	}
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "handle.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\necho \"$HTTP_STRIPE_SIGNATURE\"\ncat\n[ \"$HTTP_X_FAIL\" = \"yes\" ] && echo failed >&2 && exit 3\nexit 0\n"), 0700))
+
+	tests := []struct {
+		name           string
+		headers        []string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"success", []string{}, http.StatusOK, "t=123,v1=hunter2\n{}"},
+		{"failure", []string{"X-Fail: yes"}, http.StatusInternalServerError, "t=123,v1=hunter2\n{}failed\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rcvStatus int
+			var rcvBody string
+
+			client := NewEndpointClient(
+				parseURL("exec:"+script),
+				tt.headers,
+				false,
+				[]string{"*"},
+				false,
+				&EndpointConfig{
+					ResponseHandler: EndpointResponseHandlerFunc(func(evtCtx eventContext, forwardURL string, resp *http.Response) {
+						buf, err := io.ReadAll(resp.Body)
+						require.NoError(t, err)
+
+						rcvStatus = resp.StatusCode
+						rcvBody = string(buf)
+					}),
+				},
+			)
+
+			err := client.Post(eventContext{
+				event:          &StripeEvent{ID: "evt_123"},
+				requestBody:    "{}",
+				requestHeaders: map[string]string{"Stripe-Signature": "t=123,v1=hunter2"},
+			})
+			require.NoError(t, err)
+
+			require.Equal(t, tt.expectedStatus, rcvStatus)
+			require.Equal(t, tt.expectedBody, rcvBody)
+		})
+	}
+}
+
+func TestExecTransport_Arguments(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not available on windows")
+	}
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "handle.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\necho \"$1\"\n"), 0700))
+
+	var rcvBody string
+
+	client := NewEndpointClient(
+		parseURL("exec:"+script+" --verbose"),
+		[]string{},
+		false,
+		[]string{"*"},
+		false,
+		&EndpointConfig{
+			ResponseHandler: EndpointResponseHandlerFunc(func(evtCtx eventContext, forwardURL string, resp *http.Response) {
+				buf, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+
+				rcvBody = string(buf)
+			}),
+		},
+	)
+
+	err := client.Post(eventContext{event: &StripeEvent{ID: "evt_123"}, requestBody: "{}"})
+	require.NoError(t, err)
+	require.Equal(t, "--verbose\n", rcvBody)
+}
+
+func TestUnixSocketTransport_Path(t *testing.T) {
+	transport := newUnixSocketTransport("/tmp/app.sock:/webhooks/stripe?tenant=acme")
+	require.Equal(t, "/webhooks/stripe", transport.path)
+	require.Equal(t, "tenant=acme", transport.rawQuery)
+
+	transport = newUnixSocketTransport("/tmp/app.sock")
+	require.Equal(t, "/", transport.path)
+	require.Empty(t, transport.rawQuery)
+}
+
+func TestParseUrl_LocalTransports(t *testing.T) {
+	require.Equal(t, "unix:///tmp/app.sock", parseURL("unix:///tmp/app.sock"))
+	require.Equal(t, "unix:///tmp/app.sock:/webhooks", parseURL("unix:///tmp/app.sock:/webhooks"))
+	require.Equal(t, "grpc://localhost:50051", parseURL("grpc://localhost:50051"))
+	require.Equal(t, "exec:./handle.sh", parseURL("exec:./handle.sh"))
+}
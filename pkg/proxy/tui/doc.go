@@ -0,0 +1,4 @@
+// Package tui provides an interactive Bubble Tea dashboard for `stripe listen`,
+// listing received events and the responses of the local endpoints they were
+// forwarded to.
+package tui
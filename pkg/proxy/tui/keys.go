@@ -0,0 +1,75 @@
+package tui
+
+import (
+	"charm.land/bubbles/v2/key"
+)
+
+// KeyMap defines the keybindings for the dashboard.
+type KeyMap struct {
+	Quit      key.Binding
+	Up        key.Binding
+	Down      key.Binding
+	Newest    key.Binding
+	PageUp    key.Binding
+	PageDown  key.Binding
+	Resend    key.Binding
+	Reforward key.Binding
+	Help      key.Binding
+}
+
+// ShortHelp returns bindings for the short help view.
+func (k KeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Resend, k.Reforward, k.Help}
+}
+
+// FullHelp returns bindings grouped by column for the full help view.
+func (k KeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Up, k.Down, k.Newest},
+		{k.PageUp, k.PageDown},
+		{k.Resend, k.Reforward},
+		{k.Quit},
+	}
+}
+
+// DefaultKeyMap returns the default set of keybindings.
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Quit: key.NewBinding(
+			key.WithKeys("q", "ctrl+c"),
+			key.WithHelp("q", "quit"),
+		),
+		Up: key.NewBinding(
+			key.WithKeys("up", "k"),
+			key.WithHelp("↑/k", "previous event"),
+		),
+		Down: key.NewBinding(
+			key.WithKeys("down", "j"),
+			key.WithHelp("↓/j", "next event"),
+		),
+		Newest: key.NewBinding(
+			key.WithKeys("G", "end"),
+			key.WithHelp("G", "follow newest"),
+		),
+		PageUp: key.NewBinding(
+			key.WithKeys("pgup", "ctrl+u"),
+			key.WithHelp("pgup", "scroll details up"),
+		),
+		PageDown: key.NewBinding(
+			key.WithKeys("pgdown", "ctrl+d"),
+			key.WithHelp("pgdn", "scroll details down"),
+		),
+		Resend: key.NewBinding(
+			key.WithKeys("r"),
+			key.WithHelp("r", "resend from Stripe"),
+		),
+		Reforward: key.NewBinding(
+			key.WithKeys("f"),
+			key.WithHelp("f", "forward again"),
+		),
+		Help: key.NewBinding(
+			key.WithKeys("?"),
+			key.WithHelp("?", "help"),
+		),
+	}
+}
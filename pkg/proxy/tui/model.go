@@ -0,0 +1,717 @@
+package tui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"charm.land/bubbles/v2/help"
+	"charm.land/bubbles/v2/key"
+	"charm.land/bubbles/v2/viewport"
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+	"github.com/acarl005/stripansi"
+
+	"github.com/stripe/stripe-cli/pkg/docs/ui"
+	"github.com/stripe/stripe-cli/pkg/proxy"
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+const (
+	statusBarHeight      = 1
+	listHeaderHeight     = 1
+	separatorHeight      = 1
+	minListHeight        = 3
+	statusMessageTimeout = 3 * time.Second
+	timeFormat           = "15:04:05"
+)
+
+// Event identifies an event received by the dashboard, for the actions that
+// act on it.
+type Event struct {
+	ID      string
+	Type    string
+	Account string
+	Thin    bool
+}
+
+type elementMsg struct {
+	element websocket.IElement
+}
+
+type streamClosedMsg struct{}
+
+type statusMsg string
+
+type clearStatusMsg struct{}
+
+// delivery is the latest response of one endpoint to an event.
+type delivery struct {
+	url             string
+	status          int
+	attempt         int
+	latency         time.Duration
+	requestBody     string
+	forwardedBody   string
+	requestHeaders  map[string]string
+	sentHeaders     http.Header
+	responseHeaders http.Header
+	responseBody    string
+	retrying        bool
+}
+
+type eventRow struct {
+	event      Event
+	receivedAt time.Time
+	payload    string
+	deliveries []delivery
+}
+
+// Model is the Bubble Tea model of the listen dashboard.
+type Model struct {
+	// Components
+	detail viewport.Model
+	help   help.Model
+	keys   KeyMap
+	styles ui.Styles
+
+	// Actions
+	resend    func(context.Context, Event) error
+	reforward func(proxy.JournalEntry)
+
+	// Content
+	rows   []eventRow
+	index  map[string]int
+	cursor int
+	follow bool
+
+	// State
+	width         int
+	height        int
+	ready         bool
+	connection    string
+	statusMessage string
+	err           error
+}
+
+// Option configures a Model.
+type Option func(*Model)
+
+// WithResend sets the function used to ask Stripe to resend the selected event.
+func WithResend(resend func(context.Context, Event) error) Option {
+	return func(m *Model) { m.resend = resend }
+}
+
+// WithReforward sets the function used to forward the selected event to the
+// local endpoints again.
+func WithReforward(reforward func(proxy.JournalEntry)) Option {
+	return func(m *Model) { m.reforward = reforward }
+}
+
+// WithKeyMap sets a custom keymap.
+func WithKeyMap(km KeyMap) Option {
+	return func(m *Model) { m.keys = km }
+}
+
+// WithStyles sets custom styles.
+func WithStyles(s ui.Styles) Option {
+	return func(m *Model) { m.styles = s }
+}
+
+// New creates a Model configured with the given options.
+func New(opts ...Option) Model {
+	h := help.New()
+	h.FullSeparator = " • "
+
+	m := Model{
+		keys:       DefaultKeyMap(),
+		help:       h,
+		styles:     ui.DefaultStyles(),
+		index:      make(map[string]int),
+		follow:     true,
+		connection: "Getting ready...",
+	}
+
+	for _, opt := range opts {
+		opt(&m)
+	}
+
+	m.keys.Resend.SetEnabled(m.resend != nil)
+	m.keys.Reforward.SetEnabled(m.reforward != nil)
+
+	return m
+}
+
+// Run displays the dashboard for the elements received on outCh until the user
+// quits or the stream ends. It returns the error that ended the stream, if any.
+func Run(outCh <-chan websocket.IElement, opts ...Option) error {
+	program := tea.NewProgram(New(opts...))
+
+	go func() {
+		for el := range outCh {
+			program.Send(elementMsg{element: el})
+		}
+		program.Send(streamClosedMsg{})
+	}()
+
+	final, err := program.Run()
+	if err != nil {
+		return fmt.Errorf("running TUI: %w", err)
+	}
+
+	return final.(Model).err
+}
+
+// Init returns the initial command to run when the TUI starts.
+func (m Model) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles incoming messages and updates the model state.
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		if !m.ready {
+			m.detail = viewport.New()
+			m.detail.KeyMap = viewport.KeyMap{}
+			m.ready = true
+		}
+		m.help.SetWidth(msg.Width)
+		m.resize()
+		m.refreshDetail(false)
+		return m, nil
+
+	case elementMsg:
+		if err := msg.element.Accept(m.visitor()); err != nil {
+			m.err = err
+			return m, tea.Quit
+		}
+		m.refreshDetail(false)
+		return m, nil
+
+	case streamClosedMsg:
+		return m, tea.Quit
+
+	case statusMsg:
+		m.statusMessage = string(msg)
+		return m, tea.Tick(statusMessageTimeout, func(_ time.Time) tea.Msg {
+			return clearStatusMsg{}
+		})
+
+	case clearStatusMsg:
+		m.statusMessage = ""
+		return m, nil
+
+	case tea.KeyPressMsg:
+		return m.handleKey(msg)
+	}
+
+	var cmd tea.Cmd
+	if m.ready {
+		m.detail, cmd = m.detail.Update(msg)
+	}
+
+	return m, cmd
+}
+
+// visitor applies the elements sent by the proxy to the model.
+func (m *Model) visitor() *websocket.Visitor {
+	return &websocket.Visitor{
+		VisitError: func(ee websocket.ErrorElement) error {
+			switch ee.Error.(type) {
+			case proxy.FailedToPostError, proxy.FailedToReadResponseError:
+				m.statusMessage = ee.Error.Error()
+				return nil
+			default:
+				return ee.Error
+			}
+		},
+		VisitWarning: func(we websocket.WarningElement) error {
+			m.statusMessage = we.Warning
+			return nil
+		},
+		VisitStatus: func(se websocket.StateElement) error {
+			switch se.State {
+			case websocket.Loading:
+				m.connection = "Getting ready..."
+			case websocket.Reconnecting:
+				m.connection = "Session expired, reconnecting..."
+			case websocket.Ready:
+				m.connection = "Ready"
+				if len(se.Data) > 1 {
+					m.connection = fmt.Sprintf("Ready · %s", se.Data[1])
+				}
+			case websocket.Done:
+				m.connection = "Done"
+			}
+			return nil
+		},
+		VisitData: func(de websocket.DataElement) error {
+			switch data := de.Data.(type) {
+			case proxy.StripeEvent:
+				// the payload is shown as it was delivered, rather than only
+				// its data
+				payload := prettyJSON(stripansi.Strip(de.Marshaled))
+				m.addEvent(Event{ID: data.ID, Type: data.Type, Account: data.Account}, payload)
+			case proxy.V2EventPayload:
+				payload, _ := json.MarshalIndent(data, "", "  ")
+				m.addEvent(Event{ID: data.ID, Type: data.Type, Account: data.Context, Thin: true}, string(payload))
+			case proxy.EndpointResponse:
+				m.addResponse(data)
+			case proxy.EndpointRetry:
+				m.addRetry(data)
+			case proxy.DuplicateSuppressed:
+				if data.Event != nil {
+					m.statusMessage = "Suppressed duplicate delivery of " + data.Event.ID
+				} else if data.V2Event != nil {
+					m.statusMessage = "Suppressed duplicate delivery of " + data.V2Event.ID
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func (m *Model) addEvent(event Event, payload string) {
+	if i, ok := m.index[event.ID]; ok {
+		// the event was resent by Stripe or forwarded again
+		m.rows[i].receivedAt = time.Now()
+		return
+	}
+
+	m.index[event.ID] = len(m.rows)
+	m.rows = append(m.rows, eventRow{
+		event:      event,
+		receivedAt: time.Now(),
+		payload:    payload,
+	})
+
+	if m.follow {
+		m.cursor = len(m.rows) - 1
+		m.refreshDetail(true)
+	}
+}
+
+func (m *Model) addResponse(resp proxy.EndpointResponse) {
+	eventID := ""
+	switch {
+	case resp.Event != nil:
+		eventID = resp.Event.ID
+	case resp.V2Event != nil:
+		eventID = resp.V2Event.ID
+	}
+
+	i, ok := m.index[eventID]
+	if !ok || resp.Resp == nil {
+		return
+	}
+
+	d := delivery{
+		url:             forwardURL(resp.Resp),
+		status:          resp.Resp.StatusCode,
+		attempt:         resp.Attempt,
+		latency:         resp.Latency,
+		requestBody:     resp.RequestBody,
+		forwardedBody:   resp.ForwardedBody,
+		requestHeaders:  resp.RequestHeaders,
+		responseHeaders: resp.Resp.Header,
+		responseBody:    resp.ResponseBody,
+	}
+	if resp.Resp.Request != nil {
+		d.sentHeaders = resp.Resp.Request.Header
+	}
+
+	m.rows[i].setDelivery(d)
+}
+
+func (m *Model) addRetry(retry proxy.EndpointRetry) {
+	eventID := ""
+	switch {
+	case retry.Event != nil:
+		eventID = retry.Event.ID
+	case retry.V2Event != nil:
+		eventID = retry.V2Event.ID
+	}
+
+	i, ok := m.index[eventID]
+	if !ok {
+		return
+	}
+
+	for j := range m.rows[i].deliveries {
+		if m.rows[i].deliveries[j].url == retry.URL {
+			m.rows[i].deliveries[j].retrying = true
+			return
+		}
+	}
+
+	m.rows[i].deliveries = append(m.rows[i].deliveries, delivery{url: retry.URL, status: retry.Status, attempt: retry.Attempt, retrying: true})
+}
+
+func (r *eventRow) setDelivery(d delivery) {
+	for j := range r.deliveries {
+		if r.deliveries[j].url == d.url {
+			r.deliveries[j] = d
+			return
+		}
+	}
+
+	r.deliveries = append(r.deliveries, d)
+}
+
+// handleKey processes a key press.
+func (m Model) handleKey(msg tea.KeyPressMsg) (Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Quit):
+		return m, tea.Quit
+	case key.Matches(msg, m.keys.Help):
+		m.help.ShowAll = !m.help.ShowAll
+		m.resize()
+	case key.Matches(msg, m.keys.Up):
+		if m.cursor > 0 {
+			m.cursor--
+			m.follow = false
+			m.refreshDetail(true)
+		}
+	case key.Matches(msg, m.keys.Down):
+		if m.cursor < len(m.rows)-1 {
+			m.cursor++
+			m.follow = m.cursor == len(m.rows)-1
+			m.refreshDetail(true)
+		}
+	case key.Matches(msg, m.keys.Newest):
+		if len(m.rows) > 0 {
+			m.cursor = len(m.rows) - 1
+			m.follow = true
+			m.refreshDetail(true)
+		}
+	case key.Matches(msg, m.keys.PageUp):
+		m.detail.PageUp()
+	case key.Matches(msg, m.keys.PageDown):
+		m.detail.PageDown()
+	case key.Matches(msg, m.keys.Resend):
+		return m, m.resendCmd()
+	case key.Matches(msg, m.keys.Reforward):
+		return m, m.reforwardCmd()
+	}
+
+	return m, nil
+}
+
+func (m Model) selected() (eventRow, bool) {
+	if m.cursor < 0 || m.cursor >= len(m.rows) {
+		return eventRow{}, false
+	}
+
+	return m.rows[m.cursor], true
+}
+
+func (m Model) resendCmd() tea.Cmd {
+	row, ok := m.selected()
+	if !ok || m.resend == nil {
+		return nil
+	}
+
+	resend := m.resend
+	return func() tea.Msg {
+		if err := resend(context.Background(), row.event); err != nil {
+			return statusMsg(fmt.Sprintf("Failed to resend %s: %v", row.event.ID, err))
+		}
+		return statusMsg(fmt.Sprintf("Asked Stripe to resend %s", row.event.ID))
+	}
+}
+
+func (m Model) reforwardCmd() tea.Cmd {
+	row, ok := m.selected()
+	if !ok || m.reforward == nil {
+		return nil
+	}
+
+	if len(row.deliveries) == 0 || row.deliveries[0].requestBody == "" {
+		return func() tea.Msg {
+			return statusMsg(fmt.Sprintf("%s hasn't been forwarded yet", row.event.ID))
+		}
+	}
+
+	entry := proxy.JournalEntry{
+		Kind:        proxy.JournalEntryEvent,
+		EventID:     row.event.ID,
+		EventType:   row.event.Type,
+		Thin:        row.event.Thin,
+		Payload:     row.deliveries[0].requestBody,
+		HTTPHeaders: row.deliveries[0].requestHeaders,
+	}
+
+	reforward := m.reforward
+	return func() tea.Msg {
+		reforward(entry)
+		return statusMsg(fmt.Sprintf("Forwarded %s again", row.event.ID))
+	}
+}
+
+// View renders the current model state to the terminal.
+func (m Model) View() tea.View {
+	if !m.ready {
+		return tea.NewView("loading...")
+	}
+
+	content := m.list() + "\n" + m.separator() + "\n" + m.detail.View() + "\n" + m.status()
+	if m.help.ShowAll {
+		content += "\n" + m.helpView()
+	}
+
+	view := tea.NewView(content)
+	view.AltScreen = true
+	view.WindowTitle = "stripe listen"
+
+	return view
+}
+
+func (m *Model) resize() {
+	if !m.ready {
+		return
+	}
+
+	m.detail.SetWidth(m.width)
+	m.detail.SetHeight(max(1, m.height-statusBarHeight-m.listHeight()-listHeaderHeight-separatorHeight-m.helpHeight()))
+}
+
+func (m Model) listHeight() int {
+	return max(minListHeight, (m.height-statusBarHeight-m.helpHeight())/3)
+}
+
+func (m Model) helpView() string {
+	return lipgloss.NewStyle().PaddingTop(1).Render(m.help.View(m.keys))
+}
+
+func (m Model) helpHeight() int {
+	if !m.help.ShowAll {
+		return 0
+	}
+
+	return strings.Count(m.helpView(), "\n") + 1
+}
+
+func (m Model) list() string {
+	height := m.listHeight()
+
+	lines := []string{m.styles.Muted.Render(fmt.Sprintf("  %-8s  %-6s  %-8s  %s", "TIME", "STATUS", "LATENCY", "EVENT"))}
+
+	// keep the selected event in view
+	start := max(0, m.cursor-height+1)
+
+	for i := start; i < len(m.rows) && i < start+height; i++ {
+		lines = append(lines, m.rowView(m.rows[i], i == m.cursor))
+	}
+
+	for len(lines) < height+listHeaderHeight {
+		lines = append(lines, "")
+	}
+
+	if len(m.rows) == 0 {
+		lines[1] = m.styles.Muted.Render("  Waiting for events...")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (m Model) rowView(row eventRow, selected bool) string {
+	status, latency := "-", ""
+	statusStyle := m.styles.Muted
+
+	if d, ok := row.worstDelivery(); ok {
+		switch {
+		case d.retrying:
+			status = "retry"
+			statusStyle = m.styles.Error
+		case d.status >= 200 && d.status < 300:
+			status = fmt.Sprintf("%d", d.status)
+			statusStyle = m.styles.SuccessText
+		default:
+			status = fmt.Sprintf("%d", d.status)
+			statusStyle = m.styles.Error
+		}
+
+		if d.latency > 0 {
+			latency = d.latency.Round(time.Millisecond).String()
+		}
+	}
+
+	prefix := "  "
+	if selected {
+		prefix = m.styles.Title.Render("▸ ")
+	}
+
+	name := row.event.Type + " " + m.styles.Muted.Render("["+row.event.ID+"]")
+	if selected {
+		name = m.styles.Title.Render(row.event.Type) + " " + m.styles.Muted.Render("["+row.event.ID+"]")
+	}
+
+	line := fmt.Sprintf("%s%-8s  %s  %-8s  %s",
+		prefix,
+		row.receivedAt.Format(timeFormat),
+		statusStyle.Render(fmt.Sprintf("%-6s", status)),
+		latency,
+		name,
+	)
+
+	return lipgloss.NewStyle().MaxWidth(max(1, m.width)).Render(line)
+}
+
+// worstDelivery returns the delivery to summarize the event with: one being
+// retried or that failed, if any.
+func (r eventRow) worstDelivery() (delivery, bool) {
+	if len(r.deliveries) == 0 {
+		return delivery{}, false
+	}
+
+	worst := r.deliveries[0]
+	for _, d := range r.deliveries[1:] {
+		if d.retrying || d.status > worst.status {
+			worst = d
+		}
+	}
+
+	return worst, true
+}
+
+func (m Model) separator() string {
+	return m.styles.Muted.Render(strings.Repeat("─", max(1, m.width)))
+}
+
+// refreshDetail renders the selected event in the detail pane, scrolling back
+// to the top when the selection changed.
+func (m *Model) refreshDetail(selectionChanged bool) {
+	if !m.ready {
+		return
+	}
+
+	row, ok := m.selected()
+	if !ok {
+		m.detail.SetContent("")
+		return
+	}
+
+	offset := m.detail.YOffset()
+	m.detail.SetContent(m.detailView(row))
+	if selectionChanged {
+		m.detail.GotoTop()
+	} else {
+		m.detail.SetYOffset(offset)
+	}
+}
+
+func (m Model) detailView(row eventRow) string {
+	var sb strings.Builder
+
+	sb.WriteString(m.styles.Title.Render(row.event.Type) + "  " + row.event.ID)
+	if row.event.Account != "" {
+		sb.WriteString(m.styles.Muted.Render("  " + row.event.Account))
+	}
+	sb.WriteString("\n" + m.styles.Muted.Render("Received at "+row.receivedAt.Format(timeFormat)) + "\n")
+
+	payload := row.payload
+
+	for _, d := range row.deliveries {
+		sb.WriteString("\n")
+
+		status := fmt.Sprintf("[%d]", d.status)
+		if d.retrying {
+			status = "[retrying]"
+		}
+
+		line := fmt.Sprintf("→ %s %s", d.url, status)
+		if d.latency > 0 {
+			line += " " + d.latency.Round(time.Millisecond).String()
+		}
+		if d.attempt > 1 {
+			line += fmt.Sprintf(" (attempt %d)", d.attempt)
+		}
+		sb.WriteString(m.styles.Title.Render(line) + "\n")
+
+		writeHeaders(&sb, m.styles.Muted.Render("Request headers"), d.sentHeaders)
+		writeHeaders(&sb, m.styles.Muted.Render("Response headers"), d.responseHeaders)
+
+		if d.responseBody != "" {
+			sb.WriteString(m.styles.Muted.Render("Response body") + "\n")
+			sb.WriteString(indent(d.responseBody) + "\n")
+		}
+
+		// the payload the endpoint received is shown, transforms included
+		if d.forwardedBody != "" {
+			payload = prettyJSON(d.forwardedBody)
+		} else if d.requestBody != "" {
+			payload = prettyJSON(d.requestBody)
+		}
+	}
+
+	sb.WriteString("\n" + m.styles.Muted.Render("Payload") + "\n")
+	sb.WriteString(indent(payload))
+
+	return sb.String()
+}
+
+func (m Model) status() string {
+	bar := m.styles.Bar
+	title := m.styles.Brand.Render("Stripe")
+
+	message := m.connection
+	if m.statusMessage != "" {
+		bar = m.styles.Success
+		message = m.statusMessage
+	}
+
+	left := title + bar.Padding(0, 1).Render(message)
+	right := bar.Padding(0, 1).Render(fmt.Sprintf("%d events", len(m.rows))) + m.styles.StatusHelp.Render("? help")
+
+	gap := max(0, m.width-lipgloss.Width(left)-lipgloss.Width(right))
+	fill := lipgloss.PlaceHorizontal(gap, lipgloss.Left, "",
+		lipgloss.WithWhitespaceStyle(bar))
+
+	return left + fill + right
+}
+
+func writeHeaders(sb *strings.Builder, title string, headers http.Header) {
+	if len(headers) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	sb.WriteString(title + "\n")
+	for _, k := range keys {
+		sb.WriteString(fmt.Sprintf("  %s: %s\n", k, strings.Join(headers[k], ", ")))
+	}
+}
+
+func forwardURL(resp *http.Response) string {
+	if resp.Request == nil || resp.Request.URL == nil {
+		return ""
+	}
+
+	return resp.Request.URL.String()
+}
+
+func prettyJSON(body string) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(body), "", "  "); err != nil {
+		return body
+	}
+
+	return buf.String()
+}
+
+func indent(s string) string {
+	return "  " + strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n  ")
+}
@@ -0,0 +1,229 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/proxy"
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+func update(t *testing.T, m Model, msgs ...tea.Msg) (Model, tea.Cmd) {
+	t.Helper()
+
+	var cmd tea.Cmd
+	for _, msg := range msgs {
+		var result tea.Model
+		result, cmd = m.Update(msg)
+		m = result.(Model)
+	}
+
+	return m, cmd
+}
+
+func eventMsg(id, eventType string) elementMsg {
+	return elementMsg{element: websocket.DataElement{
+		Data:      proxy.StripeEvent{ID: id, Type: eventType, Data: map[string]interface{}{"object": map[string]interface{}{"id": "ch_123"}}},
+		Marshaled: `{"data":{"object":{"id":"ch_123"}},"id":"` + id + `","object":"event","type":"` + eventType + `"}` + "\n",
+	}}
+}
+
+func responseMsg(id string, status int) elementMsg {
+	forwardURL, _ := url.Parse("http://localhost:3000/webhooks")
+
+	return elementMsg{element: websocket.DataElement{
+		Data: proxy.EndpointResponse{
+			Event: &proxy.StripeEvent{ID: id},
+			Resp: &http.Response{
+				StatusCode: status,
+				Header:     http.Header{"Content-Type": []string{"text/plain"}},
+				Request:    &http.Request{URL: forwardURL, Header: http.Header{"Stripe-Signature": []string{"t=123,v1=abc"}}},
+			},
+			Attempt:        1,
+			RequestBody:    `{"id":"` + id + `"}`,
+			ForwardedBody:  `{"id":"` + id + `","livemode":true}`,
+			RequestHeaders: map[string]string{"Stripe-Signature": "t=123,v1=abc"},
+			ResponseBody:   "thanks",
+			Latency:        42 * time.Millisecond,
+		},
+	}}
+}
+
+func TestUpdate_EventsAndResponses(t *testing.T) {
+	m, _ := update(t, New(),
+		tea.WindowSizeMsg{Width: 120, Height: 40},
+		elementMsg{element: websocket.StateElement{State: websocket.Ready, Data: []string{"", "whsec_123"}}},
+		eventMsg("evt_1", "charge.succeeded"),
+		eventMsg("evt_2", "invoice.paid"),
+		responseMsg("evt_1", 500),
+	)
+
+	require.Len(t, m.rows, 2)
+	assert.Equal(t, 1, m.cursor, "the newest event is selected")
+	assert.Equal(t, "Ready · whsec_123", m.connection)
+
+	d, ok := m.rows[0].worstDelivery()
+	require.True(t, ok)
+	assert.Equal(t, 500, d.status)
+	assert.Equal(t, "http://localhost:3000/webhooks", d.url)
+	assert.Equal(t, 42*time.Millisecond, d.latency)
+
+	// moving up shows the first event's delivery in the detail pane
+	m, _ = update(t, m, tea.KeyPressMsg{Code: tea.KeyUp})
+	assert.Equal(t, 0, m.cursor)
+	assert.False(t, m.follow)
+
+	view := m.View().Content
+	assert.Contains(t, view, "charge.succeeded")
+	assert.Contains(t, view, "invoice.paid")
+	assert.Contains(t, view, "http://localhost:3000/webhooks [500] 42ms")
+	assert.Contains(t, view, "Stripe-Signature: t=123,v1=abc")
+	assert.Contains(t, view, "thanks")
+	assert.Contains(t, view, `"livemode": true`, "the payload shown is the forwarded one")
+
+	// new events don't move the selection while browsing
+	m, _ = update(t, m, eventMsg("evt_3", "charge.refunded"))
+	assert.Equal(t, 0, m.cursor)
+}
+
+func TestUpdate_RepeatedEventUpdatesRow(t *testing.T) {
+	m, _ := update(t, New(),
+		tea.WindowSizeMsg{Width: 120, Height: 40},
+		eventMsg("evt_1", "charge.succeeded"),
+		responseMsg("evt_1", 500),
+		eventMsg("evt_1", "charge.succeeded"),
+		responseMsg("evt_1", 200),
+	)
+
+	require.Len(t, m.rows, 1)
+	require.Len(t, m.rows[0].deliveries, 1)
+	assert.Equal(t, 200, m.rows[0].deliveries[0].status)
+}
+
+func TestUpdate_EventPayload(t *testing.T) {
+	m, _ := update(t, New(),
+		tea.WindowSizeMsg{Width: 120, Height: 40},
+		eventMsg("evt_1", "charge.succeeded"),
+	)
+
+	require.Len(t, m.rows, 1)
+
+	// the whole event is shown, not only its data
+	detail := m.detailView(m.rows[0])
+	assert.Contains(t, detail, `"object": "event"`)
+	assert.Contains(t, detail, `"id": "ch_123"`)
+}
+
+func TestUpdate_Retry(t *testing.T) {
+	m, _ := update(t, New(),
+		tea.WindowSizeMsg{Width: 120, Height: 40},
+		eventMsg("evt_1", "charge.succeeded"),
+		elementMsg{element: websocket.DataElement{Data: proxy.EndpointRetry{
+			Event:   &proxy.StripeEvent{ID: "evt_1"},
+			URL:     "http://localhost:3000/webhooks",
+			Status:  503,
+			Attempt: 1,
+		}}},
+	)
+
+	d, ok := m.rows[0].worstDelivery()
+	require.True(t, ok)
+	assert.True(t, d.retrying)
+	assert.Contains(t, m.View().Content, "retry")
+
+	m, _ = update(t, m, responseMsg("evt_1", 200))
+	assert.False(t, m.rows[0].deliveries[0].retrying)
+}
+
+func TestUpdate_FatalError(t *testing.T) {
+	m, cmd := update(t, New(),
+		tea.WindowSizeMsg{Width: 120, Height: 40},
+		elementMsg{element: websocket.ErrorElement{Error: errors.New("session expired")}},
+	)
+
+	require.EqualError(t, m.err, "session expired")
+	require.NotNil(t, cmd)
+	assert.IsType(t, tea.QuitMsg{}, cmd())
+}
+
+func TestUpdate_PostErrorIsNotFatal(t *testing.T) {
+	m, _ := update(t, New(),
+		tea.WindowSizeMsg{Width: 120, Height: 40},
+		elementMsg{element: websocket.ErrorElement{Error: proxy.FailedToPostError{Err: errors.New("connection refused")}}},
+	)
+
+	require.NoError(t, m.err)
+	assert.Equal(t, "connection refused", m.statusMessage)
+}
+
+func TestUpdate_Resend(t *testing.T) {
+	var resent []Event
+	m := New(WithResend(func(_ context.Context, evt Event) error {
+		resent = append(resent, evt)
+		return nil
+	}))
+
+	m, cmd := update(t, m,
+		tea.WindowSizeMsg{Width: 120, Height: 40},
+		eventMsg("evt_1", "charge.succeeded"),
+		tea.KeyPressMsg{Code: 'r', Text: "r"},
+	)
+	require.NotNil(t, cmd)
+
+	m, _ = update(t, m, cmd())
+	require.Equal(t, []Event{{ID: "evt_1", Type: "charge.succeeded"}}, resent)
+	assert.Equal(t, "Asked Stripe to resend evt_1", m.statusMessage)
+}
+
+func TestUpdate_Reforward(t *testing.T) {
+	var entries []proxy.JournalEntry
+	m := New(WithReforward(func(entry proxy.JournalEntry) {
+		entries = append(entries, entry)
+	}))
+
+	// events that were never forwarded can't be forwarded again
+	m, cmd := update(t, m,
+		tea.WindowSizeMsg{Width: 120, Height: 40},
+		eventMsg("evt_1", "charge.succeeded"),
+		tea.KeyPressMsg{Code: 'f', Text: "f"},
+	)
+	m, _ = update(t, m, cmd())
+	assert.Empty(t, entries)
+	assert.Contains(t, m.statusMessage, "hasn't been forwarded")
+
+	m, cmd = update(t, m,
+		responseMsg("evt_1", 500),
+		tea.KeyPressMsg{Code: 'f', Text: "f"},
+	)
+	update(t, m, cmd())
+
+	require.Len(t, entries, 1)
+	assert.Equal(t, "evt_1", entries[0].EventID)
+	assert.Equal(t, `{"id":"evt_1"}`, entries[0].Payload)
+	assert.Equal(t, map[string]string{"Stripe-Signature": "t=123,v1=abc"}, entries[0].HTTPHeaders)
+}
+
+func TestNew_DisablesMissingActions(t *testing.T) {
+	m := New()
+	assert.False(t, m.keys.Resend.Enabled())
+	assert.False(t, m.keys.Reforward.Enabled())
+}
+
+func TestUpdate_DuplicateSuppressed(t *testing.T) {
+	m, _ := update(t, New(),
+		tea.WindowSizeMsg{Width: 120, Height: 40},
+		eventMsg("evt_1", "charge.succeeded"),
+		elementMsg{element: websocket.DataElement{Data: proxy.DuplicateSuppressed{Event: &proxy.StripeEvent{ID: "evt_1"}}}},
+	)
+
+	require.Len(t, m.rows, 1)
+	assert.Equal(t, "Suppressed duplicate delivery of evt_1", m.statusMessage)
+}
@@ -1,14 +1,15 @@
 package proxy
 
 import (
-	"crypto/tls"
 	"encoding/json"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/stripe/stripe-cli/pkg/matcher"
 	"github.com/stripe/stripe-cli/pkg/websocket"
 )
 
@@ -33,11 +34,58 @@ type WebhookEventProcessorConfig struct {
 	// Indicates whether to skip certificate verification when forwarding webhooks to HTTPS endpoints
 	SkipVerify bool
 
-	// Override default timeout
-	Timeout int64
+	// Timeout is the time to wait for endpoints to respond, unless their
+	// route sets its own (default: 30s)
+	Timeout time.Duration
 
 	// LoggedInAccountID is the currently logged-in account ID
 	LoggedInAccountID string
+
+	// Journal, when set, records every received event and endpoint response
+	Journal *Journal
+
+	// RetryPolicy controls how forwards that fail are retried
+	RetryPolicy RetryPolicy
+
+	// DeliveryConcurrency is the maximum number of events forwarded to each
+	// endpoint at the same time, 0 meaning unlimited
+	DeliveryConcurrency int
+
+	// DeliveryQueueSize is the number of events that can wait for delivery to
+	// each endpoint before event processing blocks
+	DeliveryQueueSize int
+
+	// Filters are payload predicates that events must all satisfy to be
+	// printed and forwarded
+	Filters []*PayloadFilter
+
+	// ResignEvents indicates whether to re-compute the Stripe-Signature
+	// header of forwarded events, with SigningSecret or, when it is empty,
+	// the secret set with SetSigningSecret
+	ResignEvents bool
+
+	// SigningSecret is the secret used to re-sign forwarded events
+	SigningSecret string
+
+	// Metrics, when set, records received events and forwards
+	Metrics *Metrics
+
+	// CatchUp indicates whether to keep track of delivered events, so that
+	// the events missed while disconnected can be caught up with CatchUp
+	CatchUp bool
+
+	// Dedupe indicates whether to suppress the deliveries of events that were
+	// already forwarded to every endpoint. Suppressed deliveries are answered
+	// with the endpoints' responses to the first one.
+	Dedupe bool
+
+	// DedupeSize is the number of deliveries remembered to detect duplicates
+	// (default: DefaultDedupeSize)
+	DedupeSize int
+
+	// Transforms rewrite the payload of events before they're forwarded.
+	// Transformed events are re-signed like with ResignEvents.
+	Transforms []PayloadTransform
 }
 
 // WebhookEventProcessor encapsulates logic around processing and forwarding
@@ -46,23 +94,57 @@ type WebhookEventProcessor struct {
 	cfg *WebhookEventProcessorConfig
 
 	// Events is the supported event types for the command
-	events          map[string]bool
-	thinEvents      map[string]bool
+	events          *matcher.Matcher
+	thinEvents      *matcher.Matcher
 	endpointClients []*EndpointClient
 	sendMessage     func(*websocket.OutgoingMessage)
+
+	signingSecretMu sync.RWMutex
+	signingSecret   string
+
+	catchUp    *catchUpState
+	deliveries *deliveryCache
 }
 
 // NewWebhookEventProcessor constructs a WebhookEventProcessor from the provided
 // websocket delivery function, route table, and config.
 func NewWebhookEventProcessor(sendMessage func(*websocket.OutgoingMessage), routes []EndpointRoute, cfg *WebhookEventProcessorConfig) *WebhookEventProcessor {
 	p := &WebhookEventProcessor{
-		cfg:         cfg,
-		events:      convertToMap(cfg.Events),
-		sendMessage: sendMessage,
-		thinEvents:  convertToMap(cfg.ThinEvents),
+		cfg:           cfg,
+		events:        matcher.New(cfg.Events),
+		sendMessage:   sendMessage,
+		thinEvents:    matcher.New(cfg.ThinEvents),
+		signingSecret: cfg.SigningSecret,
+	}
+
+	if cfg.CatchUp {
+		p.catchUp = newCatchUpState()
+	}
+
+	if cfg.Dedupe {
+		p.deliveries = newDeliveryCache(cfg.DedupeSize)
+	}
+
+	var signingSecret func() string
+	if cfg.ResignEvents || len(cfg.Transforms) > 0 {
+		signingSecret = p.getSigningSecret
 	}
 
 	for _, route := range routes {
+		timeout := cfg.Timeout
+		if route.Timeout > 0 {
+			timeout = route.Timeout
+		} else if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+
+		transport := newEndpointTransport(route.URL, cfg.SkipVerify)
+		if route.Responder != nil {
+			// the responder's delays are meant to outlast the forward timeout
+			timeout = 0
+			transport = route.Responder
+		}
+
 		// append to endpointClients
 		p.endpointClients = append(p.endpointClients, NewEndpointClient(
 			route.URL,
@@ -75,14 +157,19 @@ func NewWebhookEventProcessor(sendMessage func(*websocket.OutgoingMessage), rout
 					CheckRedirect: func(req *http.Request, via []*http.Request) error {
 						return http.ErrUseLastResponse
 					},
-					Timeout: time.Duration(cfg.Timeout) * time.Second,
-					Transport: &http.Transport{
-						TLSClientConfig: &tls.Config{InsecureSkipVerify: cfg.SkipVerify},
-					},
+					Timeout:   timeout,
+					Transport: transport,
 				},
 				Log:             cfg.Log,
 				ResponseHandler: EndpointResponseHandlerFunc(p.processEndpointResponse),
+				FailureHandler:  p.processEndpointFailure,
 				OutCh:           cfg.OutCh,
+				RetryPolicy:     cfg.RetryPolicy,
+				Concurrency:     cfg.DeliveryConcurrency,
+				QueueSize:       cfg.DeliveryQueueSize,
+				SigningSecret:   signingSecret,
+				Metrics:         cfg.Metrics,
+				Transforms:      cfg.Transforms,
 			},
 		))
 	}
@@ -90,6 +177,34 @@ func NewWebhookEventProcessor(sendMessage func(*websocket.OutgoingMessage), rout
 	return p
 }
 
+// SetSigningSecret sets the secret used to re-sign forwarded events, unless a
+// secret was provided in the processor's config.
+func (p *WebhookEventProcessor) SetSigningSecret(secret string) {
+	if p.cfg.SigningSecret != "" {
+		return
+	}
+
+	p.signingSecretMu.Lock()
+	defer p.signingSecretMu.Unlock()
+
+	p.signingSecret = secret
+}
+
+// Close waits for the events enqueued for delivery to the processor's
+// endpoints to be delivered, without retrying failed forwards.
+func (p *WebhookEventProcessor) Close() {
+	for _, endpoint := range p.endpointClients {
+		endpoint.Close()
+	}
+}
+
+func (p *WebhookEventProcessor) getSigningSecret() string {
+	p.signingSecretMu.RLock()
+	defer p.signingSecretMu.RUnlock()
+
+	return p.signingSecret
+}
+
 // ProcessEvent processes webhook events, notifying listeners via the configured
 // OutCh, sending acknowledgements with the configured websocket sender, and
 // forwarding events to configured endpoints.
@@ -150,29 +265,86 @@ func (p *WebhookEventProcessor) processEvent(webhookEvent *websocket.WebhookEven
 		return
 	}
 
-	evtCtx := eventContext{
+	p.cfg.Metrics.eventReceived(evt.Type)
+	p.catchUp.markDelivered(evt.ID, int64(evt.Created))
+
+	p.deliverEvent(&evt, eventContext{
 		webhookID:             webhookEvent.WebhookID,
 		webhookConversationID: webhookEvent.WebhookConversationID,
 		event:                 &evt,
 		requestBody:           webhookEvent.EventPayload,
 		requestHeaders:        webhookEvent.HTTPHeaders,
+	})
+}
+
+// deliverEvent journals a snapshot event, notifies consumers and forwards it
+// to the endpoints supporting its type.
+func (p *WebhookEventProcessor) deliverEvent(evt *StripeEvent, evtCtx eventContext) {
+	p.writeJournal(JournalEntry{
+		Kind:                  JournalEntryEvent,
+		EventID:               evt.ID,
+		EventType:             evt.Type,
+		WebhookID:             evtCtx.webhookID,
+		WebhookConversationID: evtCtx.webhookConversationID,
+		Payload:               evtCtx.requestBody,
+		HTTPHeaders:           evtCtx.requestHeaders,
+	})
+
+	if !p.events.Match(evt.Type) || !p.matchFilters(evt.ID, evtCtx.requestBody) {
+		return
+	}
+
+	var endpoints []*EndpointClient
+	for _, endpoint := range p.endpointClients {
+		if endpoint.SupportsEventType(evt.IsConnect(), evt.Type) && !endpoint.isEventDestination {
+			endpoints = append(endpoints, endpoint)
+		}
 	}
 
-	if p.events["*"] || p.events[evt.Type] {
+	if p.deliveries.seen(evt.ID, endpointURLs(endpoints)) {
 		p.cfg.OutCh <- websocket.DataElement{
-			Data:      evt,
-			Marshaled: formatOutput(outputFormatJSON, webhookEvent.EventPayload),
+			Data: DuplicateSuppressed{Event: evt},
 		}
+		p.answerDuplicate(endpoints, evtCtx)
+		return
+	}
 
-		for _, endpoint := range p.endpointClients {
-			if endpoint.SupportsEventType(evt.IsConnect(), evt.Type) && !endpoint.isEventDestination {
-				// TODO: handle errors returned by endpointClients
-				go endpoint.Post(evtCtx)
-			}
+	p.cfg.OutCh <- websocket.DataElement{
+		Data:      *evt,
+		Marshaled: formatOutput(outputFormatJSON, evtCtx.requestBody),
+	}
+
+	for _, endpoint := range endpoints {
+		endpoint.Enqueue(evtCtx)
+	}
+}
+
+// AllowRedelivery forgets that an event was forwarded, so that it's forwarded
+// again the next time Stripe delivers it rather than suppressed as a
+// duplicate.
+func (p *WebhookEventProcessor) AllowRedelivery(eventID string) {
+	p.deliveries.forget(eventID)
+}
+
+// answerDuplicate answers a suppressed delivery with the responses of the
+// endpoints to the first delivery of its event, or once they respond.
+func (p *WebhookEventProcessor) answerDuplicate(endpoints []*EndpointClient, evtCtx eventContext) {
+	for _, endpoint := range endpoints {
+		if response := p.deliveries.suppress(endpoint.URL, evtCtx); response != nil {
+			p.sendWebhookResponse(evtCtx, endpoint.URL, *response)
 		}
 	}
 }
 
+func endpointURLs(endpoints []*EndpointClient) []string {
+	urls := make([]string, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		urls = append(urls, endpoint.URL)
+	}
+
+	return urls
+}
+
 func (p *WebhookEventProcessor) processV2Event(v2Event *websocket.StripeV2Event) {
 	var evt V2EventPayload
 
@@ -191,14 +363,28 @@ func (p *WebhookEventProcessor) processV2Event(v2Event *websocket.StripeV2Event)
 	// ack the event
 	p.sendMessage(websocket.NewEventAck(evt.ID, "", v2Event.EventDestinationID))
 
+	p.cfg.Metrics.eventReceived(evt.Type)
+
+	p.writeJournal(JournalEntry{
+		Kind:        JournalEntryEvent,
+		EventID:     evt.ID,
+		EventType:   evt.Type,
+		Thin:        true,
+		WebhookID:   v2Event.EventDestinationID,
+		Payload:     v2Event.Payload,
+		HTTPHeaders: v2Event.HTTPHeaders,
+	})
+
 	// skip further event processing if the event type is not enabled
-	if !p.thinEvents[evt.Type] && !p.thinEvents["*"] {
+	if !p.thinEvents.Match(evt.Type) || !p.matchFilters(evt.ID, v2Event.Payload) {
 		return
 	}
 
-	// notify consumers
-	p.cfg.OutCh <- websocket.DataElement{
-		Data: evt,
+	var endpoints []*EndpointClient
+	for _, endpoint := range p.endpointClients {
+		if endpoint.isEventDestination && endpoint.SupportsContext(evt.Context) {
+			endpoints = append(endpoints, endpoint)
+		}
 	}
 
 	evtCtx := eventContext{
@@ -209,13 +395,125 @@ func (p *WebhookEventProcessor) processV2Event(v2Event *websocket.StripeV2Event)
 		requestHeaders:        v2Event.HTTPHeaders,
 	}
 
+	if p.deliveries.seen(evt.ID, endpointURLs(endpoints)) {
+		p.cfg.OutCh <- websocket.DataElement{
+			Data: DuplicateSuppressed{V2Event: &evt},
+		}
+		p.answerDuplicate(endpoints, evtCtx)
+		return
+	}
+
+	// notify consumers
+	p.cfg.OutCh <- websocket.DataElement{
+		Data:      evt,
+		Marshaled: formatOutput(outputFormatJSON, v2Event.Payload),
+	}
+
+	for _, endpoint := range endpoints {
+		endpoint.Enqueue(evtCtx)
+	}
+}
+
+// Replay forwards a journaled event to the configured endpoints. Unlike
+// ProcessEvent, nothing is acknowledged to Stripe and the event is posted
+// synchronously so that events are replayed in journal order.
+func (p *WebhookEventProcessor) Replay(entry JournalEntry) {
+	if entry.Thin {
+		var evt V2EventPayload
+		if err := json.Unmarshal([]byte(entry.Payload), &evt); err != nil {
+			p.cfg.Log.Debugf("Skipping malformed journal entry for %s", entry.EventID)
+			return
+		}
+
+		if !p.thinEvents.Match(evt.Type) || !p.matchFilters(evt.ID, entry.Payload) {
+			return
+		}
+
+		p.cfg.OutCh <- websocket.DataElement{
+			Data:      evt,
+			Marshaled: formatOutput(outputFormatJSON, entry.Payload),
+		}
+
+		evtCtx := eventContext{
+			webhookID:      entry.WebhookID,
+			v2Event:        &evt,
+			requestBody:    entry.Payload,
+			requestHeaders: entry.HTTPHeaders,
+		}
+
+		for _, endpoint := range p.endpointClients {
+			if endpoint.isEventDestination && endpoint.SupportsContext(evt.Context) {
+				endpoint.PostV2(evtCtx)
+			}
+		}
+
+		return
+	}
+
+	var evt StripeEvent
+	if err := json.Unmarshal([]byte(entry.Payload), &evt); err != nil {
+		p.cfg.Log.Debugf("Skipping malformed journal entry for %s", entry.EventID)
+		return
+	}
+
+	req, err := ExtractRequestData(evt.RequestData)
+	if err != nil {
+		p.cfg.Log.Debugf("Skipping malformed journal entry for %s", entry.EventID)
+		return
+	}
+
+	evt.Request = req
+	evt.LoggedInAccountID = p.cfg.LoggedInAccountID
+
+	if !p.events.Match(evt.Type) || !p.matchFilters(evt.ID, entry.Payload) {
+		return
+	}
+
+	p.cfg.OutCh <- websocket.DataElement{
+		Data:      evt,
+		Marshaled: formatOutput(outputFormatJSON, entry.Payload),
+	}
+
+	evtCtx := eventContext{
+		webhookID:             entry.WebhookID,
+		webhookConversationID: entry.WebhookConversationID,
+		event:                 &evt,
+		requestBody:           entry.Payload,
+		requestHeaders:        entry.HTTPHeaders,
+	}
+
 	for _, endpoint := range p.endpointClients {
-		if endpoint.isEventDestination && endpoint.SupportsContext(evt.Context) {
-			go endpoint.PostV2(evtCtx)
+		if endpoint.SupportsEventType(evt.IsConnect(), evt.Type) && !endpoint.isEventDestination {
+			endpoint.Post(evtCtx)
 		}
 	}
 }
 
+func (p *WebhookEventProcessor) matchFilters(eventID string, payload string) bool {
+	if matchPayloadFilters(p.cfg.Filters, payload) {
+		return true
+	}
+
+	p.cfg.Log.WithFields(log.Fields{
+		"prefix":   "proxy.WebhookEventProcessor.matchFilters",
+		"event_id": eventID,
+	}).Debug("Event doesn't match the payload filters, ignoring")
+
+	return false
+}
+
+func (p *WebhookEventProcessor) writeJournal(entry JournalEntry) {
+	if p.cfg.Journal == nil {
+		return
+	}
+
+	if err := p.cfg.Journal.Write(entry); err != nil {
+		p.cfg.Log.WithFields(log.Fields{
+			"prefix": "proxy.WebhookEventProcessor.writeJournal",
+		}).Warnf("Failed to write to event journal: %v", err)
+	}
+}
+
 func (p *WebhookEventProcessor) filterWebhookEvent(msg *websocket.WebhookEvent) bool {
 	if msg.Endpoint.APIVersion != nil && !p.cfg.UseLatestAPIVersion {
 		p.cfg.Log.WithFields(log.Fields{
@@ -246,22 +544,39 @@ func (p *WebhookEventProcessor) processEndpointResponse(evtCtx eventContext, for
 		return
 	}
 
+	latency := time.Since(evtCtx.sentAt)
 	body := truncate(string(buf), maxBodySize, true)
-	var eventID string
+	var eventID, eventType string
 	if evtCtx.event != nil {
 		eventID = evtCtx.event.ID
+		eventType = evtCtx.event.Type
 		p.cfg.OutCh <- websocket.DataElement{
 			Data: EndpointResponse{
-				Event: evtCtx.event,
-				Resp:  resp,
+				Event:          evtCtx.event,
+				Resp:           resp,
+				Attempt:        evtCtx.attempt,
+				RequestBody:    evtCtx.requestBody,
+				ForwardedBody:  evtCtx.forwardedBody,
+				RequestHeaders: evtCtx.requestHeaders,
+				ResponseBody:   body,
+				Latency:        latency,
+				Timing:         evtCtx.trace.timing(latency),
 			},
 		}
 	} else if evtCtx.v2Event != nil {
 		eventID = evtCtx.v2Event.ID
+		eventType = evtCtx.v2Event.Type
 		p.cfg.OutCh <- websocket.DataElement{
 			Data: EndpointResponse{
-				V2Event: evtCtx.v2Event,
-				Resp:    resp,
+				V2Event:        evtCtx.v2Event,
+				Resp:           resp,
+				Attempt:        evtCtx.attempt,
+				RequestBody:    evtCtx.requestBody,
+				ForwardedBody:  evtCtx.forwardedBody,
+				RequestHeaders: evtCtx.requestHeaders,
+				ResponseBody:   body,
+				Latency:        latency,
+				Timing:         evtCtx.trace.timing(latency),
 			},
 		}
 	}
@@ -278,16 +593,91 @@ func (p *WebhookEventProcessor) processEndpointResponse(evtCtx eventContext, for
 		}
 	}
 
-	msg := websocket.NewWebhookResponse(
+	p.writeJournal(JournalEntry{
+		Kind:                  JournalEntryResponse,
+		EventID:               eventID,
+		EventType:             eventType,
+		Thin:                  evtCtx.v2Event != nil,
+		WebhookID:             evtCtx.webhookID,
+		WebhookConversationID: evtCtx.webhookConversationID,
+		ForwardURL:            forwardURL,
+		Status:                resp.StatusCode,
+		ResponseBody:          body,
+		Attempt:               evtCtx.attempt,
+	})
+
+	// Stripe only gets the response of the last attempt when the forward is
+	// retried
+	if !evtCtx.lastAttempt {
+		return
+	}
+
+	response := deliveryResponse{
+		status:  resp.StatusCode,
+		body:    body,
+		headers: headers,
+	}
+
+	// duplicate deliveries that were suppressed before the endpoint responded
+	// are answered with the same response
+	for _, duplicate := range p.deliveries.responded(forwardURL, eventID, response) {
+		p.sendWebhookResponse(duplicate, forwardURL, response)
+	}
+
+	p.sendWebhookResponse(evtCtx, forwardURL, response)
+}
+
+// processEndpointFailure forwards the duplicate deliveries of an event that
+// were waiting for the response of an endpoint that never responded. The
+// first one is forwarded, and the others wait for its response.
+func (p *WebhookEventProcessor) processEndpointFailure(evtCtx eventContext, forwardURL string, _ error) {
+	duplicates := p.deliveries.failed(forwardURL, eventContextID(evtCtx))
+	if len(duplicates) == 0 {
+		return
+	}
+
+	var endpoint *EndpointClient
+	for _, client := range p.endpointClients {
+		if client.URL == forwardURL && client.isEventDestination == (evtCtx.v2Event != nil) {
+			endpoint = client
+			break
+		}
+	}
+
+	if endpoint == nil {
+		return
+	}
+
+	for _, duplicate := range duplicates {
+		if p.deliveries.seen(eventContextID(duplicate), []string{forwardURL}) {
+			p.deliveries.suppress(forwardURL, duplicate)
+			continue
+		}
+
+		// the failure is handled while delivering, so the duplicate is
+		// enqueued separately to not wait on a full delivery queue
+		go endpoint.Enqueue(duplicate)
+	}
+}
+
+// sendWebhookResponse reports the response of an endpoint to a delivery to
+// Stripe.
+func (p *WebhookEventProcessor) sendWebhookResponse(evtCtx eventContext, forwardURL string, response deliveryResponse) {
+	// events caught up from the API weren't delivered over the websocket, so
+	// there's no delivery to report the response of
+	if evtCtx.webhookID == "" {
+		return
+	}
+
+	p.sendMessage(websocket.NewWebhookResponse(
 		evtCtx.webhookID,
 		evtCtx.webhookConversationID,
 		forwardURL,
-		resp.StatusCode,
-		body,
-		headers,
+		response.status,
+		response.body,
+		response.headers,
 		evtCtx.requestBody,
 		evtCtx.requestHeaders,
-		eventID,
-	)
-	p.sendMessage(msg)
+		eventContextID(evtCtx),
+	))
 }
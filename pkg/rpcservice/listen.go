@@ -138,6 +138,15 @@ func createProxyVisitor(stream *rpc.StripeCLI_ListenServer) *websocket.Visitor {
 				}
 				(*stream).Send(resp)
 				return nil
+			case proxy.EndpointRetry:
+				// Retries are followed by their own endpoint response or error
+				return nil
+			case proxy.EndpointAttempt:
+				// Attempts are followed by their own endpoint response or error
+				return nil
+			case proxy.DuplicateSuppressed:
+				// Duplicates aren't forwarded, so there's nothing to stream
+				return nil
 			default:
 				return errorcategory.Errorf(errorcategory.Internal, "VisitData received unexpected type for DataElement, got %T", de)
 			}
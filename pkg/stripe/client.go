@@ -10,6 +10,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -132,8 +133,9 @@ type Client struct {
 	NoFollowRedirects bool
 
 	// Cached HTTP client, lazily created the first time the Client is used to
-	// send a request.
-	httpClient *http.Client
+	// send a request. Requests can be sent concurrently.
+	httpClientOnce sync.Once
+	httpClient     *http.Client
 }
 
 // RequestPerformer is an interface for executing requests against the Stripe
@@ -180,14 +182,14 @@ func (c *Client) PerformRequest(ctx context.Context, method, path string, params
 		}
 	}
 
-	if c.httpClient == nil {
+	c.httpClientOnce.Do(func() {
 		c.httpClient = newHTTPClient(c.Verbose, c.VerbosePrintableHeaders, unixSocketPath)
 		if c.NoFollowRedirects {
 			c.httpClient.CheckRedirect = func(*http.Request, []*http.Request) error {
 				return http.ErrUseLastResponse
 			}
 		}
-	}
+	})
 
 	if ctx != nil {
 		req = req.WithContext(ctx)
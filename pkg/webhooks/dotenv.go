@@ -0,0 +1,88 @@
+package webhooks
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/afero"
+
+	"github.com/stripe/stripe-cli/pkg/errorcategory"
+)
+
+// DefaultSecretEnvVar is the variable the signing secret is written to in
+// dotenv files, matching the one used by Stripe samples
+const DefaultSecretEnvVar = "STRIPE_WEBHOOK_SECRET"
+
+var envVarNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateEnvVarName returns an error if name can't be used as a variable in
+// a dotenv file.
+func ValidateEnvVarName(name string) error {
+	if !envVarNameRegexp.MatchString(name) {
+		return errorcategory.Errorf(errorcategory.UserInput, "%q is not a valid environment variable name", name)
+	}
+
+	return nil
+}
+
+// WriteSecretToDotEnv sets key to secret in the dotenv file at path. Only the
+// lines setting key are replaced, or a line is appended when there's none, so
+// the rest of the file is kept as is. The file is created if it doesn't
+// exist, and left untouched if it already holds the secret.
+func WriteSecretToDotEnv(fs afero.Fs, path, key, secret string) error {
+	content, err := afero.ReadFile(fs, path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err == nil {
+		dotenv, err := godotenv.Parse(bytes.NewReader(content))
+		if err != nil {
+			return errorcategory.Errorf(errorcategory.UserInput, "failed to parse %s: %v", path, err)
+		}
+
+		if current, ok := dotenv[key]; ok && current == secret {
+			return nil
+		}
+	}
+
+	assignment := key + "=" + strconv.Quote(secret)
+
+	// the variable may be set more than once, in which case the last line
+	// wins, so every line setting it is replaced
+	keyRegexp := regexp.MustCompile(`^(\s*(?:export\s+)?)` + regexp.QuoteMeta(key) + `\s*=`)
+
+	lines := strings.Split(string(content), "\n")
+	replaced := false
+
+	for i, line := range lines {
+		match := keyRegexp.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		ending := ""
+		if strings.HasSuffix(line, "\r") {
+			ending = "\r"
+		}
+
+		lines[i] = match[1] + assignment + ending
+		replaced = true
+	}
+
+	updated := strings.Join(lines, "\n")
+	if !replaced {
+		if updated != "" && !strings.HasSuffix(updated, "\n") {
+			updated += "\n"
+		}
+
+		updated += assignment + "\n"
+	}
+
+	// the file holds a secret, so it's only readable by the user when created
+	return afero.WriteFile(fs, path, []byte(updated), 0o600)
+}
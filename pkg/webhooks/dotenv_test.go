@@ -0,0 +1,73 @@
+package webhooks
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteSecretToDotEnv_CreatesFile(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	err := WriteSecretToDotEnv(fs, ".env.local", DefaultSecretEnvVar, "whsec_123")
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(fs, ".env.local")
+	require.NoError(t, err)
+	require.Equal(t, "STRIPE_WEBHOOK_SECRET=\"whsec_123\"\n", string(content))
+}
+
+func TestWriteSecretToDotEnv_UpdatesExistingFile(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, ".env", []byte("PORT=4242\nSTRIPE_WEBHOOK_SECRET=whsec_old\n"), 0o644))
+
+	err := WriteSecretToDotEnv(fs, ".env", DefaultSecretEnvVar, "whsec_new")
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(fs, ".env")
+	require.NoError(t, err)
+	require.Equal(t, "PORT=4242\nSTRIPE_WEBHOOK_SECRET=\"whsec_new\"\n", string(content))
+}
+
+func TestWriteSecretToDotEnv_UnchangedSecret(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	original := "# keep me\nSTRIPE_WEBHOOK_SECRET=whsec_123\n"
+	require.NoError(t, afero.WriteFile(fs, ".env", []byte(original), 0o644))
+
+	err := WriteSecretToDotEnv(fs, ".env", DefaultSecretEnvVar, "whsec_123")
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(fs, ".env")
+	require.NoError(t, err)
+	require.Equal(t, original, string(content))
+}
+
+func TestValidateEnvVarName(t *testing.T) {
+	require.NoError(t, ValidateEnvVarName("STRIPE_WEBHOOK_SECRET"))
+	require.NoError(t, ValidateEnvVarName("_secret2"))
+	require.Error(t, ValidateEnvVarName("2FAST"))
+	require.Error(t, ValidateEnvVarName("MY-SECRET"))
+	require.Error(t, ValidateEnvVarName(""))
+}
+
+func TestWriteSecretToDotEnv_KeepsOtherLines(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	original := "# Stripe\n\nexport STRIPE_WEBHOOK_SECRET='whsec_old' # from stripe listen\nAPI_URL=\"${HOST}/api\"\nZED=1\nALPHA='quoted'"
+	require.NoError(t, afero.WriteFile(fs, ".env", []byte(original), 0o644))
+
+	err := WriteSecretToDotEnv(fs, ".env", DefaultSecretEnvVar, "whsec_new")
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(fs, ".env")
+	require.NoError(t, err)
+	require.Equal(t, "# Stripe\n\nexport STRIPE_WEBHOOK_SECRET=\"whsec_new\"\nAPI_URL=\"${HOST}/api\"\nZED=1\nALPHA='quoted'", string(content))
+
+	// a missing variable is appended
+	err = WriteSecretToDotEnv(fs, ".env", "OTHER_SECRET", "whsec_other")
+	require.NoError(t, err)
+
+	content, err = afero.ReadFile(fs, ".env")
+	require.NoError(t, err)
+	require.Equal(t, "# Stripe\n\nexport STRIPE_WEBHOOK_SECRET=\"whsec_new\"\nAPI_URL=\"${HOST}/api\"\nZED=1\nALPHA='quoted'\nOTHER_SECRET=\"whsec_other\"\n", string(content))
+}
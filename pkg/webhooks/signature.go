@@ -0,0 +1,35 @@
+// Package webhooks computes and verifies the Stripe-Signature header sent with
+// webhook events.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// SignatureHeader is the name of the header carrying the webhook signature
+const SignatureHeader = "Stripe-Signature"
+
+// SigningScheme is the signature scheme used by Stripe
+const SigningScheme = "v1"
+
+// ComputeSignature computes the v1 signature of a payload sent at the given
+// time, the way Stripe does: an HMAC-SHA256 of "<timestamp>.<payload>" keyed
+// with the endpoint's signing secret.
+func ComputeSignature(timestamp time.Time, payload []byte, secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%d", timestamp.Unix())))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+
+	return mac.Sum(nil)
+}
+
+// GenerateSignatureHeader returns the value of the Stripe-Signature header for
+// a payload sent at the given time.
+func GenerateSignatureHeader(timestamp time.Time, payload []byte, secret string) string {
+	return fmt.Sprintf("t=%d,%s=%s", timestamp.Unix(), SigningScheme, hex.EncodeToString(ComputeSignature(timestamp, payload, secret)))
+}
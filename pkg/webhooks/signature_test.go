@@ -0,0 +1,26 @@
+package webhooks
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeSignature(t *testing.T) {
+	timestamp := time.Unix(1700000000, 0)
+	payload := []byte(`{"id":"evt_123"}`)
+
+	// Computed with: printf '1700000000.{"id":"evt_123"}' | openssl dgst -sha256 -hmac whsec_test
+	require.Equal(t, "5a607119c8de704a1cff10c500951d1446f61db411d9ced87bfd2e4ae2d167ba", hex.EncodeToString(ComputeSignature(timestamp, payload, "whsec_test")))
+}
+
+func TestGenerateSignatureHeader(t *testing.T) {
+	timestamp := time.Unix(1700000000, 0)
+	payload := []byte(`{"id":"evt_123"}`)
+
+	header := GenerateSignatureHeader(timestamp, payload, "whsec_test")
+	require.Equal(t, "t=1700000000,v1="+hex.EncodeToString(ComputeSignature(timestamp, payload, "whsec_test")), header)
+	require.NotEqual(t, header, GenerateSignatureHeader(timestamp, payload, "whsec_other"))
+}
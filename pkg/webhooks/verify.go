@@ -0,0 +1,179 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTolerance is the maximum age of a signature accepted by Stripe's libraries
+const DefaultTolerance = 300 * time.Second
+
+var (
+	// ErrInvalidHeader is returned when the header can't be parsed
+	ErrInvalidHeader = errors.New("the Stripe-Signature header is not in the t=<timestamp>,v1=<signature> format")
+
+	// ErrNoSignatures is returned when the header doesn't contain any v1 signature
+	ErrNoSignatures = errors.New("the Stripe-Signature header does not contain any v1 signature")
+
+	// ErrNoValidSignature is returned when none of the v1 signatures match the payload
+	ErrNoValidSignature = errors.New("no signature matches the payload and secret")
+
+	// ErrTooOld is returned when the signature timestamp is outside of the tolerance
+	ErrTooOld = errors.New("the signature timestamp is outside of the tolerance window")
+)
+
+// Verification describes the outcome of verifying a Stripe-Signature header.
+type Verification struct {
+	// Timestamp is the time the payload was signed at
+	Timestamp time.Time
+
+	// Skew is how long ago the payload was signed
+	Skew time.Duration
+
+	// Schemes lists the signature schemes found in the header
+	Schemes []string
+
+	// MatchedScheme is the scheme of the signature that matched, if any
+	MatchedScheme string
+
+	// Hint explains a likely cause of a failed verification, if one was found
+	Hint string
+}
+
+// VerifySignatureHeader checks that header is a valid signature of payload for
+// the given secret. Signatures older than tolerance are rejected, unless
+// tolerance is 0. The returned Verification is filled in as much as the header
+// allows, even when verification fails.
+func VerifySignatureHeader(payload []byte, header string, secret string, tolerance time.Duration, now time.Time) (Verification, error) {
+	var verification Verification
+
+	timestamp, signatures, schemes, err := parseSignatureHeader(header)
+	verification.Schemes = schemes
+	if err != nil {
+		return verification, err
+	}
+
+	verification.Timestamp = timestamp
+	verification.Skew = now.Sub(timestamp)
+
+	if len(signatures) == 0 {
+		return verification, ErrNoSignatures
+	}
+
+	if !matchesAny(signatures, ComputeSignature(timestamp, payload, secret)) {
+		verification.Hint = diagnoseMismatch(payload, timestamp, signatures, secret)
+		return verification, ErrNoValidSignature
+	}
+
+	verification.MatchedScheme = SigningScheme
+
+	if tolerance > 0 && (verification.Skew > tolerance || verification.Skew < -tolerance) {
+		return verification, ErrTooOld
+	}
+
+	return verification, nil
+}
+
+func parseSignatureHeader(header string) (time.Time, [][]byte, []string, error) {
+	var timestamp time.Time
+	var signatures [][]byte
+	var schemes []string
+
+	if header == "" {
+		return timestamp, nil, nil, ErrInvalidHeader
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 {
+			return timestamp, nil, schemes, ErrInvalidHeader
+		}
+
+		key, value := parts[0], parts[1]
+
+		switch key {
+		case "t":
+			unix, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return timestamp, nil, schemes, ErrInvalidHeader
+			}
+
+			timestamp = time.Unix(unix, 0)
+		case SigningScheme:
+			schemes = appendScheme(schemes, key)
+
+			signature, err := hex.DecodeString(value)
+			if err != nil {
+				continue
+			}
+
+			signatures = append(signatures, signature)
+		default:
+			schemes = appendScheme(schemes, key)
+		}
+	}
+
+	if timestamp.IsZero() {
+		return timestamp, nil, schemes, ErrInvalidHeader
+	}
+
+	return timestamp, signatures, schemes, nil
+}
+
+func appendScheme(schemes []string, scheme string) []string {
+	for _, s := range schemes {
+		if s == scheme {
+			return schemes
+		}
+	}
+
+	return append(schemes, scheme)
+}
+
+func matchesAny(signatures [][]byte, expected []byte) bool {
+	for _, signature := range signatures {
+		if hmac.Equal(signature, expected) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// diagnoseMismatch looks for common ways a payload is modified between Stripe
+// and the code verifying it.
+func diagnoseMismatch(payload []byte, timestamp time.Time, signatures [][]byte, secret string) string {
+	matches := func(candidate []byte) bool {
+		return matchesAny(signatures, ComputeSignature(timestamp, candidate, secret))
+	}
+
+	trimmed := bytes.TrimSpace(payload)
+	if !bytes.Equal(trimmed, payload) && matches(trimmed) {
+		return "the signature matches the payload without its leading or trailing whitespace, check that nothing appends a newline to the body"
+	}
+
+	if matches(append(append([]byte{}, payload...), '\n')) {
+		return "the signature matches the payload with a trailing newline, check that nothing strips it from the body"
+	}
+
+	var compacted, indented bytes.Buffer
+	if json.Compact(&compacted, payload) == nil && json.Indent(&indented, payload, "", "  ") == nil {
+		for _, candidate := range [][]byte{compacted.Bytes(), indented.Bytes()} {
+			if !bytes.Equal(candidate, payload) && matches(candidate) {
+				return "the signature matches the payload with a different formatting, the body was likely parsed and re-serialized before verification; verify the raw request body instead"
+			}
+		}
+	}
+
+	if !strings.HasPrefix(secret, "whsec_") {
+		return "the secret doesn't start with whsec_, make sure you are using the endpoint's signing secret and not an API key"
+	}
+
+	return "the payload was modified after it was signed, or it was signed with a different secret"
+}
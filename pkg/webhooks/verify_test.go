@@ -0,0 +1,70 @@
+package webhooks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const verifyTestPayload = "{\n  \"id\": \"evt_123\",\n  \"object\": \"event\"\n}"
+
+func TestVerifySignatureHeader(t *testing.T) {
+	signedAt := time.Unix(1700000000, 0)
+	header := GenerateSignatureHeader(signedAt, []byte(verifyTestPayload), "whsec_test")
+
+	verification, err := VerifySignatureHeader([]byte(verifyTestPayload), header, "whsec_test", DefaultTolerance, signedAt.Add(10*time.Second))
+	require.NoError(t, err)
+	require.Equal(t, "v1", verification.MatchedScheme)
+	require.Equal(t, []string{"v1"}, verification.Schemes)
+	require.Equal(t, 10*time.Second, verification.Skew)
+	require.Equal(t, signedAt, verification.Timestamp)
+}
+
+func TestVerifySignatureHeader_MultipleSignatures(t *testing.T) {
+	signedAt := time.Unix(1700000000, 0)
+	header := GenerateSignatureHeader(signedAt, []byte(verifyTestPayload), "whsec_test") + ",v1=deadbeef,v0=abcdef"
+
+	verification, err := VerifySignatureHeader([]byte(verifyTestPayload), header, "whsec_test", 0, signedAt)
+	require.NoError(t, err)
+	require.Equal(t, []string{"v1", "v0"}, verification.Schemes)
+}
+
+func TestVerifySignatureHeader_Errors(t *testing.T) {
+	signedAt := time.Unix(1700000000, 0)
+	header := GenerateSignatureHeader(signedAt, []byte(verifyTestPayload), "whsec_test")
+
+	_, err := VerifySignatureHeader([]byte(verifyTestPayload), "", "whsec_test", 0, signedAt)
+	require.ErrorIs(t, err, ErrInvalidHeader)
+
+	_, err = VerifySignatureHeader([]byte(verifyTestPayload), "v1=abc", "whsec_test", 0, signedAt)
+	require.ErrorIs(t, err, ErrInvalidHeader)
+
+	_, err = VerifySignatureHeader([]byte(verifyTestPayload), "t=1700000000,v0=abc", "whsec_test", 0, signedAt)
+	require.ErrorIs(t, err, ErrNoSignatures)
+
+	verification, err := VerifySignatureHeader([]byte(verifyTestPayload), header, "whsec_test", DefaultTolerance, signedAt.Add(time.Hour))
+	require.ErrorIs(t, err, ErrTooOld)
+	require.Equal(t, "v1", verification.MatchedScheme)
+
+	verification, err = VerifySignatureHeader([]byte(verifyTestPayload), header, "whsec_other", 0, signedAt)
+	require.ErrorIs(t, err, ErrNoValidSignature)
+	require.Contains(t, verification.Hint, "different secret")
+
+	verification, err = VerifySignatureHeader([]byte(verifyTestPayload), header, "sk_test_123", 0, signedAt)
+	require.ErrorIs(t, err, ErrNoValidSignature)
+	require.Contains(t, verification.Hint, "whsec_")
+}
+
+func TestVerifySignatureHeader_Hints(t *testing.T) {
+	signedAt := time.Unix(1700000000, 0)
+	header := GenerateSignatureHeader(signedAt, []byte(verifyTestPayload), "whsec_test")
+
+	verification, err := VerifySignatureHeader([]byte(verifyTestPayload+"\n"), header, "whsec_test", 0, signedAt)
+	require.ErrorIs(t, err, ErrNoValidSignature)
+	require.Contains(t, verification.Hint, "whitespace")
+
+	verification, err = VerifySignatureHeader([]byte(`{"id":"evt_123","object":"event"}`), header, "whsec_test", 0, signedAt)
+	require.ErrorIs(t, err, ErrNoValidSignature)
+	require.Contains(t, verification.Hint, "re-serialized")
+}
@@ -51,6 +51,20 @@ type Config struct {
 	WriteWait time.Duration
 
 	EventHandler EventHandler
+
+	// ProcessEventsInOrder hands incoming messages to the EventHandler one at
+	// a time, in the order they were received, instead of concurrently. A
+	// slow EventHandler delays reading further messages.
+	ProcessEventsInOrder bool
+
+	// OnReconnect, if set, is called each time the client drops its
+	// connection to reconnect, with "disconnected" when Stripe closed the
+	// connection or "reset" when ReconnectInterval elapsed.
+	OnReconnect func(reason string)
+
+	// OnConnect, if set, is called each time the connection to Stripe is
+	// established, including after reconnecting
+	OnConnect func()
 }
 
 // EventHandler handles an event.
@@ -110,6 +124,12 @@ func (c *Client) getIsConnected() bool {
 	return c.isConnected
 }
 
+// IsConnected returns whether the client's websocket connection is currently
+// established.
+func (c *Client) IsConnected() bool {
+	return c.getIsConnected()
+}
+
 // Connected returns a channel that's closed when the client has finished
 // establishing the websocket connection.
 func (c *Client) Connected() <-chan struct{} {
@@ -161,6 +181,10 @@ func (c *Client) Run(ctx context.Context) {
 			err = c.connect(ctx)
 		}
 
+		if c.cfg.OnConnect != nil {
+			c.cfg.OnConnect()
+		}
+
 		select {
 		case <-ctx.Done():
 			close(c.send)
@@ -177,6 +201,7 @@ func (c *Client) Run(ctx context.Context) {
 			}).Debug("Disconnected from Stripe")
 			c.Close(ws.CloseGoingAway, "Server closed the connection")
 			c.wg.Wait()
+			c.notifyReconnect("disconnected")
 		case <-time.After(c.cfg.ReconnectInterval):
 			c.cfg.Log.WithFields(log.Fields{
 				"prefix": "websocket.Client.Run",
@@ -191,10 +216,17 @@ func (c *Client) Run(ctx context.Context) {
 			c.cfg.Log.WithFields(log.Fields{
 				"prefix": "websocket.Client.Run",
 			}).Debug("Client wg is done")
+			c.notifyReconnect("reset")
 		}
 	}
 }
 
+func (c *Client) notifyReconnect(reason string) {
+	if c.cfg.OnReconnect != nil {
+		c.cfg.OnReconnect(reason)
+	}
+}
+
 // Close executes a proper closure handshake then closes the connection
 // list of close codes: https://datatracker.ietf.org/doc/html/rfc6455#section-7.4
 func (c *Client) Close(closeCode int, text string) {
@@ -435,7 +467,11 @@ func (c *Client) readPump() {
 			continue
 		}
 
-		go c.cfg.EventHandler.ProcessEvent(msg)
+		if c.cfg.ProcessEventsInOrder {
+			c.cfg.EventHandler.ProcessEvent(msg)
+		} else {
+			go c.cfg.EventHandler.ProcessEvent(msg)
+		}
 	}
 }
 